@@ -0,0 +1,288 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/helios-ag/tgstat-go/callback"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// tickInterval is how often Run checks for due digests.
+	tickInterval = time.Second
+	// retryRetention is how long the sinks that failed to receive an event
+	// are remembered for its redelivery.
+	retryRetention = 24 * time.Hour
+)
+
+// Sink receives the events of a rule, one at a time or as a digest.
+type Sink interface {
+	Send(ctx context.Context, events []callback.Event) error
+}
+
+// SinkFunc adapts a function to Sink.
+type SinkFunc func(ctx context.Context, events []callback.Event) error
+
+// Send calls f.
+func (f SinkFunc) Send(ctx context.Context, events []callback.Event) error {
+	return f(ctx, events)
+}
+
+// RuleStats counts the events of a rule.
+type RuleStats struct {
+	Rule    string
+	Matched int
+	// Sent counts the events delivered to every sink of the rule.
+	Sent int
+	// Throttled counts the events dropped by the throttle of the rule.
+	Throttled int
+	// Failed counts the events a sink failed to receive, once per attempt.
+	Failed int
+	// Pending is the number of events waiting for the next digest.
+	Pending int
+}
+
+type ruleState struct {
+	rule     Rule
+	lastSent time.Time
+	digest   []callback.Event
+	// digestStart is when the first event of the pending digest matched.
+	digestStart time.Time
+	stats       RuleStats
+}
+
+// Engine evaluates the rules against every event and dispatches the matches.
+// It is safe for concurrent use.
+//
+// When sinks fail, HandleEvent returns their errors and remembers them: handling
+// the same event again, as the spool or TGStat do after an error, only sends it
+// to the sinks that failed. Digests that failed are sent again by the next Tick.
+type Engine struct {
+	sinks map[string]Sink
+	// Now defaults to time.Now.
+	Now func() time.Time
+	// OnThrottle, when set, is called with the events dropped by the throttle of a rule.
+	OnThrottle func(rule string, event callback.Event)
+
+	mu    sync.Mutex
+	rules []*ruleState
+	// retries holds the failed deliveries of events, by eventKey.
+	retries       map[string][]delivery
+	digestRetries []delivery
+}
+
+// NewEngine returns an engine for the rules, every sink they name must be in sinks.
+func NewEngine(rules []Rule, sinks map[string]Sink) (*Engine, error) {
+	rules = append([]Rule(nil), rules...)
+	if err := compile(rules); err != nil {
+		return nil, err
+	}
+	engine := &Engine{sinks: sinks, Now: time.Now, retries: make(map[string][]delivery)}
+	for _, rule := range rules {
+		for _, sink := range rule.Sinks {
+			if _, ok := sinks[sink]; !ok {
+				return nil, fmt.Errorf("alert: rule %s: unknown sink %s", rule.Name, sink)
+			}
+		}
+		engine.rules = append(engine.rules, &ruleState{rule: rule, stats: RuleStats{Rule: rule.Name}})
+	}
+	return engine, nil
+}
+
+type delivery struct {
+	state  *ruleState
+	sinks  []string
+	events []callback.Event
+	// failedAt is when a sink first failed to receive the events.
+	failedAt time.Time
+}
+
+// HandleEvent sends the event to the sinks of the matching rules, or adds it
+// to their digest. Errors of the sinks are joined.
+func (e *Engine) HandleEvent(ctx context.Context, event callback.Event) error {
+	now := e.Now()
+	key := eventKey(event)
+	var deliveries []delivery
+	var throttled []string
+
+	e.mu.Lock()
+	if retries, ok := e.retries[key]; ok {
+		delete(e.retries, key)
+		deliveries = retries
+	} else {
+		deliveries, throttled = e.match(event, now)
+	}
+	e.mu.Unlock()
+
+	if e.OnThrottle != nil {
+		for _, rule := range throttled {
+			e.OnThrottle(rule, event)
+		}
+	}
+
+	failed, err := e.deliver(ctx, deliveries)
+	if len(failed) != 0 && key != "" {
+		e.mu.Lock()
+		e.retries[key] = failed
+		e.mu.Unlock()
+	}
+	return err
+}
+
+// match evaluates the rules, returning the deliveries due and the names of the
+// rules that throttled the event.
+func (e *Engine) match(event callback.Event, now time.Time) (deliveries []delivery, throttled []string) {
+	for _, state := range e.rules {
+		if !state.rule.When.Match(event) {
+			continue
+		}
+		state.stats.Matched++
+
+		switch {
+		case state.rule.Digest > 0:
+			if len(state.digest) == 0 {
+				state.digestStart = now
+			}
+			state.digest = append(state.digest, event)
+			if state.rule.DigestSize > 0 && len(state.digest) >= state.rule.DigestSize {
+				deliveries = append(deliveries, state.delivery(state.takeDigest()))
+			}
+		case state.rule.Throttle > 0 && !state.lastSent.IsZero() && now.Sub(state.lastSent) < time.Duration(state.rule.Throttle):
+			state.stats.Throttled++
+			throttled = append(throttled, state.rule.Name)
+		default:
+			state.lastSent = now
+			deliveries = append(deliveries, state.delivery([]callback.Event{event}))
+		}
+
+		if state.rule.Stop {
+			break
+		}
+	}
+	return deliveries, throttled
+}
+
+// eventKey identifies the redeliveries of an event: by its ID or, for the
+// events of the keyword monitor which have none, by post and keyword.
+func eventKey(event callback.Event) string {
+	switch {
+	case event.EventID != 0:
+		return "event:" + strconv.FormatInt(event.EventID, 10)
+	case event.Post.ID != 0:
+		return fmt.Sprintf("post:%s:%d:%s", event.EventType, event.Post.ID, event.Keyword)
+	}
+	return ""
+}
+
+// Tick sends the digests whose period has elapsed, and the digests that failed again.
+func (e *Engine) Tick(ctx context.Context) error {
+	return e.flush(ctx, false)
+}
+
+// Flush sends every pending digest, e.g. before shutting down.
+func (e *Engine) Flush(ctx context.Context) error {
+	return e.flush(ctx, true)
+}
+
+// Run calls Tick every second until ctx is done. Errors are passed to onError when set.
+func (e *Engine) Run(ctx context.Context, onError func(error)) error {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := e.Tick(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Stats returns the counters of every rule, in rule order.
+func (e *Engine) Stats() []RuleStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	stats := make([]RuleStats, len(e.rules))
+	for i, state := range e.rules {
+		stats[i] = state.stats
+		stats[i].Pending = len(state.digest)
+	}
+	return stats
+}
+
+func (e *Engine) flush(ctx context.Context, all bool) error {
+	now := e.Now()
+	var deliveries []delivery
+
+	e.mu.Lock()
+	deliveries, e.digestRetries = e.digestRetries, nil
+	for _, state := range e.rules {
+		if len(state.digest) == 0 {
+			continue
+		}
+		if all || now.Sub(state.digestStart) >= time.Duration(state.rule.Digest) {
+			deliveries = append(deliveries, state.delivery(state.takeDigest()))
+		}
+	}
+	for key, retries := range e.retries {
+		if now.Sub(retries[0].failedAt) >= retryRetention {
+			delete(e.retries, key)
+		}
+	}
+	e.mu.Unlock()
+
+	failed, err := e.deliver(ctx, deliveries)
+	e.mu.Lock()
+	for _, d := range failed {
+		if now.Sub(d.failedAt) < retryRetention {
+			e.digestRetries = append(e.digestRetries, d)
+		}
+	}
+	e.mu.Unlock()
+	return err
+}
+
+func (s *ruleState) delivery(events []callback.Event) delivery {
+	return delivery{state: s, sinks: s.rule.Sinks, events: events}
+}
+
+func (s *ruleState) takeDigest() []callback.Event {
+	events := s.digest
+	s.digest = nil
+	return events
+}
+
+// deliver sends the deliveries to their sinks and returns those that failed,
+// left with the sinks that failed only.
+func (e *Engine) deliver(ctx context.Context, deliveries []delivery) ([]delivery, error) {
+	var failed []delivery
+	var errs []error
+	for _, d := range deliveries {
+		var failedSinks []string
+		for _, name := range d.sinks {
+			if err := e.sinks[name].Send(ctx, d.events); err != nil {
+				errs = append(errs, fmt.Errorf("alert: rule %s: sink %s: %w", d.state.rule.Name, name, err))
+				failedSinks = append(failedSinks, name)
+			}
+		}
+
+		e.mu.Lock()
+		if len(failedSinks) != 0 {
+			d.state.stats.Failed += len(d.events)
+			d.sinks = failedSinks
+			if d.failedAt.IsZero() {
+				d.failedAt = e.Now()
+			}
+			failed = append(failed, d)
+		} else {
+			d.state.stats.Sent += len(d.events)
+		}
+		e.mu.Unlock()
+	}
+	return failed, errors.Join(errs...)
+}
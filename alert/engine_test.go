@@ -0,0 +1,166 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/callback"
+	. "github.com/onsi/gomega"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	batches [][]int64
+	err     error
+}
+
+func (r *recorder) Send(_ context.Context, events []callback.Event) error {
+	if r.err != nil {
+		return r.err
+	}
+	var ids []int64
+	for _, event := range events {
+		ids = append(ids, event.Post.ID)
+	}
+	r.batches = append(r.batches, ids)
+	return nil
+}
+
+func post(id int64, text string) callback.Event {
+	return callback.Event{EventType: tgstat.EventNewPost, Post: tgstat.Post{ID: id, ChannelID: 1, Text: text}}
+}
+
+func TestEngine(t *testing.T) {
+	RegisterTestingT(t)
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+
+	t.Run("Test unknown sink", func(t *testing.T) {
+		_, err := NewEngine([]Rule{{Name: "a", Sinks: []string{"missing"}}}, map[string]Sink{})
+		Expect(err).To(MatchError("alert: rule a: unknown sink missing"))
+	})
+
+	t.Run("Test throttle", func(t *testing.T) {
+		sink := &recorder{}
+		engine, err := NewEngine([]Rule{{Name: "all", Sinks: []string{"s"}, Throttle: Duration(time.Minute)}}, map[string]Sink{"s": sink})
+		Expect(err).ToNot(HaveOccurred())
+		engine.Now = clock
+
+		Expect(engine.HandleEvent(context.Background(), post(1, ""))).To(Succeed())
+		now = now.Add(30 * time.Second)
+		Expect(engine.HandleEvent(context.Background(), post(2, ""))).To(Succeed())
+		now = now.Add(31 * time.Second)
+		Expect(engine.HandleEvent(context.Background(), post(3, ""))).To(Succeed())
+
+		Expect(sink.batches).To(Equal([][]int64{{1}, {3}}))
+		Expect(engine.Stats()).To(Equal([]RuleStats{{Rule: "all", Matched: 3, Sent: 2, Throttled: 1}}))
+
+		var throttled []string
+		engine.OnThrottle = func(rule string, event callback.Event) {
+			throttled = append(throttled, fmt.Sprintf("%s/%d", rule, event.Post.ID))
+		}
+		Expect(engine.HandleEvent(context.Background(), post(4, ""))).To(Succeed())
+		Expect(throttled).To(Equal([]string{"all/4"}))
+	})
+
+	t.Run("Test digest by period and size", func(t *testing.T) {
+		sink := &recorder{}
+		engine, err := NewEngine([]Rule{{Name: "digest", Sinks: []string{"s"}, Digest: Duration(time.Hour), DigestSize: 3}}, map[string]Sink{"s": sink})
+		Expect(err).ToNot(HaveOccurred())
+		engine.Now = clock
+
+		for id := int64(1); id <= 4; id++ {
+			Expect(engine.HandleEvent(context.Background(), post(id, ""))).To(Succeed())
+		}
+		Expect(sink.batches).To(Equal([][]int64{{1, 2, 3}}))
+		Expect(engine.Stats()[0].Pending).To(Equal(1))
+
+		now = now.Add(59 * time.Minute)
+		Expect(engine.Tick(context.Background())).To(Succeed())
+		Expect(sink.batches).To(HaveLen(1))
+
+		now = now.Add(time.Minute)
+		Expect(engine.Tick(context.Background())).To(Succeed())
+		Expect(sink.batches).To(Equal([][]int64{{1, 2, 3}, {4}}))
+
+		Expect(engine.HandleEvent(context.Background(), post(5, ""))).To(Succeed())
+		Expect(engine.Flush(context.Background())).To(Succeed())
+		Expect(sink.batches).To(Equal([][]int64{{1, 2, 3}, {4}, {5}}))
+		Expect(engine.Stats()[0].Sent).To(Equal(5))
+	})
+
+	t.Run("Test routing and stop", func(t *testing.T) {
+		rules, err := ParseYAML([]byte(`
+rules:
+  - name: urgent
+    when: {text: urgent}
+    sinks: [pager, log]
+    stop: true
+  - name: rest
+    when: {}
+    sinks: [log]
+`))
+		Expect(err).ToNot(HaveOccurred())
+		pager, log := &recorder{}, &recorder{}
+		engine, err := NewEngine(rules, map[string]Sink{"pager": pager, "log": log})
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(engine.HandleEvent(context.Background(), post(1, "urgent news"))).To(Succeed())
+		Expect(engine.HandleEvent(context.Background(), post(2, "news"))).To(Succeed())
+		Expect(pager.batches).To(Equal([][]int64{{1}}))
+		Expect(log.batches).To(Equal([][]int64{{1}, {2}}))
+	})
+
+	t.Run("Test sink errors are reported", func(t *testing.T) {
+		broken, ok := &recorder{err: errors.New("down")}, &recorder{}
+		engine, err := NewEngine([]Rule{{Name: "all", Sinks: []string{"broken", "ok"}}}, map[string]Sink{"broken": broken, "ok": ok})
+		Expect(err).ToNot(HaveOccurred())
+
+		err = engine.HandleEvent(context.Background(), post(1, ""))
+		Expect(err).To(MatchError("alert: rule all: sink broken: down"))
+		Expect(ok.batches).To(HaveLen(1))
+		Expect(engine.Stats()[0].Failed).To(Equal(1))
+	})
+
+	t.Run("Test redelivery only retries the failed sinks", func(t *testing.T) {
+		broken, ok := &recorder{err: errors.New("down")}, &recorder{}
+		engine, err := NewEngine([]Rule{{Name: "all", Sinks: []string{"broken", "ok"}, Throttle: Duration(time.Hour)}},
+			map[string]Sink{"broken": broken, "ok": ok})
+		Expect(err).ToNot(HaveOccurred())
+		engine.Now = clock
+
+		event := post(1, "")
+		event.EventID = 42
+		Expect(engine.HandleEvent(context.Background(), event)).ToNot(Succeed())
+		Expect(engine.HandleEvent(context.Background(), event)).ToNot(Succeed())
+
+		broken.err = nil
+		Expect(engine.HandleEvent(context.Background(), event)).To(Succeed())
+		Expect(broken.batches).To(Equal([][]int64{{1}}))
+		Expect(ok.batches).To(Equal([][]int64{{1}}))
+		Expect(engine.Stats()).To(Equal([]RuleStats{{Rule: "all", Matched: 1, Sent: 1, Failed: 2}}))
+
+		// the event is delivered, handling it again is a new match
+		Expect(engine.HandleEvent(context.Background(), event)).To(Succeed())
+		Expect(engine.Stats()[0].Throttled).To(Equal(1))
+	})
+
+	t.Run("Test failed digests are sent again on tick", func(t *testing.T) {
+		broken, ok := &recorder{err: errors.New("down")}, &recorder{}
+		engine, err := NewEngine([]Rule{{Name: "digest", Sinks: []string{"broken", "ok"}, Digest: Duration(time.Minute)}},
+			map[string]Sink{"broken": broken, "ok": ok})
+		Expect(err).ToNot(HaveOccurred())
+		engine.Now = clock
+
+		Expect(engine.HandleEvent(context.Background(), post(1, ""))).To(Succeed())
+		now = now.Add(time.Minute)
+		Expect(engine.Tick(context.Background())).ToNot(Succeed())
+		broken.err = nil
+		Expect(engine.Tick(context.Background())).To(Succeed())
+		Expect(engine.Tick(context.Background())).To(Succeed())
+		Expect(broken.batches).To(Equal([][]int64{{1}}))
+		Expect(ok.batches).To(Equal([][]int64{{1}}))
+	})
+}
@@ -0,0 +1,206 @@
+// Package alert routes post events to sinks according to declarative rules.
+//
+// Rules are loaded from YAML or JSON:
+//
+//	rules:
+//	  - name: brand
+//	    when:
+//	      text: "(?i)acme"
+//	      min_views: 1000
+//	      black_label: false
+//	    sinks: [slack]
+//	    throttle: 10m
+//	  - name: competitors
+//	    when:
+//	      channel_ids: [1, 2, 3]
+//	    sinks: [mail]
+//	    digest: 1h
+//
+// Engine implements callback.Handler, so it consumes callback deliveries and
+// KeywordMonitor matches alike.
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/callback"
+	"go.yaml.in/yaml/v3"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Duration is a time.Duration written as "90s" or "1h30m" in rule files.
+type Duration time.Duration
+
+// UnmarshalText parses the duration with time.ParseDuration.
+func (d *Duration) UnmarshalText(text []byte) error {
+	value, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(value)
+	return nil
+}
+
+// MarshalText formats the duration with time.Duration.String.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Condition matches events, every field set must match.
+type Condition struct {
+	// Text and NotText are regular expressions matched against the post text.
+	Text    string `json:"text,omitempty" yaml:"text,omitempty"`
+	NotText string `json:"not_text,omitempty" yaml:"not_text,omitempty"`
+	// ChannelIDs matches the channel of the post.
+	ChannelIDs []int `json:"channel_ids,omitempty" yaml:"channel_ids,omitempty"`
+	// Keywords matches the query of keyword events.
+	Keywords   []string           `json:"keywords,omitempty" yaml:"keywords,omitempty"`
+	EventTypes []tgstat.EventType `json:"event_types,omitempty" yaml:"event_types,omitempty"`
+	// MinViews and MaxViews bound the views of the post, zero is no bound.
+	MinViews int `json:"min_views,omitempty" yaml:"min_views,omitempty"`
+	MaxViews int `json:"max_views,omitempty" yaml:"max_views,omitempty"`
+	// MediaTypes matches the media type of the post, e.g. mediaPhoto.
+	MediaTypes []string `json:"media_types,omitempty" yaml:"media_types,omitempty"`
+	// RedLabel and BlackLabel match the restrictions of the channel of the event.
+	RedLabel   *bool `json:"red_label,omitempty" yaml:"red_label,omitempty"`
+	BlackLabel *bool `json:"black_label,omitempty" yaml:"black_label,omitempty"`
+
+	text    *regexp.Regexp
+	notText *regexp.Regexp
+}
+
+// Rule sends the events matching When to Sinks.
+type Rule struct {
+	Name  string    `json:"name" yaml:"name"`
+	When  Condition `json:"when" yaml:"when"`
+	Sinks []string  `json:"sinks" yaml:"sinks"`
+	// Throttle is the minimum time between two notifications of the rule,
+	// events matched in between are dropped. It does not apply to digests.
+	Throttle Duration `json:"throttle,omitempty" yaml:"throttle,omitempty"`
+	// Digest collects the events matched over the period and sends them together.
+	Digest Duration `json:"digest,omitempty" yaml:"digest,omitempty"`
+	// DigestSize sends a digest early once it holds that many events.
+	DigestSize int `json:"digest_size,omitempty" yaml:"digest_size,omitempty"`
+	// Stop skips the rules after this one when it matches.
+	Stop bool `json:"stop,omitempty" yaml:"stop,omitempty"`
+}
+
+type ruleFile struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+}
+
+// ParseJSON reads rules from a JSON document with a top level "rules" array.
+func ParseJSON(data []byte) ([]Rule, error) {
+	var file ruleFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("alert: %w", err)
+	}
+	return file.Rules, compile(file.Rules)
+}
+
+// ParseYAML reads rules from a YAML document with a top level "rules" list.
+func ParseYAML(data []byte) ([]Rule, error) {
+	var file ruleFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("alert: %w", err)
+	}
+	return file.Rules, compile(file.Rules)
+}
+
+// LoadFile reads rules from a .json, .yaml or .yml file.
+func LoadFile(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("alert: %w", err)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return ParseJSON(data)
+	case ".yaml", ".yml":
+		return ParseYAML(data)
+	}
+	return nil, fmt.Errorf("alert: %s: unknown rule file format", path)
+}
+
+// compile validates the rules and compiles their regular expressions.
+func compile(rules []Rule) error {
+	names := make(map[string]bool, len(rules))
+	for i := range rules {
+		rule := &rules[i]
+		if rule.Name == "" {
+			return fmt.Errorf("alert: rule %d: name is required", i)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("alert: rule %s: duplicate name", rule.Name)
+		}
+		names[rule.Name] = true
+		if len(rule.Sinks) == 0 {
+			return fmt.Errorf("alert: rule %s: no sinks", rule.Name)
+		}
+		if rule.Throttle < 0 || rule.Digest < 0 || rule.DigestSize < 0 {
+			return fmt.Errorf("alert: rule %s: negative throttle or digest", rule.Name)
+		}
+		if err := rule.When.compile(); err != nil {
+			return fmt.Errorf("alert: rule %s: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+func (c *Condition) compile() error {
+	var err error
+	if c.Text != "" {
+		if c.text, err = regexp.Compile(c.Text); err != nil {
+			return fmt.Errorf("text: %w", err)
+		}
+	}
+	if c.NotText != "" {
+		if c.notText, err = regexp.Compile(c.NotText); err != nil {
+			return fmt.Errorf("not_text: %w", err)
+		}
+	}
+	if c.MaxViews != 0 && c.MaxViews < c.MinViews {
+		return errors.New("max_views is below min_views")
+	}
+	return nil
+}
+
+// Match reports whether the event satisfies the condition.
+// The condition must come from ParseJSON, ParseYAML, LoadFile or NewEngine.
+func (c *Condition) Match(event callback.Event) bool {
+	post := event.Post
+	switch {
+	case c.text != nil && !c.text.MatchString(post.Text),
+		c.notText != nil && c.notText.MatchString(post.Text),
+		len(c.ChannelIDs) != 0 && !contains(c.ChannelIDs, post.ChannelID),
+		len(c.Keywords) != 0 && !contains(c.Keywords, event.Keyword),
+		len(c.EventTypes) != 0 && !contains(c.EventTypes, event.EventType),
+		c.MinViews != 0 && post.Views < c.MinViews,
+		c.MaxViews != 0 && post.Views > c.MaxViews,
+		len(c.MediaTypes) != 0 && !contains(c.MediaTypes, post.Media.MediaType),
+		c.RedLabel != nil && *c.RedLabel != event.Channel.Restrictions.RedLabel,
+		c.BlackLabel != nil && *c.BlackLabel != event.Channel.Restrictions.BlackLabel:
+		return false
+	}
+	return true
+}
+
+func contains[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
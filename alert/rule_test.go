@@ -0,0 +1,125 @@
+package alert
+
+import (
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/callback"
+	. "github.com/onsi/gomega"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const yamlRules = `
+rules:
+  - name: brand
+    when:
+      text: "(?i)acme"
+      not_text: "(?i)giveaway"
+      min_views: 100
+      max_views: 5000
+      media_types: [mediaPhoto]
+      black_label: false
+    sinks: [slack]
+    throttle: 10m
+  - name: competitors
+    when:
+      channel_ids: [1, 2]
+      keywords: [rival]
+      event_types: [new_post]
+    sinks: [mail, slack]
+    digest: 1h
+    digest_size: 20
+    stop: true
+`
+
+func TestParse(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test parse yaml rules", func(t *testing.T) {
+		rules, err := ParseYAML([]byte(yamlRules))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(HaveLen(2))
+		Expect(rules[0].Name).To(Equal("brand"))
+		Expect(rules[0].When.MinViews).To(Equal(100))
+		Expect(*rules[0].When.BlackLabel).To(BeFalse())
+		Expect(rules[0].When.RedLabel).To(BeNil())
+		Expect(time.Duration(rules[0].Throttle)).To(Equal(10 * time.Minute))
+		Expect(rules[1].When.ChannelIDs).To(Equal([]int{1, 2}))
+		Expect(rules[1].When.EventTypes).To(Equal([]tgstat.EventType{tgstat.EventNewPost}))
+		Expect(rules[1].Sinks).To(Equal([]string{"mail", "slack"}))
+		Expect(time.Duration(rules[1].Digest)).To(Equal(time.Hour))
+		Expect(rules[1].DigestSize).To(Equal(20))
+		Expect(rules[1].Stop).To(BeTrue())
+	})
+
+	t.Run("Test parse json rules", func(t *testing.T) {
+		rules, err := ParseJSON([]byte(`{"rules":[{"name":"views","when":{"min_views":10,"red_label":true},"sinks":["log"],"digest":"30s"}]}`))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules[0].When.MinViews).To(Equal(10))
+		Expect(*rules[0].When.RedLabel).To(BeTrue())
+		Expect(time.Duration(rules[0].Digest)).To(Equal(30 * time.Second))
+	})
+
+	t.Run("Test load file by extension", func(t *testing.T) {
+		dir := t.TempDir()
+		Expect(os.WriteFile(filepath.Join(dir, "rules.yml"), []byte(yamlRules), 0o644)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(dir, "rules.txt"), []byte(yamlRules), 0o644)).To(Succeed())
+
+		rules, err := LoadFile(filepath.Join(dir, "rules.yml"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(rules).To(HaveLen(2))
+
+		_, err = LoadFile(filepath.Join(dir, "rules.txt"))
+		Expect(err).To(MatchError(ContainSubstring("unknown rule file format")))
+	})
+
+	t.Run("Test invalid rules", func(t *testing.T) {
+		for document, message := range map[string]string{
+			`{"rules":[{"when":{},"sinks":["a"]}]}`:                                       "rule 0: name is required",
+			`{"rules":[{"name":"a","when":{}}]}`:                                          "rule a: no sinks",
+			`{"rules":[{"name":"a","sinks":["s"]},{"name":"a","sinks":["s"]}]}`:           "rule a: duplicate name",
+			`{"rules":[{"name":"a","when":{"text":"("},"sinks":["s"]}]}`:                  "rule a: text:",
+			`{"rules":[{"name":"a","when":{"min_views":5,"max_views":1},"sinks":["s"]}]}`: "max_views is below min_views",
+			`{"rules":[{"name":"a","when":{"views":5},"sinks":["s"]}]}`:                   "unknown field",
+			`{"rules":[{"name":"a","sinks":["s"],"throttle":"soon"}]}`:                    "invalid duration",
+		} {
+			_, err := ParseJSON([]byte(document))
+			Expect(err).To(MatchError(ContainSubstring(message)), document)
+		}
+
+		_, err := ParseYAML([]byte("rules:\n  - name: a\n    sinks: [s]\n    when:\n      view: 1\n"))
+		Expect(err).To(MatchError(ContainSubstring("field view not found")))
+	})
+}
+
+func TestCondition_Match(t *testing.T) {
+	RegisterTestingT(t)
+	rules, err := ParseYAML([]byte(yamlRules))
+	Expect(err).ToNot(HaveOccurred())
+	brand, competitors := rules[0].When, rules[1].When
+
+	event := callback.Event{
+		EventType: tgstat.EventNewPost,
+		Post:      tgstat.Post{ChannelID: 1, Views: 500, Text: "New ACME phone", Media: tgstat.PostMedia{MediaType: "mediaPhoto"}},
+		Keyword:   "rival",
+	}
+	Expect(brand.Match(event)).To(BeTrue())
+	Expect(competitors.Match(event)).To(BeTrue())
+
+	modified := func(fn func(*callback.Event)) callback.Event {
+		e := event
+		fn(&e)
+		return e
+	}
+	Expect(brand.Match(modified(func(e *callback.Event) { e.Post.Text = "ACME giveaway" }))).To(BeFalse())
+	Expect(brand.Match(modified(func(e *callback.Event) { e.Post.Text = "other" }))).To(BeFalse())
+	Expect(brand.Match(modified(func(e *callback.Event) { e.Post.Views = 99 }))).To(BeFalse())
+	Expect(brand.Match(modified(func(e *callback.Event) { e.Post.Views = 5001 }))).To(BeFalse())
+	Expect(brand.Match(modified(func(e *callback.Event) { e.Post.Media.MediaType = "mediaDocument" }))).To(BeFalse())
+	Expect(brand.Match(modified(func(e *callback.Event) { e.Channel.Restrictions.BlackLabel = true }))).To(BeFalse())
+	Expect(brand.Match(modified(func(e *callback.Event) { e.Channel.Restrictions.RedLabel = true }))).To(BeTrue())
+	Expect(competitors.Match(modified(func(e *callback.Event) { e.Post.ChannelID = 3 }))).To(BeFalse())
+	Expect(competitors.Match(modified(func(e *callback.Event) { e.Keyword = "" }))).To(BeFalse())
+	Expect(competitors.Match(modified(func(e *callback.Event) { e.EventType = tgstat.EventEditPost }))).To(BeFalse())
+}
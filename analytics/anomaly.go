@@ -0,0 +1,242 @@
+package analytics
+
+import (
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"math"
+	"sort"
+	"time"
+)
+
+// Detector names reported in Anomaly.Detector.
+const (
+	DetectorZScore     = "robust_zscore"
+	DetectorStepChange = "step_change"
+	DetectorViewsRatio = "views_ratio"
+	DetectorFlatErr    = "flat_err"
+)
+
+// Default thresholds of the detectors used by Detect.
+const (
+	DefaultZScoreThreshold     = 3.5
+	DefaultStepChangePercent   = 20.0
+	DefaultViewsRatioThreshold = 3.5
+	DefaultFlatErrPeriods      = 5
+	DefaultFlatErrTolerance    = 0.1
+)
+
+// Anomaly is a period flagged by a detector.
+type Anomaly struct {
+	Detector string
+	Start    time.Time
+	End      time.Time
+	Value    float64
+	// Score is how far the value is past the detector threshold, 1 is at the threshold.
+	Score       float64
+	Explanation string
+}
+
+// RobustZScore flags the changes between periods whose modified z-score,
+// based on the median and the median absolute deviation, exceeds threshold.
+func RobustZScore(series tgstat.Series, threshold float64) []Anomaly {
+	deltas := series.Deltas()
+	scores := modifiedZScores(deltas.Values())
+
+	var anomalies []Anomaly
+	for i, score := range scores {
+		if math.Abs(score) < threshold {
+			continue
+		}
+		p := deltas.Points[i]
+		anomalies = append(anomalies, Anomaly{
+			Detector:    DetectorZScore,
+			Start:       p.Start,
+			End:         p.End,
+			Value:       p.Value,
+			Score:       math.Abs(score) / threshold,
+			Explanation: fmt.Sprintf("change of %+.0f has a robust z-score of %.1f", p.Value, score),
+		})
+	}
+	return anomalies
+}
+
+// StepChanges flags the periods changing by at least percent against the previous one.
+// Periods following a zero value are skipped.
+func StepChanges(series tgstat.Series, percent float64) []Anomaly {
+	var anomalies []Anomaly
+	for i := 1; i < len(series.Points); i++ {
+		p := series.Points[i]
+		growth, ok := tgstat.Series{Points: series.Points[i-1 : i+1]}.TotalGrowth()
+		if !ok || math.Abs(growth) < percent {
+			continue
+		}
+		anomalies = append(anomalies, Anomaly{
+			Detector:    DetectorStepChange,
+			Start:       p.Start,
+			End:         p.End,
+			Value:       p.Value,
+			Score:       math.Abs(growth) / percent,
+			Explanation: fmt.Sprintf("value changed by %+.1f%% in one period", growth),
+		})
+	}
+	return anomalies
+}
+
+// ViewsRatioOutliers flags the periods whose views to subscribers ratio
+// has a modified z-score exceeding threshold. Periods are matched by start.
+func ViewsRatioOutliers(subscribers, views tgstat.Series, threshold float64) []Anomaly {
+	counts := make(map[time.Time]float64, len(subscribers.Points))
+	for _, p := range subscribers.Points {
+		counts[p.Start] = p.Value
+	}
+
+	var (
+		points []tgstat.SeriesPoint
+		ratios []float64
+	)
+	for _, p := range views.Points {
+		if count := counts[p.Start]; count > 0 {
+			points = append(points, p)
+			ratios = append(ratios, p.Value/count)
+		}
+	}
+
+	var anomalies []Anomaly
+	for i, score := range modifiedZScores(ratios) {
+		if math.Abs(score) < threshold {
+			continue
+		}
+		anomalies = append(anomalies, Anomaly{
+			Detector:    DetectorViewsRatio,
+			Start:       points[i].Start,
+			End:         points[i].End,
+			Value:       ratios[i],
+			Score:       math.Abs(score) / threshold,
+			Explanation: fmt.Sprintf("views to subscribers ratio of %.2f has a robust z-score of %.1f", ratios[i], score),
+		})
+	}
+	return anomalies
+}
+
+// FlatErr flags runs of at least periods points where ERR stays within
+// tolerance percentage points, organic channels rarely keep it that stable.
+func FlatErr(series tgstat.Series, periods int, tolerance float64) []Anomaly {
+	points := series.Sorted().Points
+
+	var anomalies []Anomaly
+	for start := 0; start < len(points); {
+		low, high := points[start].Value, points[start].Value
+		end := start + 1
+		for ; end < len(points); end++ {
+			v := points[end].Value
+			if math.Max(high, v)-math.Min(low, v) > tolerance {
+				break
+			}
+			low, high = math.Min(low, v), math.Max(high, v)
+		}
+		if length := end - start; length >= periods {
+			anomalies = append(anomalies, Anomaly{
+				Detector:    DetectorFlatErr,
+				Start:       points[start].Start,
+				End:         points[end-1].End,
+				Value:       (low + high) / 2,
+				Score:       float64(length) / float64(periods),
+				Explanation: fmt.Sprintf("ERR stayed within %.2f points for %d periods", high-low, length),
+			})
+		}
+		start = end
+	}
+	return anomalies
+}
+
+// Detect runs every detector with the default thresholds, empty series are skipped.
+func Detect(subscribers, views, err tgstat.Series) []Anomaly {
+	var anomalies []Anomaly
+	anomalies = append(anomalies, RobustZScore(subscribers, DefaultZScoreThreshold)...)
+	anomalies = append(anomalies, StepChanges(subscribers, DefaultStepChangePercent)...)
+	anomalies = append(anomalies, RobustZScore(views, DefaultZScoreThreshold)...)
+	anomalies = append(anomalies, ViewsRatioOutliers(subscribers, views, DefaultViewsRatioThreshold)...)
+	anomalies = append(anomalies, FlatErr(err, DefaultFlatErrPeriods, DefaultFlatErrTolerance)...)
+
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Start.Before(anomalies[j].Start)
+	})
+	return anomalies
+}
+
+// Anomalies runs Detect over the series of the report.
+func (r *ChannelReport) Anomalies() []Anomaly {
+	return Detect(r.Subscribers, r.Views, r.Err)
+}
+
+// Risk is the likelihood of a channel being inflated, Score is from 0 to 100.
+type Risk struct {
+	Score   float64
+	Reasons []string
+}
+
+// Weights of the risk score.
+const (
+	riskRedLabel   = 50.0
+	riskAnomaly    = 10.0
+	riskMaxAnomaly = 25.0
+)
+
+// RiskScore combines the TGStat labels of a channel with its anomalies.
+// A black label is the maximum risk, a red label adds half of it and every
+// anomaly adds up to a quarter depending on its score.
+func RiskScore(restrictions tgstat.Restrictions, anomalies []Anomaly) Risk {
+	var risk Risk
+	if restrictions.BlackLabel {
+		return Risk{Score: 100, Reasons: []string{"channel has a black label"}}
+	}
+	if restrictions.RedLabel {
+		risk.Score += riskRedLabel
+		risk.Reasons = append(risk.Reasons, "channel has a red label")
+	}
+	for _, anomaly := range anomalies {
+		risk.Score += math.Min(anomaly.Score*riskAnomaly, riskMaxAnomaly)
+		risk.Reasons = append(risk.Reasons, fmt.Sprintf("%s %s: %s", anomaly.Start.Format("2006-01-02"), anomaly.Detector, anomaly.Explanation))
+	}
+	risk.Score = math.Min(risk.Score, 100)
+	return risk
+}
+
+// modifiedZScores returns 0.6745 * (x - median) / MAD for every value.
+// When the MAD is zero the mean absolute deviation is used instead, and the
+// scale never drops below 5% of the median so tiny noise is not flagged.
+func modifiedZScores(values []float64) []float64 {
+	scores := make([]float64, len(values))
+	if len(values) < 3 {
+		return scores
+	}
+
+	med := median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - med)
+	}
+
+	scale := median(deviations) / 0.6745
+	if scale == 0 {
+		scale = mean(deviations) * 1.2533
+	}
+	scale = math.Max(scale, math.Abs(med)*0.05)
+	if scale == 0 {
+		return scores
+	}
+	for i, v := range values {
+		scores[i] = (v - med) / scale
+	}
+	return scores
+}
+
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,85 @@
+package analytics
+
+import (
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func daily(values ...float64) tgstat.Series {
+	periods := make([]string, len(values))
+	for i := range values {
+		periods[i] = fmt.Sprintf("2024-01-%02d", i+1)
+	}
+	series, _ := tgstat.NewSeries(tgstat.GroupDay, periods, values)
+	return series
+}
+
+func TestDetectors(t *testing.T) {
+	RegisterTestingT(t)
+	subscribers := daily(1000, 1010, 1021, 1030, 1041, 3041, 3050, 3061, 3070)
+
+	t.Run("Test robust z-score flags a spike", func(t *testing.T) {
+		anomalies := RobustZScore(subscribers, DefaultZScoreThreshold)
+		Expect(anomalies).To(HaveLen(1))
+		Expect(anomalies[0].Detector).To(Equal(DetectorZScore))
+		Expect(anomalies[0].Start).To(Equal(subscribers.Points[5].Start))
+		Expect(anomalies[0].Value).To(Equal(2000.0))
+		Expect(anomalies[0].Score).To(BeNumerically(">", 1))
+		Expect(anomalies[0].Explanation).To(ContainSubstring("+2000"))
+	})
+
+	t.Run("Test step changes", func(t *testing.T) {
+		anomalies := StepChanges(subscribers, DefaultStepChangePercent)
+		Expect(anomalies).To(HaveLen(1))
+		Expect(anomalies[0].Value).To(Equal(3041.0))
+		Expect(anomalies[0].Explanation).To(ContainSubstring("+192.1%"))
+	})
+
+	t.Run("Test step changes skip periods following zero", func(t *testing.T) {
+		series := daily(0, 100, 110, 300)
+		anomalies := StepChanges(series, DefaultStepChangePercent)
+		Expect(anomalies).To(HaveLen(1))
+		Expect(anomalies[0].Start).To(Equal(series.Points[3].Start))
+		Expect(anomalies[0].Value).To(Equal(300.0))
+	})
+
+	t.Run("Test views ratio outliers", func(t *testing.T) {
+		views := daily(300, 330, 280, 320, 5000, 900, 1000, 850, 950)
+		anomalies := ViewsRatioOutliers(subscribers, views, DefaultViewsRatioThreshold)
+		Expect(anomalies).To(HaveLen(1))
+		Expect(anomalies[0].Start).To(Equal(views.Points[4].Start))
+	})
+
+	t.Run("Test flat ERR", func(t *testing.T) {
+		anomalies := FlatErr(daily(12, 9, 15.01, 15.02, 15, 15.05, 15.01, 15.03, 11), DefaultFlatErrPeriods, DefaultFlatErrTolerance)
+		Expect(anomalies).To(HaveLen(1))
+		Expect(anomalies[0].Start).To(Equal(daily(0, 0, 0).Points[2].Start))
+		Expect(anomalies[0].Score).To(Equal(1.2))
+
+		Expect(FlatErr(daily(10, 12, 14, 13, 11, 15), DefaultFlatErrPeriods, DefaultFlatErrTolerance)).To(BeEmpty())
+	})
+
+	t.Run("Test organic series has no anomalies", func(t *testing.T) {
+		Expect(Detect(daily(100, 103, 105, 108, 110), daily(40, 41, 43, 42, 44), daily(40, 38, 41, 39, 42))).To(BeEmpty())
+	})
+}
+
+func TestRiskScore(t *testing.T) {
+	RegisterTestingT(t)
+	anomalies := []Anomaly{{Detector: DetectorZScore, Score: 1.5}, {Detector: DetectorFlatErr, Score: 4}}
+
+	t.Run("Test anomalies add up", func(t *testing.T) {
+		risk := RiskScore(tgstat.Restrictions{}, anomalies)
+		Expect(risk.Score).To(Equal(40.0))
+		Expect(risk.Reasons).To(HaveLen(2))
+	})
+
+	t.Run("Test labels", func(t *testing.T) {
+		Expect(RiskScore(tgstat.Restrictions{RedLabel: true}, anomalies).Score).To(Equal(90.0))
+		Expect(RiskScore(tgstat.Restrictions{RedLabel: true}, append(anomalies, anomalies...)).Score).To(Equal(100.0))
+		Expect(RiskScore(tgstat.Restrictions{BlackLabel: true}, nil).Score).To(Equal(100.0))
+		Expect(RiskScore(tgstat.Restrictions{}, nil).Score).To(BeZero())
+	})
+}
@@ -0,0 +1,119 @@
+package analytics
+
+import (
+	"context"
+	"encoding/json"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func prepareClient(URL string) {
+	tgstat.Token = "token"
+	tgstat.WithEndpoint(URL)
+}
+
+func respond(testServer server.Server, path string, response interface{}) {
+	testServer.Mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response) //nolint
+	})
+}
+
+func TestReport(t *testing.T) {
+	RegisterTestingT(t)
+	from := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 4)
+
+	t.Run("Test request validation", func(t *testing.T) {
+		_, err := Report(context.Background(), ReportRequest{From: from, To: to})
+		Expect(err).To(MatchError(ContainSubstring("channel id is required")))
+
+		_, err = Report(context.Background(), ReportRequest{ChannelId: "@channel", From: to, To: from})
+		Expect(err).To(MatchError(ContainSubstring("period end")))
+
+		_, err = Report(context.Background(), ReportRequest{ChannelId: "@channel", From: from, To: to, Group: tgstat.GroupHour})
+		Expect(err).To(MatchError(`analytics: group "hour" is not supported, use day, week or month`))
+	})
+
+	t.Run("Test report is computed from the fetched data", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+
+		respond(testServer, endpoints.ChannelsStat, tgstat.ChannelStatResult{
+			Status:   "ok",
+			Response: tgstat.ChannelStatResponse{Id: 1, ParticipantsCount: 1100, AvgPostReach: 400},
+		})
+		respond(testServer, endpoints.ChannelsSubscribers, tgstat.ChannelSubscribers{Status: "ok", Response: []tgstat.ChannelSubscribersResponse{
+			{Period: "2024-01-04", ParticipantsCount: 1100},
+			{Period: "2024-01-03", ParticipantsCount: 1050},
+			{Period: "2024-01-02", ParticipantsCount: 900},
+			{Period: "2024-01-01", ParticipantsCount: 1000},
+		}})
+		respond(testServer, endpoints.ChannelsViews, tgstat.ChannelViews{Status: "ok", Response: []tgstat.ChannelViewsResponse{
+			{Period: "2024-01-01", ViewsCount: 500},
+		}})
+		respond(testServer, endpoints.ChannelAVGPostsReach, tgstat.ChannelAvgReach{Status: "ok", Response: []tgstat.ChannelAvgReachResponse{
+			{Period: "2024-01-01", AvgPostsReach: 300},
+			{Period: "2024-01-02", AvgPostsReach: 500},
+		}})
+		respond(testServer, endpoints.ChannelErr, tgstat.ChannelErr{Status: "ok", Response: []tgstat.ChannelErrResponse{
+			{Period: "2024-01-01", Err: 10},
+			{Period: "2024-01-02", Err: 12},
+			{Period: "2024-01-03", Err: 14},
+		}})
+
+		var offsets []string
+		testServer.Mux.HandleFunc(endpoints.ChannelsPosts, func(w http.ResponseWriter, r *http.Request) {
+			offsets = append(offsets, r.URL.Query().Get("offset"))
+			count := postsPageSize
+			if r.URL.Query().Get("offset") != "0" {
+				count = 2
+			}
+			items := make([]tgstat.ChannelPostsResponseItem, count)
+			for i := range items {
+				items[i] = tgstat.ChannelPostsResponseItem{ID: int64(len(offsets)*100 + i), Views: i}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tgstat.ChannelPostsResult{ //nolint
+				Status:   "ok",
+				Response: tgstat.ChannelPostsResponse{Items: items},
+			})
+		})
+
+		report, err := Report(context.Background(), ReportRequest{ChannelId: "@channel", From: from, To: to, TopPosts: 3})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(report.Stat.ParticipantsCount).To(Equal(1100))
+		Expect(report.Subscribers.Values()).To(Equal([]float64{1000, 900, 1050, 1100}))
+		Expect(report.SubscribersGrowth).To(Equal(100.0))
+		Expect(report.SubscribersGrowthPercent).To(HaveValue(Equal(10.0)))
+		Expect(report.Churn).To(Equal(100.0))
+		Expect(report.ChurnPercent).To(Equal(10.0))
+		Expect(report.AverageReach).To(Equal(400.0))
+		Expect(report.AverageErr).To(Equal(12.0))
+		Expect(report.ErrTrend).To(Equal(2.0))
+
+		Expect(offsets).To(Equal([]string{"0", "50"}))
+		Expect(report.PostsCount).To(Equal(52))
+		Expect(report.PostsPerDay).To(Equal(13.0))
+		Expect(report.TopPosts).To(HaveLen(3))
+		Expect(report.TopPosts[0].Views).To(Equal(49))
+	})
+
+	t.Run("Test API errors are returned", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+
+		respond(testServer, endpoints.ChannelsStat, map[string]string{"status": "error", "error": "channel not found"})
+
+		_, err := Report(context.Background(), ReportRequest{ChannelId: "@channel", From: from, To: to})
+		Expect(err).To(MatchError(ContainSubstring("analytics: stat")))
+	})
+}
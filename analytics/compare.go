@@ -0,0 +1,286 @@
+package analytics
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/channels"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Metric is a channel metric used for ranking.
+type Metric string
+
+const (
+	MetricSubscribers  Metric = "participants_count"
+	MetricAvgPostReach Metric = "avg_post_reach"
+	MetricErr          Metric = "err_percent"
+	MetricDailyReach   Metric = "daily_reach"
+	MetricCiIndex      Metric = "ci_index"
+	// MetricCPV ranks cheaper views higher, it only applies to candidates with a price.
+	MetricCPV Metric = "cpv"
+)
+
+// Weights of the metrics in the score, metrics without a weight are ignored.
+type Weights map[Metric]float64
+
+// DefaultWeights favours reach and engagement over the raw audience size.
+var DefaultWeights = Weights{
+	MetricSubscribers:  1,
+	MetricAvgPostReach: 2,
+	MetricErr:          2,
+	MetricDailyReach:   1,
+	MetricCiIndex:      1,
+}
+
+// Candidate is a channel to compare, Price is the cost of a placement, zero when unknown.
+type Candidate struct {
+	ChannelId string
+	Price     float64
+}
+
+// CompareRequest describes a comparison of candidates.
+type CompareRequest struct {
+	Candidates []Candidate
+	// Weights defaults to DefaultWeights.
+	Weights Weights
+	// Concurrency is the maximum number of requests in flight, defaults to 1.
+	Concurrency int
+}
+
+// ComparisonRow is a ranked candidate. Candidates that could not be fetched
+// carry Err and are ranked last.
+type ComparisonRow struct {
+	Rank      int
+	ChannelId string
+	Stat      tgstat.ChannelStatResponse
+	Price     float64
+	// CPV is the price per view of an average post, zero without a price or reach.
+	CPV float64
+	// Normalized holds the weighted metrics scaled to [0, 1] across the candidates.
+	Normalized map[Metric]float64
+	Score      float64
+	Err        error
+}
+
+// Comparison is the ranked table of candidates.
+type Comparison struct {
+	Weights Weights
+	Rows    []ComparisonRow
+}
+
+// Compare fetches the stat of every candidate and ranks them by weighted score.
+// When ctx is cancelled the comparison of the candidates fetched so far is
+// returned with ctx.Err(), the others carry the error in their row.
+func Compare(ctx context.Context, request CompareRequest) (*Comparison, error) {
+	ids := make([]string, len(request.Candidates))
+	for i, candidate := range request.Candidates {
+		ids[i] = candidate.ChannelId
+	}
+
+	stats, err := channels.StatMany(ctx, channels.ManyRequest{ChannelIds: ids, Concurrency: request.Concurrency})
+
+	rows := make([]ComparisonRow, len(stats))
+	for i, stat := range stats {
+		rows[i] = ComparisonRow{ChannelId: stat.ChannelId, Price: request.Candidates[i].Price, Err: stat.Err}
+		if stat.Err == nil {
+			rows[i].Stat = stat.Result.Response
+		}
+	}
+
+	weights := request.Weights
+	if weights == nil {
+		weights = DefaultWeights
+	}
+	return Rank(rows, weights), err
+}
+
+// Rank scores and orders rows that already hold their stats, so a comparison
+// can be recomputed with other weights without fetching again.
+func Rank(rows []ComparisonRow, weights Weights) *Comparison {
+	rows = append([]ComparisonRow(nil), rows...)
+	for i := range rows {
+		rows[i].CPV = 0
+		if rows[i].Err == nil && rows[i].Price > 0 && rows[i].Stat.AvgPostReach > 0 {
+			rows[i].CPV = rows[i].Price / float64(rows[i].Stat.AvgPostReach)
+		}
+	}
+
+	metrics := make([]Metric, 0, len(weights))
+	for metric := range weights {
+		metrics = append(metrics, metric)
+	}
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i] < metrics[j] })
+
+	// applied sums the weights of the metrics a row has, so an unpriced
+	// candidate is not penalised for the CPV weight it cannot earn
+	applied := make([]float64, len(rows))
+	for i := range rows {
+		rows[i].Normalized = make(map[Metric]float64, len(metrics))
+		rows[i].Score = 0
+	}
+
+	for _, metric := range metrics {
+		low, high := math.Inf(1), math.Inf(-1)
+		for _, row := range rows {
+			if value, ok := row.metric(metric); ok {
+				low, high = math.Min(low, value), math.Max(high, value)
+			}
+		}
+		for i := range rows {
+			value, ok := rows[i].metric(metric)
+			if !ok {
+				continue
+			}
+			normalized := 1.0
+			if high > low {
+				normalized = (value - low) / (high - low)
+			}
+			if metric == MetricCPV {
+				normalized = 1 - normalized
+			}
+			rows[i].Normalized[metric] = normalized
+			rows[i].Score += normalized * weights[metric]
+			applied[i] += weights[metric]
+		}
+	}
+
+	for i := range rows {
+		if applied[i] > 0 {
+			rows[i].Score /= applied[i]
+		}
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if (rows[i].Err == nil) != (rows[j].Err == nil) {
+			return rows[i].Err == nil
+		}
+		return rows[i].Score > rows[j].Score
+	})
+	for i := range rows {
+		rows[i].Rank = i + 1
+	}
+
+	return &Comparison{Weights: weights, Rows: rows}
+}
+
+func (r ComparisonRow) metric(metric Metric) (float64, bool) {
+	if r.Err != nil {
+		return 0, false
+	}
+	switch metric {
+	case MetricSubscribers:
+		return float64(r.Stat.ParticipantsCount), true
+	case MetricAvgPostReach:
+		return float64(r.Stat.AvgPostReach), true
+	case MetricErr:
+		return r.Stat.ErrPercent, true
+	case MetricDailyReach:
+		return float64(r.Stat.DailyReach), true
+	case MetricCiIndex:
+		return r.Stat.CiIndex, true
+	case MetricCPV:
+		return r.CPV, r.CPV > 0
+	}
+	return 0, false
+}
+
+var comparisonColumns = []string{
+	"rank", "channel", "title", "participants_count", "avg_post_reach", "err_percent",
+	"daily_reach", "ci_index", "price", "cpv", "score", "error",
+}
+
+func (r ComparisonRow) cells() []string {
+	errText := ""
+	if r.Err != nil {
+		errText = r.Err.Error()
+	}
+	return []string{
+		strconv.Itoa(r.Rank),
+		r.ChannelId,
+		r.Stat.Title,
+		strconv.Itoa(r.Stat.ParticipantsCount),
+		strconv.Itoa(r.Stat.AvgPostReach),
+		formatFloat(r.Stat.ErrPercent),
+		strconv.Itoa(r.Stat.DailyReach),
+		formatFloat(r.Stat.CiIndex),
+		formatFloat(r.Price),
+		formatFloat(r.CPV),
+		strconv.FormatFloat(r.Score, 'f', 4, 64),
+		errText,
+	}
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// WriteJSON writes the rows as a JSON array.
+func (c *Comparison) WriteJSON(w io.Writer) error {
+	type jsonRow struct {
+		Rank       int                        `json:"rank"`
+		ChannelId  string                     `json:"channel"`
+		Stat       tgstat.ChannelStatResponse `json:"stat"`
+		Price      float64                    `json:"price,omitempty"`
+		CPV        float64                    `json:"cpv,omitempty"`
+		Normalized map[Metric]float64         `json:"normalized,omitempty"`
+		Score      float64                    `json:"score"`
+		Error      string                     `json:"error,omitempty"`
+	}
+
+	rows := make([]jsonRow, len(c.Rows))
+	for i, r := range c.Rows {
+		rows[i] = jsonRow{r.Rank, r.ChannelId, r.Stat, r.Price, r.CPV, r.Normalized, r.Score, ""}
+		if r.Err != nil {
+			rows[i].Error = r.Err.Error()
+		}
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
+
+// WriteCSV writes the rows as CSV with a header line.
+func (c *Comparison) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(comparisonColumns); err != nil {
+		return err
+	}
+	for _, row := range c.Rows {
+		if err := writer.Write(row.cells()); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// WriteMarkdown writes the rows as a Markdown table.
+func (c *Comparison) WriteMarkdown(w io.Writer) error {
+	separators := make([]string, len(comparisonColumns))
+	for i := range separators {
+		separators[i] = "---"
+	}
+
+	lines := []string{markdownLine(comparisonColumns), markdownLine(separators)}
+	for _, row := range c.Rows {
+		lines = append(lines, markdownLine(row.cells()))
+	}
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+func markdownLine(cells []string) string {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = strings.ReplaceAll(strings.ReplaceAll(cell, "|", `\|`), "\n", " ")
+	}
+	return "| " + strings.Join(escaped, " | ") + " |"
+}
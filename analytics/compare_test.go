@@ -0,0 +1,134 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	RegisterTestingT(t)
+	stats := map[string]tgstat.ChannelStatResponse{
+		"@big":   {Title: "Big", ParticipantsCount: 100000, AvgPostReach: 10000, ErrPercent: 10, DailyReach: 50000, CiIndex: 5},
+		"@small": {Title: "Small | niche", ParticipantsCount: 10000, AvgPostReach: 4000, ErrPercent: 40, DailyReach: 8000, CiIndex: 1},
+	}
+
+	prepare := func() server.Server {
+		testServer := server.NewServer()
+		prepareClient(testServer.URL)
+		testServer.Mux.HandleFunc(endpoints.ChannelsStat, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			stat, ok := stats[r.URL.Query().Get("channelId")]
+			if !ok {
+				json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": "channel not found"}) //nolint
+				return
+			}
+			json.NewEncoder(w).Encode(tgstat.ChannelStatResult{Status: "ok", Response: stat}) //nolint
+		})
+		return testServer
+	}
+
+	t.Run("Test candidates are ranked by weighted score", func(t *testing.T) {
+		testServer := prepare()
+		defer testServer.Teardown()
+
+		comparison, err := Compare(context.Background(), CompareRequest{
+			Candidates:  []Candidate{{ChannelId: "@missing"}, {ChannelId: "@small", Price: 400}, {ChannelId: "@big", Price: 5000}},
+			Concurrency: 3,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(comparison.Rows).To(HaveLen(3))
+		Expect(comparison.Rows[0].ChannelId).To(Equal("@big"))
+		Expect(comparison.Rows[0].Score).To(BeNumerically("~", 5.0/7, 1e-9))
+		Expect(comparison.Rows[0].CPV).To(Equal(0.5))
+		Expect(comparison.Rows[1].ChannelId).To(Equal("@small"))
+		Expect(comparison.Rows[1].Normalized[MetricErr]).To(Equal(1.0))
+		Expect(comparison.Rows[1].CPV).To(Equal(0.1))
+		Expect(comparison.Rows[2].ChannelId).To(Equal("@missing"))
+		Expect(comparison.Rows[2].Err).To(HaveOccurred())
+		Expect(comparison.Rows[2].Rank).To(Equal(3))
+
+		reranked := Rank(comparison.Rows, Weights{MetricErr: 1, MetricCPV: 1})
+		Expect(reranked.Rows[0].ChannelId).To(Equal("@small"))
+		Expect(reranked.Rows[0].Score).To(Equal(1.0))
+	})
+
+	t.Run("Test unpriced candidates are scored by the metrics they have", func(t *testing.T) {
+		comparison := Rank([]ComparisonRow{
+			{ChannelId: "@free", Stat: tgstat.ChannelStatResponse{ErrPercent: 40, AvgPostReach: 1000}},
+			{ChannelId: "@cheap", Price: 100, Stat: tgstat.ChannelStatResponse{ErrPercent: 10, AvgPostReach: 1000}},
+			{ChannelId: "@dear", Price: 400, Stat: tgstat.ChannelStatResponse{ErrPercent: 20, AvgPostReach: 1000}},
+		}, Weights{MetricErr: 1, MetricCPV: 1})
+
+		Expect(comparison.Rows[0].ChannelId).To(Equal("@free"))
+		Expect(comparison.Rows[0].Score).To(Equal(1.0))
+		Expect(comparison.Rows[0].Normalized).ToNot(HaveKey(MetricCPV))
+		Expect(comparison.Rows[1].ChannelId).To(Equal("@cheap"))
+		Expect(comparison.Rows[1].Score).To(Equal(0.5))
+		Expect(comparison.Rows[2].Score).To(BeNumerically("~", 1.0/6, 1e-9))
+	})
+
+	t.Run("Test cancellation keeps the candidates fetched", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		testServer.Mux.HandleFunc(endpoints.ChannelsStat, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("channelId") == "@slow" {
+				cancel()
+				<-r.Context().Done()
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tgstat.ChannelStatResult{Status: "ok", Response: stats["@big"]}) //nolint
+		})
+
+		comparison, err := Compare(ctx, CompareRequest{
+			Candidates: []Candidate{{ChannelId: "@big"}, {ChannelId: "@slow"}, {ChannelId: "@small"}},
+		})
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(comparison.Rows).To(HaveLen(3))
+		Expect(comparison.Rows[0].ChannelId).To(Equal("@big"))
+		Expect(comparison.Rows[0].Err).ToNot(HaveOccurred())
+		Expect(comparison.Rows[2].Err).To(MatchError(context.Canceled))
+	})
+
+	t.Run("Test rendering", func(t *testing.T) {
+		testServer := prepare()
+		defer testServer.Teardown()
+
+		comparison, err := Compare(context.Background(), CompareRequest{
+			Candidates: []Candidate{{ChannelId: "@small", Price: 400}, {ChannelId: "@big"}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		var out bytes.Buffer
+		Expect(comparison.WriteCSV(&out)).To(Succeed())
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines).To(HaveLen(3))
+		Expect(lines[0]).To(HavePrefix("rank,channel,title,participants_count"))
+		Expect(lines[1]).To(HavePrefix("1,@big,Big,100000,10000,10,50000,5,0,0,"))
+
+		out.Reset()
+		Expect(comparison.WriteMarkdown(&out)).To(Succeed())
+		Expect(out.String()).To(ContainSubstring(`| 2 | @small | Small \| niche |`))
+		Expect(out.String()).To(ContainSubstring("| --- |"))
+
+		out.Reset()
+		Expect(comparison.WriteJSON(&out)).To(Succeed())
+		var rows []map[string]interface{}
+		Expect(json.Unmarshal(out.Bytes(), &rows)).To(Succeed())
+		Expect(rows[1]["channel"]).To(Equal("@small"))
+		Expect(rows[1]["cpv"]).To(Equal(0.1))
+		Expect(rows[0]).ToNot(HaveKey("error"))
+	})
+}
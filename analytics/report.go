@@ -0,0 +1,246 @@
+// Package analytics computes channel metrics on top of the API clients.
+package analytics
+
+import (
+	"context"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/channels"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultTopPosts is the number of top posts kept in a report.
+	DefaultTopPosts = 10
+	// DefaultMaxPosts limits the number of posts fetched for a report.
+	DefaultMaxPosts = 1000
+
+	postsPageSize = 50
+)
+
+// ReportRequest describes the channel and the period of a report.
+type ReportRequest struct {
+	ChannelId string
+	From      time.Time
+	To        time.Time
+	// Group of the series, defaults to tgstat.GroupDay. tgstat.GroupHour is
+	// rejected: views, average reach and ERR are not grouped by hour.
+	Group tgstat.Group
+	// TopPosts defaults to DefaultTopPosts.
+	TopPosts int
+	// MaxPosts defaults to DefaultMaxPosts.
+	MaxPosts int
+}
+
+// ChannelReport holds the growth and engagement metrics of a channel over a period.
+type ChannelReport struct {
+	ChannelId string
+	From      time.Time
+	To        time.Time
+	Stat      tgstat.ChannelStatResponse
+
+	Subscribers tgstat.Series
+	Views       tgstat.Series
+	AvgReach    tgstat.Series
+	Err         tgstat.Series
+
+	// SubscribersGrowth is the absolute change of subscribers over the period.
+	SubscribersGrowth float64
+	// SubscribersGrowthPercent is the relative change, nil when the period starts from zero.
+	SubscribersGrowthPercent *float64
+	// Churn estimates the lost subscribers as the sum of the negative changes
+	// between periods, so it is a lower bound of the real churn.
+	Churn float64
+	// ChurnPercent is Churn relative to the subscribers at the start of the period.
+	ChurnPercent float64
+
+	// AverageReach is the mean of the average post reach series.
+	AverageReach float64
+	// AverageErr is the mean of the ERR series.
+	AverageErr float64
+	// ErrTrend is the slope of the ERR series in percentage points per period.
+	ErrTrend float64
+
+	PostsCount  int
+	PostsPerDay float64
+	TopPosts    []tgstat.Post
+}
+
+// Report fetches the series and posts of a channel and computes its report.
+func Report(ctx context.Context, request ReportRequest) (*ChannelReport, error) {
+	if request.ChannelId == "" {
+		return nil, fmt.Errorf("analytics: channel id is required")
+	}
+	if !request.To.After(request.From) {
+		return nil, fmt.Errorf("analytics: period end must be after its start")
+	}
+	if request.Group == "" {
+		request.Group = tgstat.GroupDay
+	}
+	if !request.Group.ValidFor(endpoints.ChannelsViews) {
+		return nil, fmt.Errorf("analytics: group %q is not supported, use day, week or month", request.Group)
+	}
+	if request.TopPosts <= 0 {
+		request.TopPosts = DefaultTopPosts
+	}
+	if request.MaxPosts <= 0 {
+		request.MaxPosts = DefaultMaxPosts
+	}
+
+	report := &ChannelReport{ChannelId: request.ChannelId, From: request.From, To: request.To}
+
+	stat, _, err := channels.Stat(ctx, request.ChannelId)
+	if err != nil {
+		return nil, fmt.Errorf("analytics: stat: %w", err)
+	}
+	report.Stat = stat.Response
+
+	startDate := strconv.FormatInt(request.From.Unix(), 10)
+	endDate := strconv.FormatInt(request.To.Unix(), 10)
+	period := channels.ChannelViewsRequest{
+		ChannelId: request.ChannelId,
+		StartDate: &startDate,
+		EndDate:   &endDate,
+		Group:     request.Group,
+	}
+
+	subscribers, _, err := channels.Subscribers(ctx, channels.ChannelSubscribersRequest(period))
+	if err != nil {
+		return nil, fmt.Errorf("analytics: subscribers: %w", err)
+	}
+	if report.Subscribers, err = subscribers.Series(request.Group); err != nil {
+		return nil, err
+	}
+
+	views, _, err := channels.Views(ctx, period)
+	if err != nil {
+		return nil, fmt.Errorf("analytics: views: %w", err)
+	}
+	if report.Views, err = views.Series(request.Group); err != nil {
+		return nil, err
+	}
+
+	reach, _, err := channels.AvgPostsReach(ctx, period)
+	if err != nil {
+		return nil, fmt.Errorf("analytics: average reach: %w", err)
+	}
+	if report.AvgReach, err = reach.Series(request.Group); err != nil {
+		return nil, err
+	}
+
+	errRate, _, err := channels.Err(ctx, period)
+	if err != nil {
+		return nil, fmt.Errorf("analytics: err: %w", err)
+	}
+	if report.Err, err = errRate.Series(request.Group); err != nil {
+		return nil, err
+	}
+
+	posts, err := fetchPosts(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("analytics: posts: %w", err)
+	}
+
+	report.compute(posts, request.TopPosts)
+	return report, nil
+}
+
+func fetchPosts(ctx context.Context, request ReportRequest) ([]tgstat.Post, error) {
+	startTime := strconv.FormatInt(request.From.Unix(), 10)
+	endTime := strconv.FormatInt(request.To.Unix(), 10)
+	limit := uint64(postsPageSize)
+
+	var posts []tgstat.Post
+	for offset := uint64(0); len(posts) < request.MaxPosts; offset += limit {
+		page, _, err := channels.Posts(ctx, channels.PostsRequest{
+			ChannelId: request.ChannelId,
+			Limit:     &limit,
+			Offset:    &offset,
+			StartTime: &startTime,
+			EndTime:   &endTime,
+		})
+		if err != nil {
+			return nil, err
+		}
+		posts = append(posts, page.Posts()...)
+		if len(page.Response.Items) < postsPageSize {
+			break
+		}
+	}
+	if len(posts) > request.MaxPosts {
+		posts = posts[:request.MaxPosts]
+	}
+	return posts, nil
+}
+
+// compute fills the metrics derived from the fetched series and posts.
+func (r *ChannelReport) compute(posts []tgstat.Post, top int) {
+	r.SubscribersGrowth = r.Subscribers.TotalDelta()
+	if growth, ok := r.Subscribers.TotalGrowth(); ok {
+		r.SubscribersGrowthPercent = &growth
+	}
+	for _, delta := range r.Subscribers.Deltas().Values() {
+		if delta < 0 {
+			r.Churn -= delta
+		}
+	}
+	if len(r.Subscribers.Points) > 0 && r.Subscribers.Points[0].Value > 0 {
+		r.ChurnPercent = r.Churn / r.Subscribers.Points[0].Value * 100
+	}
+
+	r.AverageReach = mean(r.AvgReach.Values())
+	if len(r.AvgReach.Points) == 0 {
+		r.AverageReach = float64(r.Stat.AvgPostReach)
+	}
+	r.AverageErr = mean(r.Err.Values())
+	if len(r.Err.Points) == 0 {
+		r.AverageErr = r.Stat.ErrPercent
+	}
+	r.ErrTrend = slope(r.Err.Values())
+
+	r.PostsCount = len(posts)
+	if days := r.To.Sub(r.From).Hours() / 24; days > 0 {
+		r.PostsPerDay = float64(len(posts)) / days
+	}
+
+	r.TopPosts = append([]tgstat.Post(nil), posts...)
+	sort.SliceStable(r.TopPosts, func(i, j int) bool {
+		return r.TopPosts[i].Views > r.TopPosts[j].Views
+	})
+	if len(r.TopPosts) > top {
+		r.TopPosts = r.TopPosts[:top]
+	}
+}
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+// slope is the least squares slope of values over their indexes.
+func slope(values []float64) float64 {
+	n := float64(len(values))
+	if n < 2 {
+		return 0
+	}
+	meanX, meanY := (n-1)/2, mean(values)
+	var num, den float64
+	for i, v := range values {
+		dx := float64(i) - meanX
+		num += dx * (v - meanY)
+		den += dx * dx
+	}
+	if den == 0 {
+		return 0
+	}
+	return num / den
+}
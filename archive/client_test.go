@@ -0,0 +1,220 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func prepareClient(URL string) {
+	tgstat.Token = "token"
+	tgstat.WithEndpoint(URL)
+}
+
+// fakeChannel serves channels/posts like the API: newest first, filtered by time and paged.
+type fakeChannel struct {
+	mu       sync.Mutex
+	posts    []tgstat.ChannelPostsResponseItem
+	requests []map[string]string
+	failAt   string
+}
+
+func (f *fakeChannel) handle(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query()
+	f.requests = append(f.requests, map[string]string{
+		"offset":      query.Get("offset"),
+		"startTime":   query.Get("startTime"),
+		"hideDeleted": query.Get("hideDeleted"),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	if f.failAt != "" && query.Get("offset") == f.failAt {
+		f.failAt = ""
+		json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": "temporary failure"}) //nolint
+		return
+	}
+
+	start, _ := strconv.Atoi(query.Get("startTime"))
+	end, _ := strconv.Atoi(query.Get("endTime"))
+	var items []tgstat.ChannelPostsResponseItem
+	for _, p := range f.posts {
+		if p.Date >= start && p.Date <= end {
+			items = append(items, p)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Date > items[j].Date })
+
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	items = items[min(offset, len(items)):min(offset+limit, len(items))]
+
+	json.NewEncoder(w).Encode(tgstat.ChannelPostsResult{ //nolint
+		Status: "ok",
+		Response: tgstat.ChannelPostsResponse{
+			Channel: tgstat.Channel{ID: 1, Title: "Archive"},
+			Items:   items,
+		},
+	})
+}
+
+func TestSyncer(t *testing.T) {
+	RegisterTestingT(t)
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	hour := int(time.Hour / time.Second)
+
+	newFake := func() *fakeChannel {
+		fake := &fakeChannel{}
+		for i := 0; i < 120; i++ {
+			fake.posts = append(fake.posts, tgstat.ChannelPostsResponseItem{ID: int64(i + 1), Date: int(now.Unix()) - (120-i)*hour, Views: 10})
+		}
+		return fake
+	}
+
+	prepare := func(fake *fakeChannel) (*Syncer, server.Server) {
+		testServer := server.NewServer()
+		prepareClient(testServer.URL)
+		testServer.Mux.HandleFunc(endpoints.ChannelsPosts, fake.handle)
+		store, err := NewFileStore(t.TempDir())
+		Expect(err).ToNot(HaveOccurred())
+		return &Syncer{Store: store, Now: func() time.Time { return now }}, testServer
+	}
+
+	t.Run("Test incremental sync refreshes recent posts", func(t *testing.T) {
+		fake := newFake()
+		syncer, testServer := prepare(fake)
+		defer testServer.Teardown()
+
+		results, err := syncer.Sync(context.Background(), "@archive")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results[0].Err).ToNot(HaveOccurred())
+		Expect(results[0].Added).To(Equal(120))
+		Expect(fake.requests).To(HaveLen(3))
+		Expect(fake.requests[0]["startTime"]).To(BeEmpty())
+		Expect(fake.requests[0]["hideDeleted"]).To(Equal("false"))
+
+		cursor, _ := syncer.Store.Cursor(context.Background(), "@archive")
+		Expect(cursor.InProgress()).To(BeFalse())
+		Expect(cursor.LastDate).To(Equal(now.Unix() - int64(hour)))
+		channel, _ := syncer.Store.Channel(context.Background(), "@archive")
+		Expect(channel.Title).To(Equal("Archive"))
+
+		now = now.Add(2 * time.Hour)
+		fake.posts[119].Views = 500
+		fake.posts[118].IsDeleted = 1
+		fake.posts = append(fake.posts, tgstat.ChannelPostsResponseItem{ID: 121, Date: int(now.Unix()) - hour, Views: 1})
+		fake.requests = nil
+
+		stats, err := syncer.SyncChannel(context.Background(), "@archive")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stats).To(Equal(UpsertStats{Added: 1, Updated: 2, Deleted: 1}))
+		Expect(fake.requests).To(HaveLen(2))
+		Expect(fake.requests[0]["startTime"]).To(Equal(strconv.FormatInt(now.Add(-DefaultRefreshWindow).Unix(), 10)))
+
+		posts, _ := syncer.Store.Posts(context.Background(), "@archive")
+		Expect(posts).To(HaveLen(121))
+		Expect(posts[0].ID).To(Equal(int64(121)))
+		Expect(posts[1].Views).To(Equal(500))
+		Expect(posts[2].IsDeleted).To(BeTrue())
+	})
+
+	t.Run("Test interrupted sync resumes from the saved offset", func(t *testing.T) {
+		fake := newFake()
+		fake.failAt = "50"
+		syncer, testServer := prepare(fake)
+		defer testServer.Teardown()
+
+		_, err := syncer.SyncChannel(context.Background(), "@archive")
+		Expect(err).To(MatchError(ContainSubstring("temporary failure")))
+		cursor, _ := syncer.Store.Cursor(context.Background(), "@archive")
+		Expect(cursor.InProgress()).To(BeTrue())
+		Expect(cursor.Offset).To(Equal(uint64(50)))
+
+		fake.requests = nil
+		stats, err := syncer.SyncChannel(context.Background(), "@archive")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stats.Added).To(Equal(70))
+		Expect(fake.requests[0]["offset"]).To(Equal("50"))
+
+		posts, _ := syncer.Store.Posts(context.Background(), "@archive")
+		Expect(posts).To(HaveLen(120))
+	})
+
+	t.Run("Test first sync can be limited", func(t *testing.T) {
+		fake := newFake()
+		syncer, testServer := prepare(fake)
+		defer testServer.Teardown()
+		syncer.Since = 24 * time.Hour
+
+		stats, err := syncer.SyncChannel(context.Background(), "@archive")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stats.Added).To(Equal(24))
+	})
+}
+
+func TestFileStore(t *testing.T) {
+	RegisterTestingT(t)
+	ctx := context.Background()
+
+	t.Run("Test upserts append changed posts only", func(t *testing.T) {
+		dir := t.TempDir()
+		store, err := NewFileStore(dir)
+		Expect(err).ToNot(HaveOccurred())
+
+		stats, err := store.UpsertPosts(ctx, "@c", []tgstat.Post{{ID: 1, Date: 10}, {ID: 2, Date: 20}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stats).To(Equal(UpsertStats{Added: 2}))
+		Expect(store.SaveCursor(ctx, "@c", Cursor{LastDate: 20})).To(Succeed())
+
+		stats, err = store.UpsertPosts(ctx, "@c", []tgstat.Post{{ID: 1, Date: 10}, {ID: 2, Date: 20, IsDeleted: true}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(stats).To(Equal(UpsertStats{Updated: 1, Deleted: 1}))
+
+		log, err := os.ReadFile(filepath.Join(dir, "@c.posts.ndjson"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Count(string(log), "\n")).To(Equal(3))
+
+		// a new store reads the log back, cutting a partial last line
+		Expect(os.WriteFile(filepath.Join(dir, "@c.posts.ndjson"), append(log, `{"id":3`...), 0o644)).To(Succeed())
+		store, _ = NewFileStore(dir)
+		posts, err := store.Posts(ctx, "@c")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(posts).To(Equal([]tgstat.Post{{ID: 2, Date: 20, IsDeleted: true}, {ID: 1, Date: 10}}))
+		stats, _ = store.UpsertPosts(ctx, "@c", []tgstat.Post{{ID: 1, Date: 10}})
+		Expect(stats).To(BeZero())
+		cursor, _ := store.Cursor(ctx, "@c")
+		Expect(cursor.LastDate).To(Equal(int64(20)))
+	})
+
+	t.Run("Test log is compacted", func(t *testing.T) {
+		dir := t.TempDir()
+		store, _ := NewFileStore(dir)
+		for views := 0; views < 3; views++ {
+			page := make([]tgstat.Post, 500)
+			for i := range page {
+				page[i] = tgstat.Post{ID: int64(i), Views: views}
+			}
+			_, err := store.UpsertPosts(ctx, "@c", page)
+			Expect(err).ToNot(HaveOccurred())
+		}
+		log, _ := os.ReadFile(filepath.Join(dir, "@c.posts.ndjson"))
+		Expect(strings.Count(string(log), "\n")).To(Equal(500))
+		posts, _ := store.Posts(ctx, "@c")
+		Expect(posts).To(HaveLen(500))
+		Expect(posts[0].Views).To(Equal(2))
+	})
+
+}
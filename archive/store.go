@@ -0,0 +1,406 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"hash/fnv"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cursor is the sync progress of a channel.
+type Cursor struct {
+	// LastDate is the date of the newest post stored.
+	LastDate int64 `json:"last_date"`
+	// LastSync is the end of the last completed sync.
+	LastSync time.Time `json:"last_sync"`
+
+	// The window of an unfinished sync, resumed by the next one.
+	WindowStart int64  `json:"window_start,omitempty"`
+	WindowEnd   int64  `json:"window_end,omitempty"`
+	Offset      uint64 `json:"offset,omitempty"`
+	MaxDate     int64  `json:"max_date,omitempty"`
+}
+
+// InProgress reports whether a sync of the channel was interrupted.
+func (c Cursor) InProgress() bool {
+	return c.WindowEnd != 0
+}
+
+// UpsertStats counts the changes made by Store.UpsertPosts.
+type UpsertStats struct {
+	Added   int
+	Updated int
+	// Deleted counts the posts newly marked as deleted.
+	Deleted int
+}
+
+func (s *UpsertStats) add(other UpsertStats) {
+	s.Added += other.Added
+	s.Updated += other.Updated
+	s.Deleted += other.Deleted
+}
+
+// Store persists channels, their posts and sync cursors.
+// Implementations must be safe for concurrent use by different channels.
+type Store interface {
+	Cursor(ctx context.Context, channelId string) (Cursor, error)
+	SaveCursor(ctx context.Context, channelId string, cursor Cursor) error
+	SaveChannel(ctx context.Context, channelId string, channel tgstat.ChannelSummary) error
+	Channel(ctx context.Context, channelId string) (tgstat.ChannelSummary, error)
+	// UpsertPosts inserts new posts and replaces stored ones with the same ID.
+	UpsertPosts(ctx context.Context, channelId string, posts []tgstat.Post) (UpsertStats, error)
+	// Posts returns the stored posts of a channel, newest first.
+	Posts(ctx context.Context, channelId string) ([]tgstat.Post, error)
+}
+
+// compactMinLines is the size under which a post log is never compacted.
+const compactMinLines = 1000
+
+// FileStore keeps two files per channel in a directory: a JSON file with the
+// channel and its cursor, replaced atomically, and an append-only NDJSON log
+// of its posts. UpsertPosts appends the new and changed posts only, the log is
+// compacted once it holds more than twice as many lines as posts.
+type FileStore struct {
+	dir     string
+	mu      sync.Mutex
+	locks   map[string]*sync.Mutex
+	indexes map[string]*postIndex
+}
+
+type channelFile struct {
+	Channel tgstat.ChannelSummary `json:"channel"`
+	Cursor  Cursor                `json:"cursor"`
+}
+
+// postIndex is what UpsertPosts needs to know of the logged posts of a channel.
+type postIndex struct {
+	// sums maps post IDs to the hash of their latest JSON encoding.
+	sums    map[int64]uint64
+	deleted map[int64]bool
+	lines   int
+}
+
+// NewFileStore returns a store in dir, creating it when needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("archive: %w", err)
+	}
+	return &FileStore{dir: dir, locks: make(map[string]*sync.Mutex), indexes: make(map[string]*postIndex)}, nil
+}
+
+func (f *FileStore) Cursor(_ context.Context, channelId string) (Cursor, error) {
+	var cursor Cursor
+	err := f.read(channelId, func(file *channelFile) { cursor = file.Cursor })
+	return cursor, err
+}
+
+func (f *FileStore) SaveCursor(_ context.Context, channelId string, cursor Cursor) error {
+	return f.update(channelId, func(file *channelFile) { file.Cursor = cursor })
+}
+
+func (f *FileStore) SaveChannel(_ context.Context, channelId string, channel tgstat.ChannelSummary) error {
+	return f.update(channelId, func(file *channelFile) { file.Channel = channel })
+}
+
+func (f *FileStore) Channel(_ context.Context, channelId string) (tgstat.ChannelSummary, error) {
+	var channel tgstat.ChannelSummary
+	err := f.read(channelId, func(file *channelFile) { channel = file.Channel })
+	return channel, err
+}
+
+func (f *FileStore) UpsertPosts(_ context.Context, channelId string, posts []tgstat.Post) (UpsertStats, error) {
+	lock := f.lock(channelId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var stats UpsertStats
+	index, err := f.index(channelId)
+	if err != nil {
+		return stats, err
+	}
+
+	var lines bytes.Buffer
+	// changed and deleted hold the posts of this call that are logged
+	changed := make(map[int64]uint64)
+	deleted := make(map[int64]bool)
+	for _, p := range posts {
+		data, err := json.Marshal(p)
+		if err != nil {
+			return stats, fmt.Errorf("archive: %w", err)
+		}
+		hash := fnv.New64a()
+		hash.Write(data) //nolint
+		sum := hash.Sum64()
+
+		previous, ok := changed[p.ID]
+		wasDeleted := deleted[p.ID]
+		if !ok {
+			previous, ok = index.sums[p.ID]
+			wasDeleted = index.deleted[p.ID]
+		}
+		switch {
+		case !ok:
+			stats.Added++
+		case previous == sum:
+			continue
+		default:
+			stats.Updated++
+			if p.IsDeleted && !wasDeleted {
+				stats.Deleted++
+			}
+		}
+		changed[p.ID], deleted[p.ID] = sum, p.IsDeleted
+		lines.Write(data)
+		lines.WriteByte('\n')
+	}
+	if lines.Len() == 0 {
+		return stats, nil
+	}
+
+	log, err := os.OpenFile(f.logPath(channelId), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return UpsertStats{}, fmt.Errorf("archive: %w", err)
+	}
+	if _, err := log.Write(lines.Bytes()); err != nil {
+		log.Close() //nolint
+		// the partial line is dropped by the next load
+		f.mu.Lock()
+		delete(f.indexes, channelId)
+		f.mu.Unlock()
+		return UpsertStats{}, fmt.Errorf("archive: %w", err)
+	}
+	if err := log.Close(); err != nil {
+		return UpsertStats{}, fmt.Errorf("archive: %w", err)
+	}
+
+	for id, sum := range changed {
+		index.sums[id], index.deleted[id] = sum, deleted[id]
+	}
+	index.lines += strings.Count(lines.String(), "\n")
+	if index.lines > compactMinLines && index.lines > 2*len(index.sums) {
+		if err := f.compact(channelId, index); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+func (f *FileStore) Posts(_ context.Context, channelId string) ([]tgstat.Post, error) {
+	lock := f.lock(channelId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err := f.index(channelId); err != nil {
+		return nil, err
+	}
+	return f.replay(channelId)
+}
+
+func (f *FileStore) lock(channelId string) *sync.Mutex {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	lock, ok := f.locks[channelId]
+	if !ok {
+		lock = &sync.Mutex{}
+		f.locks[channelId] = lock
+	}
+	return lock
+}
+
+func (f *FileStore) path(channelId string) string {
+	return filepath.Join(f.dir, url.PathEscape(channelId)+".json")
+}
+
+func (f *FileStore) logPath(channelId string) string {
+	return filepath.Join(f.dir, url.PathEscape(channelId)+".posts.ndjson")
+}
+
+func (f *FileStore) read(channelId string, fn func(*channelFile)) error {
+	lock := f.lock(channelId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	file, err := f.load(channelId)
+	if err != nil {
+		return err
+	}
+	fn(&file)
+	return nil
+}
+
+func (f *FileStore) update(channelId string, fn func(*channelFile)) error {
+	lock := f.lock(channelId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	file, err := f.load(channelId)
+	if err != nil {
+		return err
+	}
+	fn(&file)
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	return writeFile(f.dir, f.path(channelId), data)
+}
+
+func (f *FileStore) load(channelId string) (channelFile, error) {
+	var file channelFile
+	data, err := os.ReadFile(f.path(channelId))
+	if errors.Is(err, os.ErrNotExist) {
+		return file, nil
+	}
+	if err != nil {
+		return file, fmt.Errorf("archive: %w", err)
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return file, fmt.Errorf("archive: %s: %w", channelId, err)
+	}
+	return file, nil
+}
+
+// index returns the post index of a channel, reading its log on first use.
+// The channel must be locked.
+func (f *FileStore) index(channelId string) (*postIndex, error) {
+	f.mu.Lock()
+	index, ok := f.indexes[channelId]
+	f.mu.Unlock()
+	if ok {
+		return index, nil
+	}
+
+	index = &postIndex{sums: make(map[int64]uint64), deleted: make(map[int64]bool)}
+	err := f.scan(channelId, func(line []byte, p tgstat.Post) {
+		hash := fnv.New64a()
+		hash.Write(line) //nolint
+		index.sums[p.ID] = hash.Sum64()
+		index.deleted[p.ID] = p.IsDeleted
+		index.lines++
+	})
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	f.indexes[channelId] = index
+	f.mu.Unlock()
+	return index, nil
+}
+
+// scan calls fn with every line of the post log of a channel. A partial last
+// line, left by an interrupted append, is cut from the log.
+func (f *FileStore) scan(channelId string, fn func(line []byte, p tgstat.Post)) error {
+	log, err := os.OpenFile(f.logPath(channelId), os.O_RDWR, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	defer log.Close() //nolint
+
+	reader := bufio.NewReader(log)
+	var offset int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) != 0 {
+				if err := log.Truncate(offset); err != nil {
+					return fmt.Errorf("archive: %w", err)
+				}
+			}
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("archive: %w", err)
+		}
+		var p tgstat.Post
+		if err := json.Unmarshal(line, &p); err != nil {
+			return fmt.Errorf("archive: %s: offset %d: %w", channelId, offset, err)
+		}
+		fn(line[:len(line)-1], p)
+		offset += int64(len(line))
+	}
+}
+
+// replay returns the latest version of every logged post, newest first.
+func (f *FileStore) replay(channelId string) ([]tgstat.Post, error) {
+	index := make(map[int64]int)
+	var posts []tgstat.Post
+	err := f.scan(channelId, func(_ []byte, p tgstat.Post) {
+		if i, ok := index[p.ID]; ok {
+			posts[i] = p
+			return
+		}
+		index[p.ID] = len(posts)
+		posts = append(posts, p)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(posts, func(i, j int) bool {
+		if posts[i].Date != posts[j].Date {
+			return posts[i].Date > posts[j].Date
+		}
+		return posts[i].ID > posts[j].ID
+	})
+	return posts, nil
+}
+
+// compact rewrites the post log with the latest version of every post.
+func (f *FileStore) compact(channelId string, index *postIndex) error {
+	posts, err := f.replay(channelId)
+	if err != nil {
+		return err
+	}
+	if err := f.writeLog(channelId, posts); err != nil {
+		return err
+	}
+	index.lines = len(posts)
+	return nil
+}
+
+// writeLog replaces the post log of a channel.
+func (f *FileStore) writeLog(channelId string, posts []tgstat.Post) error {
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	for _, p := range posts {
+		if err := encoder.Encode(p); err != nil {
+			return fmt.Errorf("archive: %w", err)
+		}
+	}
+	return writeFile(f.dir, f.logPath(channelId), data.Bytes())
+}
+
+// writeFile replaces path atomically.
+func writeFile(dir, path string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint
+		return fmt.Errorf("archive: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	return nil
+}
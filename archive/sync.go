@@ -0,0 +1,178 @@
+// Package archive mirrors channel posts into a local store and keeps it up to
+// date incrementally.
+//
+// Each sync fetches the posts published since the newest stored one, going back
+// RefreshWindow to update the views and deletion marks of recent posts. Progress
+// is saved after every page, so an interrupted sync resumes where it stopped.
+//
+// FileStore is the bundled Store, other backends (e.g. SQLite) implement Store.
+package archive
+
+import (
+	"context"
+	"errors"
+	"github.com/helios-ag/tgstat-go/channels"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRefreshWindow is how far back posts are refetched to update their views.
+	DefaultRefreshWindow = 72 * time.Hour
+
+	pageSize = 50
+)
+
+// Syncer keeps the posts of channels in Store up to date.
+type Syncer struct {
+	Store Store
+	// RefreshWindow defaults to DefaultRefreshWindow.
+	RefreshWindow time.Duration
+	// Since limits the first sync of a channel, zero fetches the whole history.
+	Since time.Duration
+	// Concurrency is the number of channels synced at once, defaults to 1.
+	Concurrency int
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// Result is the outcome of syncing a channel.
+type Result struct {
+	ChannelId string
+	UpsertStats
+	Err error
+}
+
+// Sync syncs every channel, results are in the order of channelIds.
+// It returns ctx.Err() when cancelled, interrupted channels resume on the next call.
+func (s *Syncer) Sync(ctx context.Context, channelIds ...string) ([]Result, error) {
+	results := make([]Result, len(channelIds))
+	concurrency := s.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, concurrency)
+	for i, channelId := range channelIds {
+		results[i].ChannelId = channelId
+		select {
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i].UpsertStats, results[i].Err = s.SyncChannel(ctx, channelId)
+		}()
+	}
+	wg.Wait()
+
+	return results, ctx.Err()
+}
+
+// SyncChannel syncs the posts of a single channel.
+func (s *Syncer) SyncChannel(ctx context.Context, channelId string) (UpsertStats, error) {
+	var stats UpsertStats
+	if s.Store == nil {
+		return stats, errors.New("archive: store is not set")
+	}
+
+	cursor, err := s.Store.Cursor(ctx, channelId)
+	if err != nil {
+		return stats, err
+	}
+	if !cursor.InProgress() {
+		cursor = s.window(cursor)
+		if err := s.Store.SaveCursor(ctx, channelId, cursor); err != nil {
+			return stats, err
+		}
+	}
+
+	limit := uint64(pageSize)
+	hideDeleted := false
+	endTime := strconv.FormatInt(cursor.WindowEnd, 10)
+	request := channels.PostsRequest{
+		ChannelId:   channelId,
+		Limit:       &limit,
+		EndTime:     &endTime,
+		HideDeleted: &hideDeleted,
+	}
+	if cursor.WindowStart > 0 {
+		startTime := strconv.FormatInt(cursor.WindowStart, 10)
+		request.StartTime = &startTime
+	}
+
+	for {
+		offset := cursor.Offset
+		request.Offset = &offset
+		page, _, err := channels.Posts(ctx, request)
+		if err != nil {
+			return stats, err
+		}
+
+		if cursor.Offset == 0 && page.Response.Channel.ID != 0 {
+			if err := s.Store.SaveChannel(ctx, channelId, page.Response.Channel.Summary()); err != nil {
+				return stats, err
+			}
+		}
+
+		posts := page.Posts()
+		pageStats, err := s.Store.UpsertPosts(ctx, channelId, posts)
+		if err != nil {
+			return stats, err
+		}
+		stats.add(pageStats)
+
+		for _, p := range posts {
+			if int64(p.Date) > cursor.MaxDate {
+				cursor.MaxDate = int64(p.Date)
+			}
+		}
+		cursor.Offset += uint64(len(posts))
+
+		if len(posts) < pageSize {
+			break
+		}
+		if err := s.Store.SaveCursor(ctx, channelId, cursor); err != nil {
+			return stats, err
+		}
+	}
+
+	if cursor.MaxDate > cursor.LastDate {
+		cursor.LastDate = cursor.MaxDate
+	}
+	cursor.LastSync = time.Unix(cursor.WindowEnd, 0)
+	cursor.WindowStart, cursor.WindowEnd, cursor.Offset, cursor.MaxDate = 0, 0, 0, 0
+	return stats, s.Store.SaveCursor(ctx, channelId, cursor)
+}
+
+// window starts a new sync window ending now.
+func (s *Syncer) window(cursor Cursor) Cursor {
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	refresh := s.RefreshWindow
+	if refresh <= 0 {
+		refresh = DefaultRefreshWindow
+	}
+
+	end := now()
+	cursor.WindowEnd = end.Unix()
+	cursor.Offset, cursor.MaxDate = 0, 0
+
+	switch {
+	case cursor.LastDate > 0:
+		cursor.WindowStart = min(cursor.LastDate, end.Add(-refresh).Unix())
+	case s.Since > 0:
+		cursor.WindowStart = end.Add(-s.Since).Unix()
+	default:
+		cursor.WindowStart = 0
+	}
+	return cursor
+}
@@ -42,12 +42,12 @@ type SubscriptionListResponse struct {
 }
 
 type Subscription struct {
-	SubscriptionId int      `json:"subscription_id"`
-	EventTypes     []string `json:"event_types"`
-	Type           string   `json:"type"`
-	Channel        Channel  `json:"channel,omitempty"`
-	CreatedAt      int      `json:"created_at"`
-	Keyword        Keyword  `json:"keyword,omitempty"`
+	SubscriptionId int        `json:"subscription_id"`
+	EventTypes     EventTypes `json:"event_types"`
+	Type           string     `json:"type"`
+	Channel        Channel    `json:"channel,omitempty"`
+	CreatedAt      int        `json:"created_at"`
+	Keyword        Keyword    `json:"keyword,omitempty"`
 }
 
 type Keyword struct {
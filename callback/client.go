@@ -87,13 +87,13 @@ func (c Client) GetCallbackInfo(ctx context.Context) (*tgstat.GetCallbackRespons
 type SubscribeChannelRequest struct {
 	SubscriptionId *string
 	ChannelId      string
-	EventTypes     string
+	EventTypes     tgstat.EventTypes
 }
 
 func (subscribeChannelRequest SubscribeChannelRequest) Validate() error {
 	return validation.ValidateStruct(&subscribeChannelRequest,
 		validation.Field(&subscribeChannelRequest.ChannelId, validation.Required),
-		validation.Field(&subscribeChannelRequest.EventTypes, validation.Required, validation.In("new_post", "edit_post", "remove_post")),
+		validation.Field(&subscribeChannelRequest.EventTypes, validation.Required, validation.By(tgstat.AllowedFor(endpoints.SubscribeChannel))),
 	)
 }
 
@@ -115,7 +115,7 @@ func (c Client) SubscribeChannel(ctx context.Context, request SubscribeChannelRe
 	}
 
 	body["channel_id"] = request.ChannelId
-	body["event_types"] = request.EventTypes
+	body["event_types"] = request.EventTypes.String()
 
 	req, err := c.api.NewRestRequest(ctx, c.token, http.MethodPost, path, body)
 
@@ -137,18 +137,18 @@ func (c Client) SubscribeChannel(ctx context.Context, request SubscribeChannelRe
 type SubscribeWordRequest struct {
 	SubscriptionId *string
 	Q              string
-	EventTypes     string
+	EventTypes     tgstat.EventTypes
 	StrongSearch   *bool
 	MinusWords     *string
 	ExtendedSyntax *bool
-	PeerTypes      *string
+	PeerTypes      tgstat.PeerType
 }
 
 func (subscribeWordRequest SubscribeWordRequest) Validate() error {
 	return validation.ValidateStruct(&subscribeWordRequest,
 		validation.Field(&subscribeWordRequest.Q, validation.Required),
-		validation.Field(&subscribeWordRequest.EventTypes, validation.Required, validation.In("new_post")),
-		validation.Field(&subscribeWordRequest.PeerTypes, validation.In("channel", "chat", "all")),
+		validation.Field(&subscribeWordRequest.EventTypes, validation.Required, validation.By(tgstat.AllowedFor(endpoints.SubscribeWord))),
+		validation.Field(&subscribeWordRequest.PeerTypes, validation.By(tgstat.AllowedFor(endpoints.SubscribeWord))),
 	)
 }
 
@@ -171,7 +171,7 @@ func (c Client) SubscribeWord(ctx context.Context, request SubscribeWordRequest)
 	}
 
 	body["q"] = request.Q
-	body["event_types"] = request.EventTypes
+	body["event_types"] = request.EventTypes.String()
 
 	if nil != request.StrongSearch {
 		body["strong_search"] = boolValue(request.StrongSearch)
@@ -185,8 +185,8 @@ func (c Client) SubscribeWord(ctx context.Context, request SubscribeWordRequest)
 		body["extended_syntax"] = boolValue(request.ExtendedSyntax)
 	}
 
-	if nil != request.PeerTypes {
-		body["peer_types"] = *request.PeerTypes
+	if request.PeerTypes != "" {
+		body["peer_types"] = string(request.PeerTypes)
 	}
 
 	req, err := c.api.NewRestRequest(ctx, c.token, http.MethodPost, path, body)
@@ -208,12 +208,12 @@ func (c Client) SubscribeWord(ctx context.Context, request SubscribeWordRequest)
 
 type SubscriptionsListRequest struct {
 	SubscriptionId   *string
-	SubscriptionType *string
+	SubscriptionType tgstat.SubscriptionType
 }
 
 func (subscriptionsListRequest SubscriptionsListRequest) Validate() error {
 	return validation.ValidateStruct(&subscriptionsListRequest,
-		validation.Field(&subscriptionsListRequest.SubscriptionType, validation.In("channel", "keyword")),
+		validation.Field(&subscriptionsListRequest.SubscriptionType, validation.By(tgstat.AllowedFor(endpoints.SubscriptionsList))),
 	)
 }
 
@@ -235,8 +235,8 @@ func (c Client) SubscriptionsList(ctx context.Context, subscriptionsListRequest
 		body["subscription_id"] = *subscriptionsListRequest.SubscriptionId
 	}
 
-	if nil != subscriptionsListRequest.SubscriptionType {
-		body["subscription_type"] = *subscriptionsListRequest.SubscriptionType
+	if subscriptionsListRequest.SubscriptionType != "" {
+		body["subscription_type"] = string(subscriptionsListRequest.SubscriptionType)
 	}
 
 	var response tgstat.SubscriptionList
@@ -294,3 +294,9 @@ func boolValue(v *bool) string {
 func getClient() Client {
 	return Client{tgstat.GetAPI(), tgstat.Token}
 }
+
+// NewClient returns a client authenticated with token instead of tgstat.Token,
+// for serving several accounts.
+func NewClient(token string) Client {
+	return Client{tgstat.GetAPI(), token}
+}
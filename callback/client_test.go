@@ -144,7 +144,7 @@ func TestClient_SubscribeChannel(t *testing.T) {
 		req := SubscribeChannelRequest{
 			SubscriptionId: String("blabla"),
 			ChannelId:      "t.me/username",
-			EventTypes:     "new_post",
+			EventTypes:     tgstat.EventTypes{tgstat.EventNewPost},
 		}
 		_, _, err := SubscribeChannel(context.Background(), req)
 		Expect(err).To(HaveOccurred())
@@ -158,7 +158,7 @@ func TestClient_SubscribeChannel(t *testing.T) {
 		req := SubscribeChannelRequest{
 			SubscriptionId: String("blabla"),
 			ChannelId:      "t.me/username",
-			EventTypes:     "new_post",
+			EventTypes:     tgstat.EventTypes{tgstat.EventNewPost},
 		}
 
 		_, _, err := SubscribeChannel(context.Background(), req)
@@ -175,7 +175,7 @@ func TestClient_SubscribeChannel(t *testing.T) {
 		req := SubscribeChannelRequest{
 			SubscriptionId: String("blabla"),
 			ChannelId:      "t.me/username",
-			EventTypes:     "new_post1",
+			EventTypes:     tgstat.EventTypes{"new_post1"},
 		}
 
 		_, _, err := SubscribeChannel(context.Background(), req)
@@ -199,7 +199,7 @@ func TestClient_SubscribeChannel(t *testing.T) {
 		req := SubscribeChannelRequest{
 			SubscriptionId: String("blabla"),
 			ChannelId:      "t.me/username",
-			EventTypes:     "new_post",
+			EventTypes:     tgstat.EventTypes{tgstat.EventNewPost},
 		}
 
 		response, _, err := SubscribeChannel(context.Background(), req)
@@ -208,6 +208,31 @@ func TestClient_SubscribeChannel(t *testing.T) {
 			"SubscriptionId": Equal(123),
 		})))
 	})
+
+	t.Run("Test SubscribeChannel sends several event types", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+
+		testServer.Mux.HandleFunc(endpoints.SubscribeChannel, func(w http.ResponseWriter, r *http.Request) {
+			body := make(map[string]string)
+			json.NewDecoder(r.Body).Decode(&body)
+			Expect(body["event_types"]).To(Equal("new_post,remove_post"))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(tgstat.SubscribeResponse{
+				SubscriptionId: 1,
+			})
+		})
+
+		req := SubscribeChannelRequest{
+			ChannelId:  "t.me/username",
+			EventTypes: tgstat.EventTypes{tgstat.EventNewPost, tgstat.EventRemovePost},
+		}
+
+		_, _, err := SubscribeChannel(context.Background(), req)
+		Expect(err).ToNot(HaveOccurred())
+	})
 }
 
 func TestClient_SubscribeWord(t *testing.T) {
@@ -219,7 +244,7 @@ func TestClient_SubscribeWord(t *testing.T) {
 
 		req := SubscribeWordRequest{
 			Q:          "Test",
-			EventTypes: "new_post",
+			EventTypes: tgstat.EventTypes{tgstat.EventNewPost},
 		}
 		_, _, err := SubscribeWord(context.Background(), req)
 		Expect(err).To(HaveOccurred())
@@ -232,7 +257,7 @@ func TestClient_SubscribeWord(t *testing.T) {
 
 		req := SubscribeWordRequest{
 			Q:          "Test",
-			EventTypes: "new_post",
+			EventTypes: tgstat.EventTypes{tgstat.EventNewPost},
 		}
 		_, _, err := SubscribeWord(context.Background(), req)
 
@@ -259,7 +284,7 @@ func TestClient_SubscribeWord(t *testing.T) {
 
 		req := SubscribeWordRequest{
 			Q:          "Test",
-			EventTypes: "new_post",
+			EventTypes: tgstat.EventTypes{tgstat.EventNewPost},
 		}
 		response, _, err := SubscribeWord(context.Background(), req)
 		Expect(err).ToNot(HaveOccurred())
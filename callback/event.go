@@ -0,0 +1,74 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+)
+
+// Event is a post event delivered to the callback URL, or found by a
+// KeywordMonitor for keyword subscriptions served by polling.
+type Event struct {
+	EventID          int64                   `json:"event_id"`
+	EventType        tgstat.EventType        `json:"event_type"`
+	SubscriptionID   int                     `json:"subscription_id"`
+	SubscriptionType tgstat.SubscriptionType `json:"subscription_type"`
+	Post             tgstat.Post             `json:"post"`
+	// Channel is the channel of the post when the delivery carries it.
+	Channel tgstat.ChannelSummary `json:"channel"`
+	// Keyword is the query matched by keyword events.
+	Keyword string `json:"keyword,omitempty"`
+	// Enrichment is set by the enrich package.
+	Enrichment *Enrichment `json:"enrichment,omitempty"`
+}
+
+// Enrichment holds the full objects of an event resolved from the API.
+type Enrichment struct {
+	Post    *tgstat.PostResponse     `json:"post,omitempty"`
+	Channel *tgstat.ChannelResponse  `json:"channel,omitempty"`
+	Stat    *tgstat.PostStatResponse `json:"stat,omitempty"`
+	// Degraded lists the objects that could not be resolved, with the reason.
+	Degraded []string `json:"degraded,omitempty"`
+}
+
+// UnmarshalJSON accepts is_deleted of the post both as a number, the way
+// TGStat sends it, and as a boolean, the way Event is marshaled.
+func (e *Event) UnmarshalJSON(data []byte) error {
+	type event Event
+	var value struct {
+		event
+		Post struct {
+			tgstat.Post
+			IsDeleted json.RawMessage `json:"is_deleted"`
+		} `json:"post"`
+	}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	*e = Event(value.event)
+	e.Post = value.Post.Post
+	switch deleted := string(bytes.Trim(bytes.TrimSpace(value.Post.IsDeleted), `"`)); deleted {
+	case "", "null", "0", "false":
+	case "1", "true":
+		e.Post.IsDeleted = true
+	default:
+		return fmt.Errorf("post.is_deleted: unexpected value %s", deleted)
+	}
+	return nil
+}
+
+// Handler processes events. An error asks the source to deliver the event again.
+type Handler interface {
+	HandleEvent(ctx context.Context, event Event) error
+}
+
+// HandlerFunc adapts a function to Handler.
+type HandlerFunc func(ctx context.Context, event Event) error
+
+// HandleEvent calls f.
+func (f HandlerFunc) HandleEvent(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
@@ -0,0 +1,239 @@
+package callback
+
+import (
+	"context"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHealthInterval is the wait between two checks of HealthMonitor.Run.
+	DefaultHealthInterval = time.Minute
+	// DefaultBacklogChecks is the number of consecutive checks the pending
+	// update count must grow for before a backlog is reported.
+	DefaultBacklogChecks = 3
+)
+
+// HealthEventType is the kind of a HealthEvent.
+type HealthEventType string
+
+const (
+	// HealthBacklog is emitted when the pending updates keep growing or reach the threshold.
+	HealthBacklog HealthEventType = "backlog"
+	// HealthBacklogCleared is emitted when the pending updates of a backlog are delivered.
+	HealthBacklogCleared HealthEventType = "backlog_cleared"
+	// HealthDeliveryError is emitted for every new delivery error reported by TGStat.
+	HealthDeliveryError HealthEventType = "delivery_error"
+	// HealthURLMismatch is emitted when the callback URL differs from the expected one.
+	HealthURLMismatch HealthEventType = "url_mismatch"
+	// HealthRegistered is emitted when the expected URL was registered again.
+	HealthRegistered HealthEventType = "registered"
+	// HealthCheckFailed is emitted when GetCallbackInfo or the registration fails.
+	HealthCheckFailed HealthEventType = "check_failed"
+)
+
+// HealthEvent is a change of the callback health detected by HealthMonitor.
+type HealthEvent struct {
+	Type HealthEventType
+	Time time.Time
+	// Info is the result of the check, empty when GetCallbackInfo failed.
+	Info tgstat.CallbackResponse
+	Err  error
+}
+
+func (e HealthEvent) String() string {
+	switch e.Type {
+	case HealthBacklog, HealthBacklogCleared:
+		return fmt.Sprintf("%s: %d pending updates", e.Type, e.Info.PendingUpdateCount)
+	case HealthDeliveryError:
+		return fmt.Sprintf("%s at %s: %s", e.Type, time.Unix(int64(e.Info.LastErrorDate), 0).UTC().Format(time.RFC3339), e.Info.LastErrorMessage)
+	case HealthURLMismatch, HealthRegistered:
+		return fmt.Sprintf("%s: callback URL is %q", e.Type, e.Info.Url)
+	default:
+		return fmt.Sprintf("%s: %v", e.Type, e.Err)
+	}
+}
+
+// HealthStats is the state of the callback after the last check.
+type HealthStats struct {
+	Checks        int
+	CheckFailures int
+	// Pending is the pending update count of the last successful check.
+	Pending        int
+	DeliveryErrors int
+	Registrations  int
+	Backlog        bool
+	URLMismatch    bool
+	// LastCheck is the time of the last successful check.
+	LastCheck time.Time
+	// Err is the error of the last check.
+	Err error
+}
+
+// Healthy reports whether the last check succeeded and found neither a
+// backlog nor a URL mismatch.
+func (s HealthStats) Healthy() bool {
+	return s.Checks != 0 && s.Err == nil && !s.Backlog && !s.URLMismatch
+}
+
+// HealthMonitor polls GetCallbackInfo and reports backlogs, new delivery
+// errors and URL mismatches to OnEvent. States are reported when entered,
+// not on every check.
+type HealthMonitor struct {
+	// ExpectedURL is compared with the registered callback URL, the check is skipped when empty.
+	ExpectedURL string
+	// Receiver, when set, serves ExpectedURL and is used to Register it again on mismatch.
+	Receiver *Receiver
+	// BacklogThreshold reports a backlog once the pending updates reach it, zero disables it.
+	BacklogThreshold int
+	// BacklogChecks defaults to DefaultBacklogChecks.
+	BacklogChecks int
+	OnEvent       func(HealthEvent)
+	// Interval defaults to DefaultHealthInterval.
+	Interval time.Duration
+	// Now defaults to time.Now.
+	Now func() time.Time
+	// Client checks and registers the callback, it defaults to the client
+	// authenticated with tgstat.Token. Set it to Tenant.Client to monitor a tenant.
+	Client Client
+
+	once     sync.Once
+	mu       sync.Mutex
+	stats    HealthStats
+	started  time.Time
+	previous int
+	growing  int
+	// lastError is the date of the last delivery error reported.
+	lastError int
+}
+
+func (m *HealthMonitor) init() {
+	m.once.Do(func() {
+		if m.BacklogChecks <= 0 {
+			m.BacklogChecks = DefaultBacklogChecks
+		}
+		if m.Interval <= 0 {
+			m.Interval = DefaultHealthInterval
+		}
+		if m.Now == nil {
+			m.Now = time.Now
+		}
+		m.started = m.Now()
+	})
+}
+
+// Run checks every Interval until ctx is done, the first check starts at once.
+func (m *HealthMonitor) Run(ctx context.Context) error {
+	m.init()
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check calls GetCallbackInfo once and returns the events emitted.
+// Delivery errors older than the first check by more than Interval are not reported.
+func (m *HealthMonitor) Check(ctx context.Context) []HealthEvent {
+	m.init()
+	c := m.Client
+	if c.api == nil {
+		c = getClient()
+	}
+	var events []HealthEvent
+	emit := func(event HealthEvent) {
+		event.Time = m.Now()
+		events = append(events, event)
+	}
+
+	result, _, err := c.GetCallbackInfo(ctx)
+	if err == nil && result.Status != "ok" {
+		err = &tgstat.APIError{Message: "status " + result.Status}
+	}
+
+	m.mu.Lock()
+	m.stats.Checks++
+	if err != nil {
+		m.stats.CheckFailures++
+		m.stats.Err = fmt.Errorf("callback: get callback info: %w", err)
+		emit(HealthEvent{Type: HealthCheckFailed, Err: m.stats.Err})
+		m.mu.Unlock()
+		return m.deliver(events)
+	}
+	info := result.Response
+	m.stats.LastCheck = m.Now()
+	m.stats.Err = nil
+	m.stats.Pending = info.PendingUpdateCount
+
+	if info.LastErrorDate > m.lastError {
+		// errors older than the monitor were reported before it started
+		if m.lastError != 0 || int64(info.LastErrorDate) >= m.started.Add(-m.Interval).Unix() {
+			m.stats.DeliveryErrors++
+			emit(HealthEvent{Type: HealthDeliveryError, Info: info})
+		}
+		m.lastError = info.LastErrorDate
+	}
+
+	if info.PendingUpdateCount > m.previous {
+		m.growing++
+	} else {
+		m.growing = 0
+	}
+	m.previous = info.PendingUpdateCount
+	backlog := m.growing >= m.BacklogChecks ||
+		(m.BacklogThreshold > 0 && info.PendingUpdateCount >= m.BacklogThreshold) ||
+		(m.stats.Backlog && info.PendingUpdateCount > 0)
+	if backlog != m.stats.Backlog {
+		m.stats.Backlog = backlog
+		if backlog {
+			emit(HealthEvent{Type: HealthBacklog, Info: info})
+		} else {
+			emit(HealthEvent{Type: HealthBacklogCleared, Info: info})
+		}
+	}
+
+	mismatch := m.ExpectedURL != "" && strings.TrimSuffix(info.Url, "/") != strings.TrimSuffix(m.ExpectedURL, "/")
+	if mismatch && !m.stats.URLMismatch {
+		emit(HealthEvent{Type: HealthURLMismatch, Info: info})
+	}
+	m.stats.URLMismatch = mismatch
+	m.mu.Unlock()
+
+	if mismatch && m.Receiver != nil {
+		if err := c.Register(ctx, m.Receiver, m.ExpectedURL); err != nil {
+			emit(HealthEvent{Type: HealthCheckFailed, Info: info, Err: err})
+		} else {
+			info.Url = m.ExpectedURL
+			emit(HealthEvent{Type: HealthRegistered, Info: info})
+			m.mu.Lock()
+			m.stats.Registrations++
+			m.stats.URLMismatch = false
+			m.mu.Unlock()
+		}
+	}
+	return m.deliver(events)
+}
+
+func (m *HealthMonitor) deliver(events []HealthEvent) []HealthEvent {
+	if m.OnEvent != nil {
+		for _, event := range events {
+			m.OnEvent(event)
+		}
+	}
+	return events
+}
+
+// Stats returns the state after the last check.
+func (m *HealthMonitor) Stats() HealthStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
@@ -0,0 +1,166 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCallbackInfo serves callback/get-callback-info from info.
+type fakeCallbackInfo struct {
+	mu   sync.Mutex
+	info tgstat.CallbackResponse
+	fail bool
+}
+
+func newFakeCallbackInfo(testServer server.Server) *fakeCallbackInfo {
+	fake := &fakeCallbackInfo{}
+	testServer.Mux.HandleFunc(endpoints.GetCallbackURL, func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		if fake.fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(tgstat.GetCallbackResponse{Status: "ok", Response: fake.info})
+	})
+	return fake
+}
+
+func (f *fakeCallbackInfo) set(update func(info *tgstat.CallbackResponse)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	update(&f.info)
+}
+
+func types(events []HealthEvent) []HealthEventType {
+	var result []HealthEventType
+	for _, event := range events {
+		result = append(result, event.Type)
+	}
+	return result
+}
+
+func TestHealthMonitor(t *testing.T) {
+	RegisterTestingT(t)
+	now := time.Unix(1700000000, 0)
+
+	t.Run("Test backlog and delivery errors", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		fake := newFakeCallbackInfo(testServer)
+		// an error from before the monitor started
+		fake.set(func(info *tgstat.CallbackResponse) {
+			info.Url = "https://example.com/callback"
+			info.LastErrorDate = int(now.Add(-time.Hour).Unix())
+			info.LastErrorMessage = "timeout"
+		})
+
+		var received []HealthEvent
+		monitor := &HealthMonitor{
+			ExpectedURL: "https://example.com/callback/",
+			Now:         func() time.Time { return now },
+			OnEvent:     func(event HealthEvent) { received = append(received, event) },
+		}
+		ctx := context.Background()
+		Expect(monitor.Check(ctx)).To(BeEmpty())
+		Expect(monitor.Stats().Healthy()).To(BeTrue())
+
+		for pending := 1; pending <= 2; pending++ {
+			fake.set(func(info *tgstat.CallbackResponse) { info.PendingUpdateCount = pending * 10 })
+			Expect(monitor.Check(ctx)).To(BeEmpty())
+		}
+		fake.set(func(info *tgstat.CallbackResponse) {
+			info.PendingUpdateCount = 30
+			info.LastErrorDate = int(now.Unix())
+			info.LastErrorMessage = "connection refused"
+		})
+		events := monitor.Check(ctx)
+		Expect(types(events)).To(Equal([]HealthEventType{HealthDeliveryError, HealthBacklog}))
+		Expect(events[0].String()).To(Equal("delivery_error at 2023-11-14T22:13:20Z: connection refused"))
+		Expect(events[1].Info.PendingUpdateCount).To(Equal(30))
+		Expect(monitor.Stats().Healthy()).To(BeFalse())
+
+		// shrinking is still a backlog until empty
+		fake.set(func(info *tgstat.CallbackResponse) { info.PendingUpdateCount = 5 })
+		Expect(monitor.Check(ctx)).To(BeEmpty())
+		fake.set(func(info *tgstat.CallbackResponse) { info.PendingUpdateCount = 0 })
+		Expect(types(monitor.Check(ctx))).To(Equal([]HealthEventType{HealthBacklogCleared}))
+
+		Expect(received).To(HaveLen(3))
+		stats := monitor.Stats()
+		Expect(stats.Checks).To(Equal(6))
+		Expect(stats.DeliveryErrors).To(Equal(1))
+		Expect(stats.Healthy()).To(BeTrue())
+	})
+
+	t.Run("Test backlog threshold and failed checks", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		fake := newFakeCallbackInfo(testServer)
+		fake.set(func(info *tgstat.CallbackResponse) { info.PendingUpdateCount = 100 })
+
+		monitor := &HealthMonitor{BacklogThreshold: 100}
+		Expect(types(monitor.Check(context.Background()))).To(Equal([]HealthEventType{HealthBacklog}))
+
+		fake.set(func(*tgstat.CallbackResponse) { fake.fail = true })
+		events := monitor.Check(context.Background())
+		Expect(types(events)).To(Equal([]HealthEventType{HealthCheckFailed}))
+		Expect(events[0].Err).To(HaveOccurred())
+		Expect(monitor.Stats().CheckFailures).To(Equal(1))
+		Expect(monitor.Stats().Pending).To(Equal(100))
+	})
+
+	t.Run("Test monitor uses its client", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		var tokens []string
+		testServer.Mux.HandleFunc(endpoints.GetCallbackURL, func(w http.ResponseWriter, r *http.Request) {
+			tokens = append(tokens, r.URL.Query().Get("token"))
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tgstat.GetCallbackResponse{Status: "ok"}) //nolint
+		})
+
+		(&HealthMonitor{}).Check(context.Background())
+		(&HealthMonitor{Client: NewClient("tenant")}).Check(context.Background())
+		Expect(tokens).To(Equal([]string{"token", "tenant"}))
+	})
+
+	t.Run("Test URL mismatch registers again", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		fake := newFakeCallbackInfo(testServer)
+		fake.set(func(info *tgstat.CallbackResponse) { info.Url = "https://old.example.com/callback" })
+		testServer.Mux.HandleFunc(endpoints.SetCallbackURL, func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			fake.set(func(info *tgstat.CallbackResponse) { info.Url = body["callback_url"] })
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tgstat.SetCallbackSuccessResult{Status: "ok"})
+		})
+
+		monitor := &HealthMonitor{
+			ExpectedURL: "https://example.com/callback",
+			Receiver:    NewReceiver(nil),
+		}
+		events := monitor.Check(context.Background())
+		Expect(types(events)).To(Equal([]HealthEventType{HealthURLMismatch, HealthRegistered}))
+		Expect(events[0].Info.Url).To(Equal("https://old.example.com/callback"))
+		Expect(monitor.Stats().Registrations).To(Equal(1))
+		Expect(monitor.Stats().Healthy()).To(BeTrue())
+
+		Expect(monitor.Check(context.Background())).To(BeEmpty())
+	})
+}
@@ -0,0 +1,339 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/posts"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultPollInterval is the wait between two polls of KeywordMonitor.Run.
+	DefaultPollInterval = 5 * time.Minute
+	// DefaultLookback is how far back the first poll of a query searches.
+	DefaultLookback = 24 * time.Hour
+	// DefaultOverlap is how far before the watermark every poll searches again,
+	// catching posts indexed late.
+	DefaultOverlap = 15 * time.Minute
+
+	searchPageSize = 50
+	// searchMaxOffset is the largest offset accepted by posts/search.
+	searchMaxOffset = 50
+)
+
+// ErrSearchOverflow is returned by KeywordMonitor.Poll when more posts matched
+// a query since the last poll than posts/search returns. The newest posts are
+// still emitted, the older ones may be missing.
+var ErrSearchOverflow = errors.New("search overflow")
+
+// Watermark is the polling progress of a query.
+type Watermark struct {
+	// Date is the date of the newest post emitted.
+	Date int64 `json:"date"`
+	// Seen maps the IDs of the posts emitted within the overlap to their date.
+	Seen map[int64]int64 `json:"seen,omitempty"`
+}
+
+// WatermarkStore persists watermarks between runs, keyed by query.
+type WatermarkStore interface {
+	Watermark(ctx context.Context, query string) (Watermark, error)
+	SaveWatermark(ctx context.Context, query string, watermark Watermark) error
+}
+
+// FileWatermarkStore keeps the watermarks of every query in a JSON file,
+// replaced atomically on save.
+type FileWatermarkStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileWatermarkStore returns a store writing to path.
+func NewFileWatermarkStore(path string) *FileWatermarkStore {
+	return &FileWatermarkStore{path: path}
+}
+
+func (f *FileWatermarkStore) Watermark(_ context.Context, query string) (Watermark, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	watermarks, err := f.load()
+	return watermarks[query], err
+}
+
+func (f *FileWatermarkStore) SaveWatermark(_ context.Context, query string, watermark Watermark) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	watermarks, err := f.load()
+	if err != nil {
+		return err
+	}
+	watermarks[query] = watermark
+
+	data, err := json.Marshal(watermarks)
+	if err != nil {
+		return fmt.Errorf("callback: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("callback: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint
+		return fmt.Errorf("callback: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("callback: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), f.path); err != nil {
+		return fmt.Errorf("callback: %w", err)
+	}
+	return nil
+}
+
+func (f *FileWatermarkStore) load() (map[string]Watermark, error) {
+	watermarks := make(map[string]Watermark)
+	data, err := os.ReadFile(f.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return watermarks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("callback: %w", err)
+	}
+	if err := json.Unmarshal(data, &watermarks); err != nil {
+		return nil, fmt.Errorf("callback: %s: %w", f.path, err)
+	}
+	return watermarks, nil
+}
+
+type memoryWatermarkStore struct {
+	mu         sync.Mutex
+	watermarks map[string]Watermark
+}
+
+func (m *memoryWatermarkStore) Watermark(_ context.Context, query string) (Watermark, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.watermarks[query], nil
+}
+
+func (m *memoryWatermarkStore) SaveWatermark(_ context.Context, query string, watermark Watermark) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.watermarks == nil {
+		m.watermarks = make(map[string]Watermark)
+	}
+	m.watermarks[query] = watermark
+	return nil
+}
+
+// KeywordMonitor polls posts/search for keyword queries and emits the new
+// matches to Handler as keyword events, for environments where the callback
+// URL cannot be reached by TGStat.
+//
+// Every poll searches from the watermark of the query minus Overlap, posts
+// already emitted are skipped by ID. Each query is searched with at most two
+// pages of 50 posts, the interval should be short enough to stay below that:
+// when a poll finds more, Poll returns ErrSearchOverflow.
+type KeywordMonitor struct {
+	// Queries are keyed by Q in watermarks and events, StartDate, Limit and Offset are managed by the monitor.
+	Queries []posts.PostSearchRequest
+	Handler Handler
+	// Store defaults to an in-memory store.
+	Store WatermarkStore
+	// Extended uses PostSearchExtended, filling Event.Channel.
+	Extended bool
+	// Interval defaults to DefaultPollInterval, Lookback to DefaultLookback
+	// and Overlap to DefaultOverlap.
+	Interval time.Duration
+	Lookback time.Duration
+	Overlap  time.Duration
+	// Now defaults to time.Now.
+	Now func() time.Time
+
+	once sync.Once
+}
+
+func (m *KeywordMonitor) init() {
+	m.once.Do(func() {
+		if m.Store == nil {
+			m.Store = &memoryWatermarkStore{}
+		}
+		if m.Interval <= 0 {
+			m.Interval = DefaultPollInterval
+		}
+		if m.Lookback <= 0 {
+			m.Lookback = DefaultLookback
+		}
+		if m.Overlap <= 0 {
+			m.Overlap = DefaultOverlap
+		}
+		if m.Now == nil {
+			m.Now = time.Now
+		}
+	})
+}
+
+// Run polls every Interval until ctx is done, the first poll starts at once.
+// Errors of a poll are passed to onError when set and do not stop the monitor.
+func (m *KeywordMonitor) Run(ctx context.Context, onError func(error)) error {
+	m.init()
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := m.Poll(ctx); err != nil && onError != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll searches every query once and returns the number of events emitted.
+// A failed query does not stop the others, their errors are joined.
+func (m *KeywordMonitor) Poll(ctx context.Context) (int, error) {
+	m.init()
+	emitted := 0
+	var errs []error
+	for _, query := range m.Queries {
+		n, err := m.poll(ctx, query)
+		emitted += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("callback: poll %q: %w", query.Q, err))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return emitted, errors.Join(errs...)
+}
+
+func (m *KeywordMonitor) poll(ctx context.Context, query posts.PostSearchRequest) (int, error) {
+	watermark, err := m.Store.Watermark(ctx, query.Q)
+	if err != nil {
+		return 0, err
+	}
+
+	start := m.Now().Add(-m.Lookback).Unix()
+	if watermark.Date != 0 {
+		start = watermark.Date - int64(m.Overlap/time.Second)
+	}
+
+	events, overflow, err := m.search(ctx, query, start)
+	if err != nil {
+		return 0, err
+	}
+
+	if watermark.Seen == nil {
+		watermark.Seen = make(map[int64]int64)
+	}
+	emitted := 0
+	for _, event := range events {
+		if _, seen := watermark.Seen[event.Post.ID]; seen {
+			continue
+		}
+		if err = m.Handler.HandleEvent(ctx, event); err != nil {
+			break
+		}
+		emitted++
+		watermark.Seen[event.Post.ID] = int64(event.Post.Date)
+		if int64(event.Post.Date) > watermark.Date {
+			watermark.Date = int64(event.Post.Date)
+		}
+	}
+
+	for id, date := range watermark.Seen {
+		if date < watermark.Date-int64(m.Overlap/time.Second) {
+			delete(watermark.Seen, id)
+		}
+	}
+	if saveErr := m.Store.SaveWatermark(ctx, query.Q, watermark); saveErr != nil && err == nil {
+		err = saveErr
+	}
+	if overflow && err == nil {
+		err = fmt.Errorf("%w: posts published between %s and %s may be missing", ErrSearchOverflow,
+			time.Unix(start, 0).UTC().Format(time.RFC3339), time.Unix(int64(events[0].Post.Date), 0).UTC().Format(time.RFC3339))
+	}
+	return emitted, err
+}
+
+// search returns the posts published since start as events, oldest first,
+// and whether the last page was reached before start.
+func (m *KeywordMonitor) search(ctx context.Context, query posts.PostSearchRequest, start int64) ([]Event, bool, error) {
+	startDate := strconv.FormatInt(start, 10)
+	query.StartDate = &startDate
+
+	var events []Event
+	overflow := true
+	for offset := 0; offset <= searchMaxOffset; offset += searchPageSize {
+		limit, offset := searchPageSize, offset
+		query.Limit, query.Offset = &limit, &offset
+
+		var page []Event
+		if m.Extended {
+			result, _, err := posts.PostSearchExtended(ctx, query)
+			if err != nil {
+				return nil, false, err
+			}
+			channels := make(map[int]tgstat.ChannelSummary)
+			for _, channel := range result.Channels() {
+				channels[channel.ID] = channel
+			}
+			for _, post := range result.Posts() {
+				page = append(page, keywordEvent(query, post, channels[post.ChannelID]))
+			}
+		} else {
+			result, _, err := posts.PostSearch(ctx, query)
+			if err != nil {
+				return nil, false, err
+			}
+			for _, post := range result.Posts() {
+				page = append(page, keywordEvent(query, post, tgstat.ChannelSummary{}))
+			}
+		}
+
+		older := false
+		for _, event := range page {
+			if int64(event.Post.Date) < start {
+				older = true
+				continue
+			}
+			events = append(events, event)
+		}
+		if older || len(page) < searchPageSize {
+			overflow = false
+			break
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].Post.Date != events[j].Post.Date {
+			return events[i].Post.Date < events[j].Post.Date
+		}
+		return events[i].Post.ID < events[j].Post.ID
+	})
+	return events, overflow, nil
+}
+
+func keywordEvent(query posts.PostSearchRequest, post tgstat.Post, channel tgstat.ChannelSummary) Event {
+	if channel.ID == 0 {
+		channel.ID = post.ChannelID
+	}
+	return Event{
+		EventType:        tgstat.EventNewPost,
+		SubscriptionType: tgstat.SubscriptionKeyword,
+		Post:             post,
+		Channel:          channel,
+		Keyword:          query.Q,
+	}
+}
@@ -0,0 +1,262 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	"github.com/helios-ag/tgstat-go/posts"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSearch serves posts/search from a list of posts, newest first.
+type fakeSearch struct {
+	mu       sync.Mutex
+	posts    []tgstat.PostSearchExtendedResponseItem
+	requests int
+}
+
+func newFakeSearch(testServer server.Server) *fakeSearch {
+	search := &fakeSearch{}
+	testServer.Mux.HandleFunc(endpoints.PostsSearch, func(w http.ResponseWriter, r *http.Request) {
+		search.mu.Lock()
+		defer search.mu.Unlock()
+		search.requests++
+
+		query := r.URL.Query()
+		startDate, _ := strconv.Atoi(query.Get("startDate"))
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		offset, _ := strconv.Atoi(query.Get("offset"))
+
+		var items []tgstat.PostSearchExtendedResponseItem
+		for _, post := range search.posts {
+			if post.Date >= startDate {
+				items = append(items, post)
+			}
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i].Date > items[j].Date })
+		items = items[min(offset, len(items)):min(offset+limit, len(items))]
+
+		response := tgstat.PostSearchExtendedResponse{Count: len(items), Items: items}
+		if query.Get("extended") == "1" {
+			response.Channels = []tgstat.PostSearchExtendedChannel{{ID: 7, Title: "Seven"}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tgstat.PostSearchExtendedResult{Status: "ok", Response: response})
+	})
+	return search
+}
+
+func (f *fakeSearch) add(id int64, date int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.posts = append(f.posts, tgstat.PostSearchExtendedResponseItem{ID: id, Date: date, ChannelID: 7, Text: "bitcoin"})
+}
+
+func collect(events *[]Event) Handler {
+	return HandlerFunc(func(_ context.Context, event Event) error {
+		*events = append(*events, event)
+		return nil
+	})
+}
+
+func ids(events []Event) []int64 {
+	var result []int64
+	for _, event := range events {
+		result = append(result, event.Post.ID)
+	}
+	return result
+}
+
+func TestKeywordMonitor_Poll(t *testing.T) {
+	RegisterTestingT(t)
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+
+	t.Run("Test poll emits new posts once, oldest first", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		search := newFakeSearch(testServer)
+		search.add(1, int(now.Add(-2*time.Hour).Unix()))
+		search.add(2, int(now.Add(-time.Hour).Unix()))
+		search.add(3, int(now.Add(-48*time.Hour).Unix()))
+
+		var events []Event
+		monitor := &KeywordMonitor{
+			Queries: []posts.PostSearchRequest{{Q: "bitcoin"}},
+			Handler: collect(&events),
+			Now:     clock,
+		}
+
+		emitted, err := monitor.Poll(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(emitted).To(Equal(2))
+		Expect(ids(events)).To(Equal([]int64{1, 2}))
+		Expect(events[0].EventType).To(Equal(tgstat.EventNewPost))
+		Expect(events[0].SubscriptionType).To(Equal(tgstat.SubscriptionKeyword))
+		Expect(events[0].Keyword).To(Equal("bitcoin"))
+		Expect(events[0].Channel.ID).To(Equal(7))
+
+		emitted, err = monitor.Poll(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(emitted).To(Equal(0))
+
+		// a post indexed late, within the overlap, and a new one
+		search.add(4, int(now.Add(-time.Hour-5*time.Minute).Unix()))
+		search.add(5, int(now.Add(-30*time.Minute).Unix()))
+		_, err = monitor.Poll(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ids(events)).To(Equal([]int64{1, 2, 4, 5}))
+	})
+
+	t.Run("Test poll pages past the first 50 posts", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		search := newFakeSearch(testServer)
+		for i := 1; i <= 70; i++ {
+			search.add(int64(i), int(now.Add(-time.Duration(71-i)*time.Minute).Unix()))
+		}
+
+		var events []Event
+		monitor := &KeywordMonitor{Queries: []posts.PostSearchRequest{{Q: "bitcoin"}}, Handler: collect(&events), Now: clock}
+		emitted, err := monitor.Poll(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(emitted).To(Equal(70))
+		Expect(search.requests).To(Equal(2))
+		Expect(events[0].Post.ID).To(Equal(int64(1)))
+		Expect(events[69].Post.ID).To(Equal(int64(70)))
+	})
+
+	t.Run("Test poll reports posts past the last page", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		search := newFakeSearch(testServer)
+		for i := 1; i <= 120; i++ {
+			search.add(int64(i), int(now.Add(-time.Duration(121-i)*time.Minute).Unix()))
+		}
+
+		var events []Event
+		monitor := &KeywordMonitor{Queries: []posts.PostSearchRequest{{Q: "bitcoin"}}, Handler: collect(&events), Now: clock}
+		emitted, err := monitor.Poll(context.Background())
+		Expect(err).To(MatchError(ErrSearchOverflow))
+		Expect(err).To(MatchError(ContainSubstring("may be missing")))
+		Expect(emitted).To(Equal(100))
+		Expect(events[0].Post.ID).To(Equal(int64(21)))
+	})
+
+	t.Run("Test extended poll fills channel", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		search := newFakeSearch(testServer)
+		search.add(1, int(now.Add(-time.Hour).Unix()))
+
+		var events []Event
+		monitor := &KeywordMonitor{Queries: []posts.PostSearchRequest{{Q: "bitcoin"}}, Handler: collect(&events), Extended: true, Now: clock}
+		_, err := monitor.Poll(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(events[0].Channel.Title).To(Equal("Seven"))
+	})
+
+	t.Run("Test handler error redelivers on next poll", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		search := newFakeSearch(testServer)
+		search.add(1, int(now.Add(-2*time.Hour).Unix()))
+		search.add(2, int(now.Add(-time.Hour).Unix()))
+
+		var events []Event
+		fail := true
+		monitor := &KeywordMonitor{
+			Queries: []posts.PostSearchRequest{{Q: "bitcoin"}},
+			Handler: HandlerFunc(func(_ context.Context, event Event) error {
+				if event.Post.ID == 2 && fail {
+					return errors.New("sink down")
+				}
+				events = append(events, event)
+				return nil
+			}),
+			Now: clock,
+		}
+		emitted, err := monitor.Poll(context.Background())
+		Expect(err).To(MatchError(ContainSubstring(`callback: poll "bitcoin": sink down`)))
+		Expect(emitted).To(Equal(1))
+
+		fail = false
+		emitted, err = monitor.Poll(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(emitted).To(Equal(1))
+		Expect(ids(events)).To(Equal([]int64{1, 2}))
+	})
+
+	t.Run("Test watermarks persist between runs", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		search := newFakeSearch(testServer)
+		search.add(1, int(now.Add(-time.Hour).Unix()))
+		path := filepath.Join(t.TempDir(), "watermarks.json")
+
+		var events []Event
+		first := &KeywordMonitor{Queries: []posts.PostSearchRequest{{Q: "bitcoin"}}, Handler: collect(&events), Store: NewFileWatermarkStore(path), Now: clock}
+		_, err := first.Poll(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+
+		watermark, err := NewFileWatermarkStore(path).Watermark(context.Background(), "bitcoin")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(watermark.Date).To(Equal(now.Add(-time.Hour).Unix()))
+		Expect(watermark.Seen).To(HaveKey(int64(1)))
+
+		search.add(2, int(now.Add(-time.Minute).Unix()))
+		second := &KeywordMonitor{Queries: []posts.PostSearchRequest{{Q: "bitcoin"}}, Handler: collect(&events), Store: NewFileWatermarkStore(path), Now: clock}
+		_, err = second.Poll(context.Background())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ids(events)).To(Equal([]int64{1, 2}))
+	})
+}
+
+func TestEvent_UnmarshalJSON(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test decode delivery", func(t *testing.T) {
+		var event Event
+		err := json.Unmarshal([]byte(`{"event_id":10,"event_type":"remove_post","subscription_id":3,"subscription_type":"channel",
+			"post":{"id":5,"date":1700000000,"channel_id":7,"is_deleted":1,"forwarded_from":"https://t.me/src/9","media":{"media_type":"mediaPhoto"}}}`), &event)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(event.EventID).To(Equal(int64(10)))
+		Expect(event.EventType).To(Equal(tgstat.EventRemovePost))
+		Expect(event.SubscriptionType).To(Equal(tgstat.SubscriptionChannel))
+		Expect(event.Post.ID).To(Equal(int64(5)))
+		Expect(event.Post.IsDeleted).To(BeTrue())
+		Expect(event.Post.ForwardedFrom.PostID).To(Equal(int64(9)))
+		Expect(event.Post.Media.MediaType).To(Equal("mediaPhoto"))
+	})
+
+	t.Run("Test marshal round trip", func(t *testing.T) {
+		event := Event{EventID: 1, EventType: tgstat.EventNewPost, Post: tgstat.Post{ID: 2, IsDeleted: true}, Keyword: "q"}
+		data, err := json.Marshal(event)
+		Expect(err).ToNot(HaveOccurred())
+		var decoded Event
+		Expect(json.Unmarshal(data, &decoded)).To(Succeed())
+		Expect(decoded).To(Equal(event))
+	})
+
+	t.Run("Test invalid is_deleted", func(t *testing.T) {
+		var event Event
+		err := json.Unmarshal([]byte(`{"post":{"is_deleted":"maybe"}}`), &event)
+		Expect(err).To(MatchError(ContainSubstring("post.is_deleted")))
+	})
+}
@@ -0,0 +1,201 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultMaxBodySize limits the size of a delivery read by Receiver.
+const DefaultMaxBodySize = 1 << 20
+
+// Receiver is the http.Handler of the callback URL. It answers the
+// verification requests of SetCallback and passes the events to Handler.
+// Deliveries are acknowledged once Handler returns nil, TGStat retries the
+// others.
+//
+// Every request can be restricted to a secret carried by the URL and to
+// source addresses; rejected requests are reported to OnError.
+type Receiver struct {
+	Handler Handler
+	// OnError, when set, is called with rejected requests and Handler errors.
+	OnError func(r *http.Request, err error)
+
+	// Secret, when set, must be the value of the SecretParam query parameter.
+	// SecretURL adds it to the URL to register.
+	Secret string
+	// SecretParam defaults to DefaultSecretParam.
+	SecretParam string
+	// AllowedNetworks restricts the source addresses, all are allowed when empty.
+	AllowedNetworks []netip.Prefix
+	// TrustedProxies are the proxies whose X-Forwarded-For and X-Real-IP
+	// headers give the source address.
+	TrustedProxies []netip.Prefix
+	// MaxBodySize defaults to DefaultMaxBodySize.
+	MaxBodySize int64
+	// Timeout bounds reading the request and handling the event, no limit when zero.
+	Timeout time.Duration
+	// Strict rejects events with unknown fields or missing identifiers.
+	Strict bool
+
+	mu         sync.RWMutex
+	verifyCode string
+}
+
+// NewReceiver returns a receiver passing events to handler.
+func NewReceiver(handler Handler) *Receiver {
+	return &Receiver{Handler: handler}
+}
+
+// SetVerifyCode sets the code answered to verification requests without one.
+func (r *Receiver) SetVerifyCode(code string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.verifyCode = code
+}
+
+// VerifyCode returns the code set by SetVerifyCode.
+func (r *Receiver) VerifyCode() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.verifyCode
+}
+
+// ServeHTTP handles a delivery.
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if status, err := r.authorize(req); err != nil {
+		r.reject(w, req, status, err)
+		return
+	}
+	if req.Method != http.MethodPost {
+		r.reject(w, req, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", req.Method))
+		return
+	}
+	if r.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), r.Timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+		// not every ResponseWriter supports deadlines
+		_ = http.NewResponseController(w).SetReadDeadline(time.Now().Add(r.Timeout))
+	}
+
+	maxBodySize := r.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = DefaultMaxBodySize
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxBodySize))
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		r.reject(w, req, http.StatusRequestEntityTooLarge, err)
+		return
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		r.reject(w, req, http.StatusRequestTimeout, err)
+		return
+	case err != nil:
+		r.reject(w, req, http.StatusBadRequest, err)
+		return
+	}
+
+	code, isVerification, err := verification(req, body)
+	if err != nil {
+		r.reject(w, req, http.StatusBadRequest, err)
+		return
+	}
+	if isVerification {
+		if code == "" {
+			code = r.VerifyCode()
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = io.WriteString(w, code)
+		return
+	}
+
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		r.reject(w, req, http.StatusBadRequest, fmt.Errorf("decode event: %w", err))
+		return
+	}
+	if r.Strict {
+		if err := validateEvent(body, event); err != nil {
+			r.reject(w, req, http.StatusBadRequest, fmt.Errorf("invalid event: %w", err))
+			return
+		}
+	}
+	if err := r.Handler.HandleEvent(req.Context(), event); err != nil {
+		r.reject(w, req, http.StatusInternalServerError, fmt.Errorf("event %d: %w", event.EventID, err))
+		return
+	}
+	_, _ = io.WriteString(w, "ok")
+}
+
+func (r *Receiver) reject(w http.ResponseWriter, req *http.Request, status int, err error) {
+	if r.OnError != nil {
+		r.OnError(req, err)
+	}
+	http.Error(w, http.StatusText(status), status)
+}
+
+// verification reports whether the request is a verification request, with
+// the code it carries. Requests without event_type are verifications.
+func verification(req *http.Request, body []byte) (string, bool, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return "", true, nil
+	}
+	if trimmed[0] != '{' {
+		values, err := url.ParseQuery(string(trimmed))
+		if err != nil {
+			return "", false, fmt.Errorf("decode request: %w", err)
+		}
+		return values.Get("verify_code"), true, nil
+	}
+
+	var fields struct {
+		VerifyCode string           `json:"verify_code"`
+		EventType  tgstat.EventType `json:"event_type"`
+	}
+	if err := json.Unmarshal(trimmed, &fields); err != nil {
+		return "", false, fmt.Errorf("decode request: %w", err)
+	}
+	return fields.VerifyCode, fields.EventType == "", nil
+}
+
+// Register sets the callback URL, answering the verification through receiver.
+// TGStat answers the first SetCallback with the code the URL must return,
+// Register sets it on receiver and calls SetCallback again.
+// The receiver must already be served at callbackUrl.
+func Register(ctx context.Context, receiver *Receiver, callbackUrl string) error {
+	return getClient().Register(ctx, receiver, callbackUrl)
+}
+
+// Register sets the callback URL, answering the verification through receiver.
+// TGStat answers the first SetCallback with the code the URL must return,
+// Register sets it on receiver and calls SetCallback again.
+// The receiver must already be served at callbackUrl.
+func (c Client) Register(ctx context.Context, receiver *Receiver, callbackUrl string) error {
+	for attempt := 0; attempt < 2; attempt++ {
+		result, _, err := c.SetCallback(ctx, callbackUrl)
+		if err != nil {
+			return fmt.Errorf("callback: register: %w", err)
+		}
+		if result.Status == "ok" {
+			return nil
+		}
+		if result.VerifyCode == "" || result.VerifyCode == receiver.VerifyCode() {
+			return fmt.Errorf("callback: register: %w", &tgstat.APIError{Message: result.Error})
+		}
+		receiver.SetVerifyCode(result.VerifyCode)
+	}
+	return errors.New("callback: register: verification failed")
+}
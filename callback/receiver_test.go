@@ -0,0 +1,356 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func deliver(handler http.Handler, method, body string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(method, "/callback", strings.NewReader(body)))
+	return recorder
+}
+
+func TestReceiver(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test verification answers the code", func(t *testing.T) {
+		receiver := NewReceiver(HandlerFunc(func(context.Context, Event) error { return nil }))
+
+		Expect(deliver(receiver, http.MethodPost, `{"verify_code":"CODE_1"}`).Body.String()).To(Equal("CODE_1"))
+		Expect(deliver(receiver, http.MethodPost, `verify_code=CODE_2`).Body.String()).To(Equal("CODE_2"))
+
+		receiver.SetVerifyCode("CODE_3")
+		Expect(deliver(receiver, http.MethodPost, ``).Body.String()).To(Equal("CODE_3"))
+	})
+
+	t.Run("Test events are passed to handler", func(t *testing.T) {
+		var events []Event
+		var errs []error
+		receiver := NewReceiver(HandlerFunc(func(_ context.Context, event Event) error {
+			if event.EventID == 2 {
+				return errors.New("busy")
+			}
+			events = append(events, event)
+			return nil
+		}))
+		receiver.OnError = func(_ *http.Request, err error) { errs = append(errs, err) }
+
+		response := deliver(receiver, http.MethodPost, `{"event_id":1,"event_type":"new_post","post":{"id":5,"is_deleted":0}}`)
+		Expect(response.Code).To(Equal(http.StatusOK))
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Post.ID).To(Equal(int64(5)))
+
+		response = deliver(receiver, http.MethodPost, `{"event_id":2,"event_type":"new_post"}`)
+		Expect(response.Code).To(Equal(http.StatusInternalServerError))
+		Expect(deliver(receiver, http.MethodPost, `{"event_type":"new_post","post":[]}`).Code).To(Equal(http.StatusBadRequest))
+		Expect(deliver(receiver, http.MethodPost, `{broken`).Code).To(Equal(http.StatusBadRequest))
+		Expect(deliver(receiver, http.MethodGet, ``).Code).To(Equal(http.StatusMethodNotAllowed))
+		Expect(errs).To(HaveLen(4))
+		Expect(errs[0]).To(MatchError("event 2: busy"))
+	})
+}
+
+func TestRegister(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test register answers the verify code", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		receiver := NewReceiver(HandlerFunc(func(context.Context, Event) error { return nil }))
+		testServer.Mux.Handle("/callback", receiver)
+
+		testServer.Mux.HandleFunc(endpoints.SetCallbackURL, func(w http.ResponseWriter, r *http.Request) {
+			// verify the URL like TGStat does
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			resp, err := http.Post(body["callback_url"], "application/json", strings.NewReader(`{}`))
+			Expect(err).ToNot(HaveOccurred())
+			answer, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+
+			w.Header().Set("Content-Type", "application/json")
+			if string(answer) != "TGSTAT_VERIFY_CODE_1" {
+				json.NewEncoder(w).Encode(tgstat.SetCallbackVerificationResult{Status: "error", Error: "wrong verify code", VerifyCode: "TGSTAT_VERIFY_CODE_1"})
+				return
+			}
+			json.NewEncoder(w).Encode(tgstat.SetCallbackSuccessResult{Status: "ok"})
+		})
+
+		Expect(Register(context.Background(), receiver, testServer.URL+"/callback")).To(Succeed())
+		Expect(receiver.VerifyCode()).To(Equal("TGSTAT_VERIFY_CODE_1"))
+	})
+
+	t.Run("Test register fails when the code is not answered", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		testServer.Mux.HandleFunc(endpoints.SetCallbackURL, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tgstat.SetCallbackVerificationResult{Status: "error", Error: "wrong verify code", VerifyCode: "CODE"})
+		})
+
+		err := Register(context.Background(), NewReceiver(nil), "https://example.com/callback")
+		Expect(err).To(MatchError("callback: register: wrong verify code"))
+	})
+}
+
+func TestReconcile(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test reconcile subscribes, updates and prunes", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+
+		var mu sync.Mutex
+		var calls []string
+		record := func(path string) func(w http.ResponseWriter, r *http.Request) {
+			return func(w http.ResponseWriter, r *http.Request) {
+				var body map[string]string
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				mu.Lock()
+				calls = append(calls, path+" "+body["channel_id"]+body["q"]+" "+body["subscription_id"]+" "+body["event_types"])
+				mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"status":"ok"}`))
+			}
+		}
+		testServer.Mux.HandleFunc(endpoints.SubscribeChannel, record("channel"))
+		testServer.Mux.HandleFunc(endpoints.SubscribeWord, record("word"))
+		testServer.Mux.HandleFunc(endpoints.Unsubscribe, record("unsubscribe"))
+		testServer.Mux.HandleFunc(endpoints.SubscriptionsList, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tgstat.SubscriptionList{Status: "ok", Response: tgstat.SubscriptionListResponse{
+				TotalCount: 4,
+				Subscriptions: []tgstat.Subscription{
+					{SubscriptionId: 1, Type: "channel", EventTypes: tgstat.EventTypes{tgstat.EventNewPost}, Channel: tgstat.Channel{ID: 10, Username: "@kept"}},
+					{SubscriptionId: 2, Type: "channel", EventTypes: tgstat.EventTypes{tgstat.EventNewPost}, Channel: tgstat.Channel{ID: 20, Link: "t.me/changed"}},
+					{SubscriptionId: 3, Type: "keyword", EventTypes: tgstat.EventTypes{tgstat.EventNewPost}, Keyword: tgstat.Keyword{Q: "bitcoin"}},
+					{SubscriptionId: 4, Type: "keyword", EventTypes: tgstat.EventTypes{tgstat.EventNewPost}, Keyword: tgstat.Keyword{Q: "stale"}},
+				},
+			}})
+		})
+
+		result, err := Reconcile(context.Background(), ReconcileRequest{
+			Channels: []SubscribeChannelRequest{
+				{ChannelId: "https://t.me/Kept", EventTypes: tgstat.EventTypes{tgstat.EventNewPost}},
+				{ChannelId: "@changed", EventTypes: tgstat.EventTypes{tgstat.EventNewPost, tgstat.EventRemovePost}},
+				{ChannelId: "@new", EventTypes: tgstat.EventTypes{tgstat.EventNewPost}},
+			},
+			Words: []SubscribeWordRequest{{Q: "bitcoin", EventTypes: tgstat.EventTypes{tgstat.EventNewPost}}},
+			Prune: true,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result).To(Equal(&ReconcileResult{
+			Subscribed:   []string{"@new"},
+			Updated:      []string{"@changed"},
+			Unsubscribed: []int{4},
+			Unchanged:    2,
+		}))
+		Expect(calls).To(Equal([]string{
+			"channel @changed 2 new_post,remove_post",
+			"channel @new  new_post",
+			"unsubscribe  4 ",
+		}))
+	})
+}
+
+func TestReceiverSecurity(t *testing.T) {
+	RegisterTestingT(t)
+	ok := HandlerFunc(func(context.Context, Event) error { return nil })
+	event := `{"event_id":1,"event_type":"new_post","subscription_id":3,"subscription_type":"channel","post":{"id":5,"is_deleted":0},"channel":{"id":7}}`
+
+	t.Run("Test secret in the query", func(t *testing.T) {
+		receiver := NewReceiver(ok)
+		receiver.Secret = "s3cret"
+		var errs []error
+		receiver.OnError = func(_ *http.Request, err error) { errs = append(errs, err) }
+
+		callbackUrl, err := receiver.SecretURL("https://example.com/callback?a=1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(callbackUrl).To(Equal("https://example.com/callback?a=1&secret=s3cret"))
+
+		for target, status := range map[string]int{
+			"/callback?secret=s3cret": http.StatusOK,
+			"/callback/s3cret":        http.StatusNotFound,
+			"/callback?secret=wrong":  http.StatusNotFound,
+			"/callback":               http.StatusNotFound,
+		} {
+			recorder := httptest.NewRecorder()
+			receiver.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, strings.NewReader(event)))
+			Expect(recorder.Code).To(Equal(status), target)
+		}
+		Expect(errs).To(HaveLen(3))
+		Expect(errs[0]).To(MatchError(ContainSubstring("wrong secret")))
+	})
+
+	t.Run("Test source addresses", func(t *testing.T) {
+		receiver := NewReceiver(ok)
+		var err error
+		receiver.AllowedNetworks, err = ParsePrefixes([]string{"192.0.2.0/24", "2001:db8::1", "::ffff:203.0.113.0/120"})
+		Expect(err).ToNot(HaveOccurred())
+		receiver.TrustedProxies, err = ParsePrefixes([]string{"10.0.0.0/8"})
+		Expect(err).ToNot(HaveOccurred())
+		_, err = ParsePrefixes([]string{"192.0.2.300"})
+		Expect(err).To(HaveOccurred())
+
+		for _, test := range []struct {
+			remote, forwarded, real string
+			status                  int
+		}{
+			{"192.0.2.10:1234", "", "", http.StatusOK},
+			{"[2001:db8::1]:1234", "", "", http.StatusOK},
+			{"198.51.100.1:1234", "", "", http.StatusForbidden},
+			{"203.0.113.5:1234", "", "", http.StatusOK},
+			{"[::ffff:203.0.113.5]:1234", "", "", http.StatusOK},
+			// headers of untrusted peers are ignored
+			{"198.51.100.1:1234", "192.0.2.10", "", http.StatusForbidden},
+			{"10.0.0.1:1234", "198.51.100.1, 192.0.2.10, 10.0.0.2", "", http.StatusOK},
+			{"10.0.0.1:1234", "192.0.2.10, 198.51.100.1", "", http.StatusForbidden},
+			{"10.0.0.1:1234", "", "192.0.2.10", http.StatusOK},
+			{"10.0.0.1:1234", "", "", http.StatusForbidden},
+		} {
+			req := httptest.NewRequest(http.MethodPost, "/callback", strings.NewReader(event))
+			req.RemoteAddr = test.remote
+			if test.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", test.forwarded)
+			}
+			if test.real != "" {
+				req.Header.Set("X-Real-IP", test.real)
+			}
+			recorder := httptest.NewRecorder()
+			receiver.ServeHTTP(recorder, req)
+			Expect(recorder.Code).To(Equal(test.status), "%+v", test)
+		}
+	})
+
+	t.Run("Test body size and strict validation", func(t *testing.T) {
+		receiver := NewReceiver(ok)
+		receiver.MaxBodySize = 64
+		Expect(deliver(receiver, http.MethodPost, event).Code).To(Equal(http.StatusRequestEntityTooLarge))
+
+		receiver = NewReceiver(ok)
+		receiver.Strict = true
+		var errs []error
+		receiver.OnError = func(_ *http.Request, err error) { errs = append(errs, err) }
+		Expect(deliver(receiver, http.MethodPost, event).Code).To(Equal(http.StatusOK))
+		Expect(deliver(receiver, http.MethodPost, `{"verify_code":"CODE"}`).Body.String()).To(Equal("CODE"))
+
+		Expect(deliver(receiver, http.MethodPost, `{"event_id":1,"event_type":"new_post","extra":true}`).Code).To(Equal(http.StatusBadRequest))
+		Expect(errs[0]).To(MatchError(ContainSubstring("extra: unknown field")))
+		Expect(deliver(receiver, http.MethodPost, `{"event_id":"1","event_type":"new_post"}`).Code).To(Equal(http.StatusBadRequest))
+		Expect(deliver(receiver, http.MethodPost, `{"event_id":1,"event_type":"pin_post","subscription_id":3,"subscription_type":"channel","post":{"id":5}}`).Code).To(Equal(http.StatusBadRequest))
+		Expect(errs[2]).To(MatchError(ContainSubstring("event_type: must be a valid value")))
+		Expect(deliver(receiver, http.MethodPost, `{"event_id":1,"event_type":"new_post","subscription_type":"channel","post":{}}`).Code).To(Equal(http.StatusBadRequest))
+		Expect(errs[3]).To(MatchError(ContainSubstring("post.id: cannot be blank")))
+	})
+
+	t.Run("Test timeout bounds the handler", func(t *testing.T) {
+		receiver := NewReceiver(HandlerFunc(func(ctx context.Context, _ Event) error {
+			<-ctx.Done()
+			return ctx.Err()
+		}))
+		receiver.Timeout = 10 * time.Millisecond
+		Expect(deliver(receiver, http.MethodPost, event).Code).To(Equal(http.StatusInternalServerError))
+	})
+}
+
+func TestReceiverWithSimulator(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test simulated deliveries pass strict validation", func(t *testing.T) {
+		var mu sync.Mutex
+		var events []Event
+		receiver := NewReceiver(HandlerFunc(func(_ context.Context, event Event) error {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event)
+			return nil
+		}))
+		receiver.Strict = true
+		receiver.OnError = func(_ *http.Request, err error) { t.Errorf("rejected: %v", err) }
+		testServer := httptest.NewServer(receiver)
+		defer testServer.Close()
+
+		simulator := server.NewSimulator(server.SimulatorConfig{
+			URL:        testServer.URL,
+			Seed:       42,
+			Keywords:   []string{"bitcoin"},
+			Mix:        map[string]int{"new_post": 4, "edit_post": 2, "remove_post": 2, "keyword": 2},
+			Redelivery: 0.3,
+			Reorder:    5,
+		})
+		result, err := simulator.Run(context.Background(), 40)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Failed).To(BeEmpty())
+		Expect(len(result.Delivered)).To(BeNumerically(">", 40))
+		Expect(events).To(HaveLen(len(result.Delivered)))
+
+		kinds := map[string]int{}
+		seen := map[int64]bool{}
+		redelivered, reordered := false, false
+		for i, event := range events {
+			kinds[string(event.EventType)+"/"+string(event.SubscriptionType)]++
+			redelivered = redelivered || seen[event.EventID]
+			reordered = reordered || (i > 0 && event.EventID < events[i-1].EventID)
+			seen[event.EventID] = true
+			Expect(event.EventID).To(Equal(result.Delivered[i].EventID))
+			if event.EventType == tgstat.EventRemovePost {
+				Expect(event.Post.IsDeleted).To(BeTrue())
+			}
+			if event.SubscriptionType == tgstat.SubscriptionKeyword {
+				Expect(event.Keyword).To(Equal("bitcoin"))
+				Expect(event.Post.Text).To(ContainSubstring("bitcoin"))
+			}
+		}
+		Expect(seen).To(HaveLen(40))
+		Expect(redelivered).To(BeTrue())
+		Expect(reordered).To(BeTrue())
+		Expect(kinds).To(HaveKey("new_post/channel"))
+		Expect(kinds).To(HaveKey("edit_post/channel"))
+		Expect(kinds).To(HaveKey("remove_post/channel"))
+		Expect(kinds).To(HaveKey("new_post/keyword"))
+	})
+
+	t.Run("Test simulator reports failures", func(t *testing.T) {
+		fails := 0
+		receiver := NewReceiver(HandlerFunc(func(_ context.Context, event Event) error {
+			if event.EventID == 2 && fails < 3 {
+				fails++
+				return errors.New("busy")
+			}
+			return nil
+		}))
+		receiver.SetVerifyCode("unused")
+		testServer := httptest.NewServer(receiver)
+		defer testServer.Close()
+
+		simulator := server.NewSimulator(server.SimulatorConfig{URL: testServer.URL, Seed: 1, Retries: 1})
+		result, err := simulator.Run(context.Background(), 3)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(result.Failed).To(HaveLen(1))
+		Expect(result.Failed[0].EventID).To(Equal(int64(2)))
+		Expect(result.Attempts).To(Equal(4))
+
+		answering := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "ok")
+		}))
+		defer answering.Close()
+		err = server.NewSimulator(server.SimulatorConfig{URL: answering.URL, VerifyCode: "CODE"}).Verify(context.Background())
+		Expect(err).To(MatchError(`simulator: verification: answered "ok" instead of "CODE"`))
+	})
+}
@@ -0,0 +1,155 @@
+package callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ReconcileRequest is the set of subscriptions the account should have.
+type ReconcileRequest struct {
+	Channels []SubscribeChannelRequest
+	Words    []SubscribeWordRequest
+	// Prune unsubscribes the existing subscriptions that are not requested.
+	Prune bool
+}
+
+// ReconcileResult lists what Reconcile changed.
+type ReconcileResult struct {
+	// Subscribed and Updated hold the channel or query of the subscriptions.
+	Subscribed []string
+	Updated    []string
+	// Unsubscribed holds subscription IDs.
+	Unsubscribed []int
+	Unchanged    int
+}
+
+// Reconcile lists the subscriptions, subscribes the missing ones, updates the
+// event types of the ones that differ and, with Prune, removes the others.
+// Channels are matched by ID, username or link, keywords by query.
+func Reconcile(ctx context.Context, request ReconcileRequest) (*ReconcileResult, error) {
+	return getClient().Reconcile(ctx, request)
+}
+
+// Reconcile lists the subscriptions, subscribes the missing ones, updates the
+// event types of the ones that differ and, with Prune, removes the others.
+// Channels are matched by ID, username or link, keywords by query.
+func (c Client) Reconcile(ctx context.Context, request ReconcileRequest) (*ReconcileResult, error) {
+	list, _, err := c.SubscriptionsList(ctx, SubscriptionsListRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("callback: reconcile: %w", err)
+	}
+	existing := list.Response.Subscriptions
+	kept := make(map[int]bool, len(existing))
+	result := &ReconcileResult{}
+	var errs []error
+
+	for _, channel := range request.Channels {
+		found := findSubscription(existing, func(s tgstat.Subscription) bool {
+			return tgstat.SubscriptionType(s.Type) == tgstat.SubscriptionChannel && sameChannel(s.Channel, channel.ChannelId)
+		})
+		switch {
+		case found == nil:
+			_, _, err = c.SubscribeChannel(ctx, channel)
+			result.Subscribed = appendIf(result.Subscribed, channel.ChannelId, err)
+		case !sameEventTypes(found.EventTypes, channel.EventTypes):
+			kept[found.SubscriptionId] = true
+			id := strconv.Itoa(found.SubscriptionId)
+			channel.SubscriptionId = &id
+			_, _, err = c.SubscribeChannel(ctx, channel)
+			result.Updated = appendIf(result.Updated, channel.ChannelId, err)
+		default:
+			kept[found.SubscriptionId] = true
+			result.Unchanged++
+			err = nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("callback: reconcile channel %s: %w", channel.ChannelId, err))
+		}
+	}
+
+	for _, word := range request.Words {
+		found := findSubscription(existing, func(s tgstat.Subscription) bool {
+			return tgstat.SubscriptionType(s.Type) == tgstat.SubscriptionKeyword && s.Keyword.Q == word.Q
+		})
+		switch {
+		case found == nil:
+			_, _, err = c.SubscribeWord(ctx, word)
+			result.Subscribed = appendIf(result.Subscribed, word.Q, err)
+		case !sameEventTypes(found.EventTypes, word.EventTypes):
+			kept[found.SubscriptionId] = true
+			id := strconv.Itoa(found.SubscriptionId)
+			word.SubscriptionId = &id
+			_, _, err = c.SubscribeWord(ctx, word)
+			result.Updated = appendIf(result.Updated, word.Q, err)
+		default:
+			kept[found.SubscriptionId] = true
+			result.Unchanged++
+			err = nil
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("callback: reconcile keyword %q: %w", word.Q, err))
+		}
+	}
+
+	if request.Prune {
+		for _, subscription := range existing {
+			if kept[subscription.SubscriptionId] {
+				continue
+			}
+			if _, _, err := c.Unsubscribe(ctx, strconv.Itoa(subscription.SubscriptionId)); err != nil {
+				errs = append(errs, fmt.Errorf("callback: reconcile unsubscribe %d: %w", subscription.SubscriptionId, err))
+				continue
+			}
+			result.Unsubscribed = append(result.Unsubscribed, subscription.SubscriptionId)
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
+
+func findSubscription(subscriptions []tgstat.Subscription, match func(tgstat.Subscription) bool) *tgstat.Subscription {
+	for i := range subscriptions {
+		if match(subscriptions[i]) {
+			return &subscriptions[i]
+		}
+	}
+	return nil
+}
+
+func appendIf(values []string, value string, err error) []string {
+	if err != nil {
+		return values
+	}
+	return append(values, value)
+}
+
+// sameChannel reports whether channelId, as accepted by SubscribeChannel,
+// designates the channel.
+func sameChannel(channel tgstat.Channel, channelId string) bool {
+	id := normalizeChannel(channelId)
+	return id == strconv.Itoa(channel.ID) ||
+		(channel.Username != "" && id == normalizeChannel(channel.Username)) ||
+		(channel.Link != "" && id == normalizeChannel(channel.Link))
+}
+
+func normalizeChannel(channel string) string {
+	channel = strings.ToLower(strings.TrimSpace(channel))
+	for _, prefix := range []string{"https://", "http://", "t.me/", "telegram.me/", "@"} {
+		channel = strings.TrimPrefix(channel, prefix)
+	}
+	return strings.TrimSuffix(channel, "/")
+}
+
+func sameEventTypes(a, b tgstat.EventTypes) bool {
+	set := func(types tgstat.EventTypes) []string {
+		values := strings.Split(types.String(), ",")
+		sort.Strings(values)
+		return values
+	}
+	return strings.Join(set(a), ",") == strings.Join(set(b), ",")
+}
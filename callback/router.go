@@ -0,0 +1,251 @@
+package callback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultRouterPrefix is the path the tenants of a Router are served under.
+const DefaultRouterPrefix = "/callback/"
+
+var tenantName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Router serves the callback URLs of several TGStat accounts, each tenant at
+// the prefix of the router followed by its name. Deliveries of a tenant are handled by its own
+// Receiver and Handler: a failing, panicking or slow handler only affects
+// its tenant.
+type Router struct {
+	// OnError, when set, is called with the rejected requests and handler errors of every tenant.
+	OnError func(tenant string, r *http.Request, err error)
+
+	prefix  string
+	mu      sync.RWMutex
+	tenants map[string]*Tenant
+}
+
+// NewRouter returns a router serving tenants under prefix, DefaultRouterPrefix when empty.
+func NewRouter(prefix string) *Router {
+	if prefix == "" {
+		prefix = DefaultRouterPrefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &Router{prefix: prefix, tenants: make(map[string]*Tenant)}
+}
+
+// Tenant is an account served by a Router.
+type Tenant struct {
+	Name  string
+	Token string
+	// Client is authenticated with Token.
+	Client Client
+	// Receiver serves the deliveries of the tenant, its security options can
+	// be set before serving. Its OnError is set by the router.
+	Receiver *Receiver
+	// MaxInFlight limits the deliveries handled at once, further ones are
+	// answered 503 for TGStat to retry. No limit when zero.
+	MaxInFlight int
+
+	handler  Handler
+	inFlight atomic.Int64
+	stats    struct {
+		received, failed, rejected, panics, busy atomic.Int64
+	}
+}
+
+// TenantStats are the delivery counters of a tenant.
+type TenantStats struct {
+	Name string
+	// Received counts the events handled successfully.
+	Received int64
+	// Failed counts the handler errors, including Panics.
+	Failed int64
+	Panics int64
+	// Rejected counts the requests refused by the receiver.
+	Rejected int64
+	// Busy counts the deliveries refused because of MaxInFlight.
+	Busy     int64
+	InFlight int64
+}
+
+// Add serves a tenant authenticated with token, passing its events to handler.
+func (r *Router) Add(name, token string, handler Handler) (*Tenant, error) {
+	if !tenantName.MatchString(name) {
+		return nil, fmt.Errorf("callback: tenant name %q: only letters, digits, - and _ are allowed", name)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("callback: tenant %s: token is empty", name)
+	}
+	if handler == nil {
+		return nil, fmt.Errorf("callback: tenant %s: handler is nil", name)
+	}
+
+	tenant := &Tenant{Name: name, Token: token, Client: NewClient(token), handler: handler}
+	tenant.Receiver = NewReceiver(HandlerFunc(tenant.handle))
+	tenant.Receiver.OnError = func(req *http.Request, err error) {
+		if !errors.As(err, new(*handlerError)) {
+			tenant.stats.rejected.Add(1)
+		}
+		r.report(name, req, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.tenants[name]; exists {
+		return nil, fmt.Errorf("callback: tenant %s already exists", name)
+	}
+	r.tenants[name] = tenant
+	return tenant, nil
+}
+
+// Remove stops serving a tenant.
+func (r *Router) Remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.tenants, name)
+}
+
+// Tenant returns the tenant named name.
+func (r *Router) Tenant(name string) (*Tenant, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tenant, ok := r.tenants[name]
+	return tenant, ok
+}
+
+// Tenants returns the tenants sorted by name.
+func (r *Router) Tenants() []*Tenant {
+	r.mu.RLock()
+	tenants := make([]*Tenant, 0, len(r.tenants))
+	for _, tenant := range r.tenants {
+		tenants = append(tenants, tenant)
+	}
+	r.mu.RUnlock()
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].Name < tenants[j].Name })
+	return tenants
+}
+
+// Stats returns the counters of every tenant, sorted by name.
+func (r *Router) Stats() []TenantStats {
+	tenants := r.Tenants()
+	stats := make([]TenantStats, len(tenants))
+	for i, tenant := range tenants {
+		stats[i] = tenant.Stats()
+	}
+	return stats
+}
+
+// ServeHTTP passes the request to the receiver of the tenant named by the
+// path segment following the prefix.
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rest, found := strings.CutPrefix(req.URL.Path, r.prefix)
+	name, _, _ := strings.Cut(rest, "/")
+	tenant, ok := r.Tenant(name)
+	if !found || !ok {
+		r.report(name, req, fmt.Errorf("callback: unknown tenant on %s", req.URL.Path))
+		http.NotFound(w, req)
+		return
+	}
+
+	inFlight := tenant.inFlight.Add(1)
+	defer tenant.inFlight.Add(-1)
+	if tenant.MaxInFlight > 0 && inFlight > int64(tenant.MaxInFlight) {
+		tenant.stats.busy.Add(1)
+		r.report(name, req, errors.New("callback: too many deliveries in flight"))
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+	tenant.Receiver.ServeHTTP(w, req)
+}
+
+// Register registers the callback URL of every tenant, baseURL followed by
+// the path of the tenant. A failing tenant does not stop the others, the
+// errors are joined.
+func (r *Router) Register(ctx context.Context, baseURL string) error {
+	var errs []error
+	for _, tenant := range r.Tenants() {
+		callbackUrl, err := r.URL(tenant.Name, baseURL)
+		if err == nil {
+			err = tenant.Client.Register(ctx, tenant.Receiver, callbackUrl)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("tenant %s: %w", tenant.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// URL returns the callback URL of the tenant for the router served at baseURL,
+// with the secret of its receiver.
+func (r *Router) URL(name, baseURL string) (string, error) {
+	tenant, ok := r.Tenant(name)
+	if !ok {
+		return "", fmt.Errorf("callback: unknown tenant %s", name)
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", fmt.Errorf("callback: %w", err)
+	}
+	return tenant.Receiver.SecretURL(base.JoinPath(r.prefix, name).String())
+}
+
+func (r *Router) report(tenant string, req *http.Request, err error) {
+	if r.OnError != nil {
+		r.OnError(tenant, req, err)
+	}
+}
+
+// handlerError tells the handler errors from the rejected requests.
+type handlerError struct{ err error }
+
+func (e *handlerError) Error() string { return e.err.Error() }
+func (e *handlerError) Unwrap() error { return e.err }
+
+type tenantKey struct{}
+
+// TenantFromContext returns the tenant of the delivery handled with ctx, for
+// handlers shared by several tenants.
+func TenantFromContext(ctx context.Context) (*Tenant, bool) {
+	tenant, ok := ctx.Value(tenantKey{}).(*Tenant)
+	return tenant, ok
+}
+
+// handle calls the handler of the tenant, turning a panic into an error.
+func (t *Tenant) handle(ctx context.Context, event Event) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			t.stats.panics.Add(1)
+			err = fmt.Errorf("callback: tenant %s: handler panic: %v\n%s", t.Name, recovered, debug.Stack())
+		}
+		if err != nil {
+			t.stats.failed.Add(1)
+			err = &handlerError{err}
+		} else {
+			t.stats.received.Add(1)
+		}
+	}()
+	return t.handler.HandleEvent(context.WithValue(ctx, tenantKey{}, t), event)
+}
+
+// Stats returns the delivery counters of the tenant.
+func (t *Tenant) Stats() TenantStats {
+	return TenantStats{
+		Name:     t.Name,
+		Received: t.stats.received.Load(),
+		Failed:   t.stats.failed.Load(),
+		Panics:   t.stats.panics.Load(),
+		Rejected: t.stats.rejected.Load(),
+		Busy:     t.stats.busy.Load(),
+		InFlight: t.inFlight.Load(),
+	}
+}
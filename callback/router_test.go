@@ -0,0 +1,143 @@
+package callback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRouter(t *testing.T) {
+	RegisterTestingT(t)
+	delivery := `{"event_id":1,"event_type":"new_post","post":{"id":5}}`
+
+	t.Run("Test tenants are routed and isolated", func(t *testing.T) {
+		router := NewRouter("")
+		var mu sync.Mutex
+		handled := map[string]int{}
+		record := HandlerFunc(func(ctx context.Context, event Event) error {
+			tenant, ok := TenantFromContext(ctx)
+			Expect(ok).To(BeTrue())
+			mu.Lock()
+			defer mu.Unlock()
+			handled[tenant.Name]++
+			return nil
+		})
+		var errs []string
+		router.OnError = func(tenant string, _ *http.Request, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, tenant+": "+err.Error())
+		}
+
+		_, err := router.Add("acme", "token-a", record)
+		Expect(err).ToNot(HaveOccurred())
+		_, err = router.Add("globex", "token-g", HandlerFunc(func(context.Context, Event) error { panic("boom") }))
+		Expect(err).ToNot(HaveOccurred())
+		_, err = router.Add("acme", "token-a", record)
+		Expect(err).To(MatchError("callback: tenant acme already exists"))
+		_, err = router.Add("a/b", "token", record)
+		Expect(err).To(HaveOccurred())
+
+		serve := func(target string) int {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, strings.NewReader(delivery)))
+			return recorder.Code
+		}
+		Expect(serve("/callback/acme")).To(Equal(http.StatusOK))
+		Expect(serve("/callback/globex")).To(Equal(http.StatusInternalServerError))
+		Expect(serve("/callback/acme")).To(Equal(http.StatusOK))
+		Expect(serve("/callback/initech")).To(Equal(http.StatusNotFound))
+		Expect(handled).To(Equal(map[string]int{"acme": 2}))
+
+		stats := router.Stats()
+		Expect(stats).To(Equal([]TenantStats{
+			{Name: "acme", Received: 2},
+			{Name: "globex", Failed: 1, Panics: 1},
+		}))
+		Expect(errs).To(HaveLen(2))
+		Expect(errs[0]).To(HavePrefix("globex: event 1: callback: tenant globex: handler panic: boom"))
+		Expect(errs[1]).To(Equal("initech: callback: unknown tenant on /callback/initech"))
+
+		router.Remove("acme")
+		Expect(serve("/callback/acme")).To(Equal(http.StatusNotFound))
+	})
+
+	t.Run("Test in flight limit and tenant security", func(t *testing.T) {
+		router := NewRouter("/hooks")
+		release := make(chan struct{})
+		started := make(chan struct{})
+		tenant, err := router.Add("acme", "token-a", HandlerFunc(func(context.Context, Event) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		}))
+		Expect(err).ToNot(HaveOccurred())
+		tenant.MaxInFlight = 1
+		tenant.Receiver.Secret = "s3cret"
+
+		codes := make(chan int, 1)
+		go func() {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/hooks/acme?secret=s3cret", strings.NewReader(delivery)))
+			codes <- recorder.Code
+		}()
+		<-started
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/hooks/acme?secret=s3cret", strings.NewReader(delivery)))
+		Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+		close(release)
+		Expect(<-codes).To(Equal(http.StatusOK))
+
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/hooks/acme", strings.NewReader(delivery)))
+		Expect(recorder.Code).To(Equal(http.StatusNotFound))
+		Expect(tenant.Stats()).To(Equal(TenantStats{Name: "acme", Received: 1, Rejected: 1, Busy: 1}))
+	})
+
+	t.Run("Test register uses the token of every tenant", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		var mu sync.Mutex
+		registered := map[string]string{}
+		testServer.Mux.HandleFunc(endpoints.SetCallbackURL, func(w http.ResponseWriter, r *http.Request) {
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			w.Header().Set("Content-Type", "application/json")
+			if body["token"] == "token-g" {
+				json.NewEncoder(w).Encode(tgstat.SetCallbackVerificationResult{Status: "error", Error: "wrong token"})
+				return
+			}
+			mu.Lock()
+			registered[body["token"]] = body["callback_url"]
+			mu.Unlock()
+			json.NewEncoder(w).Encode(tgstat.SetCallbackSuccessResult{Status: "ok"})
+		})
+
+		router := NewRouter("")
+		noop := HandlerFunc(func(context.Context, Event) error { return nil })
+		acme, _ := router.Add("acme", "token-a", noop)
+		acme.Receiver.Secret = "s3cret"
+		router.Add("globex", "token-g", noop)
+		router.Add("initech", "token-i", noop)
+
+		err := router.Register(context.Background(), "https://hooks.example.com/base/")
+		Expect(err).To(MatchError("tenant globex: callback: register: wrong token"))
+		var apiErr *tgstat.APIError
+		Expect(errors.As(err, &apiErr)).To(BeTrue())
+		Expect(registered).To(Equal(map[string]string{
+			"token-a": "https://hooks.example.com/base/callback/acme?secret=s3cret",
+			"token-i": "https://hooks.example.com/base/callback/initech",
+		}))
+	})
+}
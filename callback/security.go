@@ -0,0 +1,200 @@
+package callback
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	validation "github.com/go-ozzo/ozzo-validation/v4"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultSecretParam is the query parameter carrying Receiver.Secret.
+const DefaultSecretParam = "secret"
+
+// ParsePrefixes parses IP addresses and CIDR ranges, for
+// Receiver.AllowedNetworks and Receiver.TrustedProxies. IPv4-mapped IPv6
+// addresses and ranges are converted to IPv4, as source addresses are.
+func ParsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return nil, fmt.Errorf("callback: %w", err)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("callback: %w", err)
+		}
+		if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// SecretURL returns callbackUrl with the secret of the receiver in the
+// SecretParam query parameter, the URL to register with SetCallback.
+func (r *Receiver) SecretURL(callbackUrl string) (string, error) {
+	if r.Secret == "" {
+		return callbackUrl, nil
+	}
+	parsed, err := url.Parse(callbackUrl)
+	if err != nil {
+		return "", fmt.Errorf("callback: %w", err)
+	}
+	query := parsed.Query()
+	query.Set(r.secretParam(), r.Secret)
+	parsed.RawQuery = query.Encode()
+	return parsed.String(), nil
+}
+
+func (r *Receiver) secretParam() string {
+	if r.SecretParam != "" {
+		return r.SecretParam
+	}
+	return DefaultSecretParam
+}
+
+// authorize checks the secret and the source address of the request, it
+// returns the status to answer on failure.
+func (r *Receiver) authorize(req *http.Request) (int, error) {
+	if r.Secret != "" {
+		given := req.URL.Query().Get(r.secretParam())
+		if subtle.ConstantTimeCompare([]byte(given), []byte(r.Secret)) != 1 {
+			// do not reveal the endpoint
+			return http.StatusNotFound, fmt.Errorf("callback: wrong secret on %s", req.URL.Path)
+		}
+	}
+
+	if len(r.AllowedNetworks) != 0 {
+		addr, err := r.clientAddr(req)
+		if err != nil {
+			return http.StatusForbidden, err
+		}
+		if !contains(r.AllowedNetworks, addr) {
+			return http.StatusForbidden, fmt.Errorf("callback: source %s not allowed", addr)
+		}
+	}
+	return 0, nil
+}
+
+// clientAddr returns the address of the client. Behind a trusted proxy, it is
+// the last address of X-Forwarded-For not belonging to a trusted proxy, or X-Real-IP.
+func (r *Receiver) clientAddr(req *http.Request) (netip.Addr, error) {
+	peer, err := netip.ParseAddrPort(req.RemoteAddr)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("callback: remote address %q: %w", req.RemoteAddr, err)
+	}
+	addr := peer.Addr().Unmap()
+	if !contains(r.TrustedProxies, addr) {
+		return addr, nil
+	}
+
+	if forwarded := req.Header.Values("X-Forwarded-For"); len(forwarded) != 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				return netip.Addr{}, fmt.Errorf("callback: X-Forwarded-For %q: %w", hops[i], err)
+			}
+			addr = hop.Unmap()
+			if !contains(r.TrustedProxies, addr) {
+				break
+			}
+		}
+		return addr, nil
+	}
+	if real := req.Header.Get("X-Real-IP"); real != "" {
+		hop, err := netip.ParseAddr(strings.TrimSpace(real))
+		if err != nil {
+			return netip.Addr{}, fmt.Errorf("callback: X-Real-IP %q: %w", real, err)
+		}
+		return hop.Unmap(), nil
+	}
+	return addr, nil
+}
+
+func contains(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// eventFields are the JSON types of the fields of a delivery.
+var eventFields = map[string]byte{
+	"event_id":          'n',
+	"event_type":        's',
+	"subscription_id":   'n',
+	"subscription_type": 's',
+	"post":              'o',
+	"channel":           'o',
+	"keyword":           's',
+}
+
+// validateEvent checks the fields of a delivery and the values of the decoded event.
+func validateEvent(body []byte, event Event) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return err
+	}
+	var problems []string
+	for name, value := range fields {
+		kind, known := eventFields[name]
+		switch {
+		case !known:
+			problems = append(problems, fmt.Sprintf("%s: unknown field", name))
+		case jsonKind(value) != kind:
+			problems = append(problems, fmt.Sprintf("%s: unexpected value %s", name, value))
+		}
+	}
+	if len(problems) != 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+
+	return validation.Errors{
+		"event_id":          validation.Validate(event.EventID, validation.Required, validation.Min(int64(1))),
+		"event_type":        validation.Validate(event.EventType, validation.Required, validation.In(tgstat.EventNewPost, tgstat.EventEditPost, tgstat.EventRemovePost)),
+		"subscription_id":   validation.Validate(event.SubscriptionID, validation.Required, validation.Min(1)),
+		"subscription_type": validation.Validate(event.SubscriptionType, validation.Required, validation.In(tgstat.SubscriptionChannel, tgstat.SubscriptionKeyword)),
+		"post.id":           validation.Validate(event.Post.ID, validation.Required, validation.Min(int64(1))),
+	}.Filter()
+}
+
+// jsonKind returns n, s, o, a, b or z for a number, string, object, array, boolean or null.
+func jsonKind(value json.RawMessage) byte {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 {
+		return 0
+	}
+	switch value[0] {
+	case '"':
+		return 's'
+	case '{':
+		return 'o'
+	case '[':
+		return 'a'
+	case 't', 'f':
+		return 'b'
+	case 'n':
+		return 'z'
+	default:
+		return 'n'
+	}
+}
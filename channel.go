@@ -1,25 +1,74 @@
 package tgstat_go
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
 type ChannelResponse struct {
-	Id                int         `json:"id"`
-	Link              string      `json:"link"`
-	Username          string      `json:"username"`
-	Title             string      `json:"title"`
-	About             string      `json:"about"`
-	Category          string      `json:"category"`
-	Country           string      `json:"country"`
-	Language          string      `json:"Language"`
-	Image100          string      `json:"image100"`
-	Image640          string      `json:"image640"`
-	ParticipantsCount int         `json:"participants_count"`
-	TGStatRestriction interface{} `json:"tgstat_restrictions"`
-}
-
-type TGStatRestrictions struct {
+	Id                int          `json:"id"`
+	Link              string       `json:"link"`
+	Username          string       `json:"username"`
+	Title             string       `json:"title"`
+	About             string       `json:"about"`
+	Category          string       `json:"category"`
+	Country           string       `json:"country"`
+	Language          string       `json:"Language"`
+	Image100          string       `json:"image100"`
+	Image640          string       `json:"image640"`
+	ParticipantsCount int          `json:"participants_count"`
+	TGStatRestriction Restrictions `json:"tgstat_restrictions"`
+}
+
+// Restrictions are the labels TGStat puts on suspicious channels.
+type Restrictions struct {
 	RedLabel   bool `json:"red_label"`
 	BlackLabel bool `json:"black_label"`
 }
 
+// TGStatRestrictions is kept for compatibility, use Restrictions.
+type TGStatRestrictions = Restrictions
+
+// UnmarshalJSON accepts an object, or an array TGStat sends empty for
+// channels without restrictions. Items of the array are label names or
+// objects, unknown items are skipped.
+func (r *Restrictions) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	*r = Restrictions{}
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+	case bytes.HasPrefix(data, []byte("[")):
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return fmt.Errorf("tgstat_restrictions: %w", err)
+		}
+		for _, item := range items {
+			var label string
+			if err := json.Unmarshal(item, &label); err == nil {
+				r.RedLabel = r.RedLabel || label == "red_label"
+				r.BlackLabel = r.BlackLabel || label == "black_label"
+				continue
+			}
+			var labels Restrictions
+			if err := json.Unmarshal(item, &labels); err == nil {
+				r.RedLabel = r.RedLabel || labels.RedLabel
+				r.BlackLabel = r.BlackLabel || labels.BlackLabel
+			}
+		}
+		return nil
+	}
+
+	type restrictions Restrictions
+	var value restrictions
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("tgstat_restrictions: %w", err)
+	}
+	*r = Restrictions(value)
+	return nil
+}
+
 type ChannelResponseResult struct {
 	Status   string          `json:"status"`
 	Response ChannelResponse `json:"response"`
@@ -63,15 +112,15 @@ type ChannelStatResponse struct {
 }
 
 type ChannelPostsWithChannelResponseItem struct {
-	ID            int64        `json:"id"`
-	Date          int          `json:"date"`
-	Views         int          `json:"views"`
-	Link          string       `json:"link"`
-	ChannelID     int          `json:"channel_id"`
-	ForwardedFrom interface{}  `json:"forwarded_from"`
-	IsDeleted     int          `json:"is_deleted"`
-	Text          string       `json:"text"`
-	Media         ChannelMedia `json:"media"`
+	ID            int64         `json:"id"`
+	Date          int           `json:"date"`
+	Views         int           `json:"views"`
+	Link          string        `json:"link"`
+	ChannelID     int           `json:"channel_id"`
+	ForwardedFrom ForwardSource `json:"forwarded_from"`
+	IsDeleted     int           `json:"is_deleted"`
+	Text          string        `json:"text"`
+	Media         ChannelMedia  `json:"media"`
 }
 
 type ChannelMedia struct {
@@ -104,15 +153,15 @@ type ChannelPostsWithChannelResult struct {
 }
 
 type ChannelPostsResponseItem struct {
-	ID            int64        `json:"id"`
-	Date          int          `json:"date"`
-	Views         int          `json:"views"`
-	Link          string       `json:"link"`
-	ChannelID     int          `json:"channel_id"`
-	ForwardedFrom string       `json:"forwarded_from"`
-	IsDeleted     int          `json:"is_deleted"`
-	Text          string       `json:"text"`
-	Media         ChannelMedia `json:"media"`
+	ID            int64         `json:"id"`
+	Date          int           `json:"date"`
+	Views         int           `json:"views"`
+	Link          string        `json:"link"`
+	ChannelID     int           `json:"channel_id"`
+	ForwardedFrom ForwardSource `json:"forwarded_from"`
+	IsDeleted     int           `json:"is_deleted"`
+	Text          string        `json:"text"`
+	Media         ChannelMedia  `json:"media"`
 }
 
 type ChannelPostsResponse struct {
@@ -227,6 +276,12 @@ type ChannelErr struct {
 	Response []ChannelErrResponse `json:"response"`
 }
 
+// Statuses of channels/add responses.
+const (
+	ChannelAddStatusOK      = "ok"
+	ChannelAddStatusPending = "pending"
+)
+
 type ChannelAddPending struct {
 	Status string `json:"status"`
 }
@@ -237,3 +292,14 @@ type ChannelAddSuccess struct {
 		ChannelId int `json:"channelId"`
 	} `json:"response,omitempty"`
 }
+
+// IsPending reports whether TGStat accepted the channel but has not indexed it yet,
+// the response carries no ChannelId then.
+func (r ChannelAddSuccess) IsPending() bool {
+	return r.Status == ChannelAddStatusPending
+}
+
+// Pending returns the response as a ChannelAddPending when it is pending.
+func (r ChannelAddSuccess) Pending() (ChannelAddPending, bool) {
+	return ChannelAddPending{Status: r.Status}, r.IsPending()
+}
@@ -7,6 +7,7 @@ import (
 	validation "github.com/go-ozzo/ozzo-validation/v4"
 	tgstat "github.com/helios-ag/tgstat-go"
 	"github.com/helios-ag/tgstat-go/endpoints"
+	"github.com/helios-ag/tgstat-go/reference"
 	"net/http"
 	"strconv"
 )
@@ -14,6 +15,16 @@ import (
 type Client struct {
 	api   tgstat.API
 	token string
+	// checkReferenceCodes makes Search and Add check codes against the reference snapshot.
+	checkReferenceCodes bool
+}
+
+// WithReferenceCheck returns a copy of the client whose Search and Add reject country,
+// language and category codes missing from the offline reference snapshot.
+// The check is off by default: the snapshot can lag behind the codes the API accepts.
+func (c Client) WithReferenceCheck() Client {
+	c.checkReferenceCodes = true
+	return c
 }
 
 // Get request
@@ -44,20 +55,6 @@ func (c Client) Get(ctx context.Context, channelId string) (*tgstat.ChannelRespo
 	}
 	_ = json.NewDecoder(result.Body).Decode(&response)
 
-	switch x := response.Response.TGStatRestriction.(type) {
-	case []interface{}:
-		response.Response.TGStatRestriction = nil
-	case map[string]interface{}:
-		var restrictions tgstat.TGStatRestrictions
-		jsonString, _ := json.Marshal(x)
-		errors := json.Unmarshal(jsonString, &restrictions)
-		if errors == nil {
-			response.Response.TGStatRestriction = restrictions
-		}
-	default:
-		return nil, result, fmt.Errorf("something wrong with Restrictions response")
-	}
-
 	return &response, result, err
 }
 
@@ -85,6 +82,29 @@ func (searchRequest SearchRequest) Validate() error {
 	)
 }
 
+// ValidateReferenceCodes checks the country, language and category codes
+// against the offline reference snapshot.
+func (searchRequest SearchRequest) ValidateReferenceCodes() error {
+	return validation.ValidateStruct(&searchRequest,
+		validation.Field(&searchRequest.Country, validation.By(referenceCode(reference.ValidateCountry))),
+		validation.Field(&searchRequest.Language, validation.By(referenceCode(reference.ValidateLanguage))),
+		validation.Field(&searchRequest.Category, validation.By(referenceCode(reference.ValidateCategory))),
+	)
+}
+
+// referenceCode checks optional country, language and category codes
+// against the offline reference snapshot.
+func referenceCode(validate func(string) error) validation.RuleFunc {
+	return func(value interface{}) error {
+		value, isNil := validation.Indirect(value)
+		code, ok := value.(string)
+		if isNil || !ok || code == "" {
+			return nil
+		}
+		return validate(code)
+	}
+}
+
 // Search request
 // see https://api.tgstat.ru/docs/ru/channels/search.html
 func Search(ctx context.Context, request SearchRequest) (*tgstat.ChannelSearchResult, *http.Response, error) {
@@ -97,6 +117,12 @@ func (c Client) Search(ctx context.Context, request SearchRequest) (*tgstat.Chan
 		return nil, nil, err
 	}
 
+	if c.checkReferenceCodes {
+		if err := request.ValidateReferenceCodes(); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	body := make(map[string]string)
 	body["q"] = request.Q
 	body["search_by_description"] = strconv.Itoa(request.SearchByDescription)
@@ -496,12 +522,12 @@ type ChannelSubscribersRequest struct {
 	ChannelId string
 	StartDate *string
 	EndDate   *string
-	Group     *string
+	Group     tgstat.Group
 }
 
 func (channelSubscribersRequest ChannelSubscribersRequest) Validate() error {
 	return validation.ValidateStruct(&channelSubscribersRequest,
-		validation.Field(&channelSubscribersRequest.Group, validation.In("hour", "day", "week", "month")),
+		validation.Field(&channelSubscribersRequest.Group, validation.By(tgstat.AllowedFor(endpoints.ChannelsSubscribers))),
 	)
 }
 
@@ -542,8 +568,8 @@ func (c Client) Subscribers(ctx context.Context, request ChannelSubscribersReque
 		body["endDate"] = *request.EndDate
 	}
 
-	if nil != request.Group {
-		body["group"] = *request.Group
+	if request.Group != "" {
+		body["group"] = string(request.Group)
 	}
 
 	req, err := c.api.NewRestRequest(ctx, c.token, http.MethodGet, path, body)
@@ -567,12 +593,12 @@ type ChannelViewsRequest struct {
 	ChannelId string
 	StartDate *string
 	EndDate   *string
-	Group     *string
+	Group     tgstat.Group
 }
 
 func (channelViewsRequest ChannelViewsRequest) Validate() error {
 	return validation.ValidateStruct(&channelViewsRequest,
-		validation.Field(&channelViewsRequest.Group, validation.In("day", "week", "month")),
+		validation.Field(&channelViewsRequest.Group, validation.By(tgstat.AllowedFor(endpoints.ChannelsViews))),
 	)
 }
 
@@ -613,8 +639,8 @@ func (c Client) Views(ctx context.Context, request ChannelViewsRequest) (*tgstat
 		body["endDate"] = *request.EndDate
 	}
 
-	if nil != request.Group {
-		body["group"] = *request.Group
+	if request.Group != "" {
+		body["group"] = string(request.Group)
 	}
 
 	req, err := c.api.NewRestRequest(ctx, c.token, http.MethodGet, path, body)
@@ -647,6 +673,16 @@ func (channelAddRequest ChannelAddRequest) Validate() error {
 	)
 }
 
+// ValidateReferenceCodes checks the country, language and category codes
+// against the offline reference snapshot.
+func (channelAddRequest ChannelAddRequest) ValidateReferenceCodes() error {
+	return validation.ValidateStruct(&channelAddRequest,
+		validation.Field(&channelAddRequest.Country, validation.By(referenceCode(reference.ValidateCountry))),
+		validation.Field(&channelAddRequest.Language, validation.By(referenceCode(reference.ValidateLanguage))),
+		validation.Field(&channelAddRequest.Category, validation.By(referenceCode(reference.ValidateCategory))),
+	)
+}
+
 // Add request
 // see https://api.tgstat.ru/docs/ru/channels/add.html
 func Add(ctx context.Context, request ChannelAddRequest) (*tgstat.ChannelAddSuccess, *http.Response, error) {
@@ -662,6 +698,12 @@ func (c Client) Add(ctx context.Context, request ChannelAddRequest) (*tgstat.Cha
 		return nil, nil, err
 	}
 
+	if c.checkReferenceCodes {
+		if err := request.ValidateReferenceCodes(); err != nil {
+			return nil, nil, err
+		}
+	}
+
 	body := make(map[string]string)
 	body["channelName"] = request.ChannelName
 
@@ -731,8 +773,8 @@ func (c Client) AvgPostsReach(ctx context.Context, request ChannelViewsRequest)
 		body["endDate"] = *request.EndDate
 	}
 
-	if nil != request.Group {
-		body["group"] = *request.Group
+	if request.Group != "" {
+		body["group"] = string(request.Group)
 	}
 
 	req, err := c.api.NewRestRequest(ctx, c.token, http.MethodGet, path, body)
@@ -789,8 +831,8 @@ func (c Client) Err(ctx context.Context, request ChannelViewsRequest) (*tgstat.C
 		body["endDate"] = *request.EndDate
 	}
 
-	if nil != request.Group {
-		body["group"] = *request.Group
+	if request.Group != "" {
+		body["group"] = string(request.Group)
 	}
 
 	req, err := c.api.NewRestRequest(ctx, c.token, http.MethodGet, path, body)
@@ -811,5 +853,10 @@ func (c Client) Err(ctx context.Context, request ChannelViewsRequest) (*tgstat.C
 }
 
 func getClient() Client {
-	return Client{tgstat.GetAPI(), tgstat.Token}
+	return Client{api: tgstat.GetAPI(), token: tgstat.Token}
+}
+
+// NewClient returns a client authenticated with token.
+func NewClient(token string) Client {
+	return Client{api: tgstat.GetAPI(), token: token}
 }
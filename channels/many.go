@@ -0,0 +1,121 @@
+package channels
+
+import (
+	"context"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"sync"
+)
+
+// ManyRequest describes a bulk request over several channels.
+type ManyRequest struct {
+	ChannelIds []string
+	// Concurrency is the maximum number of requests in flight, defaults to 1.
+	Concurrency int
+	// Progress, when set, is called after each channel is processed.
+	Progress func(done, total int)
+}
+
+// ManyResult holds the outcome of a single channel from a bulk request.
+type ManyResult[T any] struct {
+	ChannelId string
+	Result    *T
+	Err       error
+}
+
+// GetMany runs Get for every channel in the request.
+// Results are returned in the order of request.ChannelIds.
+func GetMany(ctx context.Context, request ManyRequest) ([]ManyResult[tgstat.ChannelResponseResult], error) {
+	return getClient().GetMany(ctx, request)
+}
+
+// GetMany runs Get for every channel in the request.
+// Results are returned in the order of request.ChannelIds.
+func (c Client) GetMany(ctx context.Context, request ManyRequest) ([]ManyResult[tgstat.ChannelResponseResult], error) {
+	return many(ctx, request, func(ctx context.Context, channelId string) (*tgstat.ChannelResponseResult, error) {
+		response, _, err := c.Get(ctx, channelId)
+		return response, err
+	})
+}
+
+// StatMany runs Stat for every channel in the request.
+// Results are returned in the order of request.ChannelIds.
+func StatMany(ctx context.Context, request ManyRequest) ([]ManyResult[tgstat.ChannelStatResult], error) {
+	return getClient().StatMany(ctx, request)
+}
+
+// StatMany runs Stat for every channel in the request.
+// Results are returned in the order of request.ChannelIds.
+func (c Client) StatMany(ctx context.Context, request ManyRequest) ([]ManyResult[tgstat.ChannelStatResult], error) {
+	return many(ctx, request, func(ctx context.Context, channelId string) (*tgstat.ChannelStatResult, error) {
+		response, _, err := c.Stat(ctx, channelId)
+		return response, err
+	})
+}
+
+// many fans the request out over a bounded set of workers. When ctx is
+// cancelled the channels processed so far are kept, the rest carry ctx.Err()
+// which is returned as well. Once every channel was processed the error is nil.
+func many[T any](ctx context.Context, request ManyRequest, fetch func(context.Context, string) (*T, error)) ([]ManyResult[T], error) {
+	results, err := manyIndexed(ctx, len(request.ChannelIds), request.Concurrency, request.Progress,
+		func(ctx context.Context, i int) (*T, error) {
+			return fetch(ctx, request.ChannelIds[i])
+		})
+	for i, channelId := range request.ChannelIds {
+		results[i].ChannelId = channelId
+	}
+	return results, err
+}
+
+// manyIndexed is many over the positions 0 to total-1, for requests whose
+// items are not channel IDs. ChannelId is left empty in the results.
+func manyIndexed[T any](ctx context.Context, total, concurrency int, progress func(done, total int), fetch func(context.Context, int) (*T, error)) ([]ManyResult[T], error) {
+	results := make([]ManyResult[T], total)
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		done int
+	)
+	jobs := make(chan int)
+
+	for w := 0; w < concurrency && w < total; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i].Result, results[i].Err = fetch(ctx, i)
+				if progress != nil {
+					mu.Lock()
+					done++
+					progress(done, total)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	next := 0
+feed:
+	for ; next < total; next++ {
+		select {
+		case <-ctx.Done():
+			break feed
+		case jobs <- next:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if next == total {
+		return results, nil
+	}
+	for i := next; i < total; i++ {
+		results[i].Err = ctx.Err()
+	}
+
+	return results, ctx.Err()
+}
@@ -0,0 +1,217 @@
+package channels
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OnboardStatus is the final state of a channel submitted by Onboard.
+type OnboardStatus string
+
+const (
+	// OnboardIndexed channels are available in TGStat, ChannelId is set.
+	OnboardIndexed OnboardStatus = "indexed"
+	// OnboardPending channels were accepted but not indexed before the deadline,
+	// Err is set when the last poll could not reach the API.
+	OnboardPending OnboardStatus = "pending"
+	// OnboardFailed channels were rejected or could not be checked, see Err.
+	OnboardFailed OnboardStatus = "failed"
+)
+
+// Default polling of Onboard.
+const (
+	DefaultOnboardTimeout  = 10 * time.Minute
+	DefaultOnboardInterval = 5 * time.Second
+	DefaultOnboardMaxWait  = 2 * time.Minute
+)
+
+// OnboardRequest describes channels to add and how long to wait for their indexing.
+type OnboardRequest struct {
+	Channels []ChannelAddRequest
+	// Timeout per channel, defaults to DefaultOnboardTimeout.
+	Timeout time.Duration
+	// Interval is the first wait between polls, doubled up to MaxWait.
+	// They default to DefaultOnboardInterval and DefaultOnboardMaxWait.
+	Interval time.Duration
+	MaxWait  time.Duration
+	// Concurrency is the number of channels onboarded at once, defaults to 1.
+	Concurrency int
+	// Progress, when set, is called after each channel is done.
+	Progress func(done, total int)
+}
+
+// OnboardResult is the outcome of onboarding a channel.
+type OnboardResult struct {
+	ChannelName string
+	ChannelId   int
+	Status      OnboardStatus
+	// Polls counts the channels/get requests made while pending.
+	Polls int
+	Err   error
+}
+
+// Onboard adds every channel and, for the ones TGStat answers as pending,
+// polls Get with exponential backoff until the channel is indexed or the
+// timeout passes. Results are in the order of request.Channels.
+func Onboard(ctx context.Context, request OnboardRequest) ([]OnboardResult, error) {
+	return getClient().Onboard(ctx, request)
+}
+
+// Onboard adds every channel and, for the ones TGStat answers as pending,
+// polls Get with exponential backoff until the channel is indexed or the
+// timeout passes. Results are in the order of request.Channels.
+func (c Client) Onboard(ctx context.Context, request OnboardRequest) ([]OnboardResult, error) {
+	if request.Timeout <= 0 {
+		request.Timeout = DefaultOnboardTimeout
+	}
+	if request.Interval <= 0 {
+		request.Interval = DefaultOnboardInterval
+	}
+	if request.MaxWait <= 0 {
+		request.MaxWait = DefaultOnboardMaxWait
+	}
+
+	// channels are passed by position, the same name may be listed twice
+	results, err := manyIndexed(ctx, len(request.Channels), request.Concurrency, request.Progress,
+		func(ctx context.Context, i int) (*OnboardResult, error) {
+			result := c.onboard(ctx, request.Channels[i], request)
+			return &result, nil
+		})
+
+	onboarded := make([]OnboardResult, len(results))
+	for i, result := range results {
+		if result.Result != nil {
+			onboarded[i] = *result.Result
+			continue
+		}
+		onboarded[i] = OnboardResult{ChannelName: request.Channels[i].ChannelName, Status: OnboardFailed, Err: result.Err}
+	}
+	return onboarded, err
+}
+
+func (c Client) onboard(ctx context.Context, channel ChannelAddRequest, request OnboardRequest) OnboardResult {
+	result := OnboardResult{ChannelName: channel.ChannelName, Status: OnboardFailed}
+
+	added, _, err := c.Add(ctx, channel)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if !added.IsPending() && added.Response.ChannelId != 0 {
+		result.ChannelId, result.Status = added.Response.ChannelId, OnboardIndexed
+		return result
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, request.Timeout)
+	defer cancel()
+
+	// pollErr is the last failure to get an answer from the API, errors
+	// answered by the API only mean the channel is not indexed yet
+	var pollErr error
+	wait := request.Interval
+	for {
+		select {
+		case <-ctx.Done():
+			result.Status, result.Err = OnboardPending, pollErr
+			if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				result.Status, result.Err = OnboardFailed, ctx.Err()
+			}
+			return result
+		case <-time.After(wait):
+		}
+
+		result.Polls++
+		found, _, err := c.Get(ctx, channel.ChannelName)
+		var apiErr *tgstat.APIError
+		switch {
+		case err == nil && found.Response.Id != 0:
+			result.ChannelId, result.Status = found.Response.Id, OnboardIndexed
+			return result
+		case err == nil || errors.As(err, &apiErr):
+			pollErr = nil
+		case ctx.Err() == nil:
+			pollErr = err
+		}
+
+		wait = min(wait*2, request.MaxWait)
+	}
+}
+
+// ReadOnboardCSV reads channels to onboard from CSV with a header line.
+// The channel column is required, country, language and category are optional.
+func ReadOnboardCSV(r io.Reader) ([]ChannelAddRequest, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("onboard csv: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["channel"]; !ok {
+		return nil, errors.New("onboard csv: missing channel column")
+	}
+
+	value := func(record []string, column string) *string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) || strings.TrimSpace(record[i]) == "" {
+			return nil
+		}
+		v := strings.TrimSpace(record[i])
+		return &v
+	}
+
+	var requests []ChannelAddRequest
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return requests, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("onboard csv: %w", err)
+		}
+		name := value(record, "channel")
+		if name == nil {
+			return nil, fmt.Errorf("onboard csv: line %d: empty channel", line)
+		}
+		requests = append(requests, ChannelAddRequest{
+			ChannelName: *name,
+			Country:     value(record, "country"),
+			Language:    value(record, "language"),
+			Category:    value(record, "category"),
+		})
+	}
+}
+
+// WriteOnboardCSV writes the results with channel, channel_id, status, polls and error columns.
+func WriteOnboardCSV(w io.Writer, results []OnboardResult) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"channel", "channel_id", "status", "polls", "error"}); err != nil {
+		return err
+	}
+	for _, result := range results {
+		channelId, errText := "", ""
+		if result.ChannelId != 0 {
+			channelId = strconv.Itoa(result.ChannelId)
+		}
+		if result.Err != nil {
+			errText = result.Err.Error()
+		}
+		if err := writer.Write([]string{result.ChannelName, channelId, string(result.Status), strconv.Itoa(result.Polls), errText}); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
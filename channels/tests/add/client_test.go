@@ -93,4 +93,17 @@ func TestClient_ChannelAdd(t *testing.T) {
 			"Status": Equal("ok"),
 		})))
 	})
+
+	t.Run("Test channel add reference codes validation", func(t *testing.T) {
+		request := channels.ChannelAddRequest{
+			ChannelName: "@channel",
+			Country:     tgstat.String("ru"),
+			Category:    tgstat.String("unknown"),
+		}
+		Expect(request.Validate()).To(Succeed())
+
+		_, _, err := channels.NewClient("token").WithReferenceCheck().Add(context.Background(), request)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(Equal(`Category: unknown category code "unknown".`))
+	})
 }
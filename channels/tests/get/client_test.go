@@ -50,7 +50,7 @@ func TestClient_ChannelGet(t *testing.T) {
 					Image100:          "//static.tgstat.ru/public/images/channels/_100/ca/caf1a3dfb505ffed0d024130f58c5cfa.jpg",
 					Image640:          "//static.tgstat.ru/public/images/channels/_0/ca/caf1a3dfb505ffed0d024130f58c5cfa.jpg",
 					ParticipantsCount: 100,
-					TGStatRestriction: tgstat.TGStatRestrictions{
+					TGStatRestriction: tgstat.Restrictions{
 						RedLabel:   true,
 						BlackLabel: true,
 					},
@@ -69,4 +69,38 @@ func TestClient_ChannelGet(t *testing.T) {
 			}),
 		})))
 	})
+
+	t.Run("Test channel Get restrictions shapes", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+
+		restrictions := map[string]string{
+			"empty":   `[]`,
+			"labeled": `{"red_label":true,"black_label":false}`,
+			"names":   `["black_label"]`,
+			"objects": `[{"red_label":true},42]`,
+		}
+		testServer.Mux.HandleFunc(endpoints.ChannelsGet, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"status":"ok","response":{"id":1,"tgstat_restrictions":` + restrictions[r.URL.Query().Get("channelId")] + `}}`))
+		})
+
+		response, _, err := channels.Get(context.Background(), "empty")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.Response.TGStatRestriction).To(Equal(tgstat.Restrictions{}))
+
+		response, _, err = channels.Get(context.Background(), "labeled")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.Response.TGStatRestriction).To(Equal(tgstat.Restrictions{RedLabel: true}))
+
+		response, _, err = channels.Get(context.Background(), "names")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.Response.TGStatRestriction).To(Equal(tgstat.Restrictions{BlackLabel: true}))
+
+		response, _, err = channels.Get(context.Background(), "objects")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.Response.TGStatRestriction).To(Equal(tgstat.Restrictions{RedLabel: true}))
+	})
 }
@@ -0,0 +1,163 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/channels"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func prepareClient(URL string) {
+	tgstat.Token = "token"
+	tgstat.WithEndpoint(URL)
+}
+
+func TestClient_StatMany(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test results keep input order and per item errors", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+
+		testServer.Mux.HandleFunc(endpoints.ChannelsStat, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(tgstat.ChannelStatResult{ //nolint
+				Status: "ok",
+				Response: tgstat.ChannelStatResponse{
+					Title: r.URL.Query().Get("channelId"),
+				},
+			})
+		})
+
+		var progress int32
+		results, err := channels.StatMany(context.Background(), channels.ManyRequest{
+			ChannelIds:  []string{"a", "", "c", "d"},
+			Concurrency: 3,
+			Progress: func(done, total int) {
+				atomic.AddInt32(&progress, 1)
+				Expect(total).To(Equal(4))
+			},
+		})
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(4))
+		Expect(atomic.LoadInt32(&progress)).To(Equal(int32(4)))
+		Expect(results[0].Result.Response.Title).To(Equal("a"))
+		Expect(results[1].Err).To(MatchError(ContainSubstring("ChannelId: cannot be blank")))
+		Expect(results[2].ChannelId).To(Equal("c"))
+		Expect(results[3].Result.Response.Title).To(Equal("d"))
+	})
+
+	t.Run("Test partial results on cancellation", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		testServer.Mux.HandleFunc(endpoints.ChannelsGet, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"status":"ok","response":{"title":"ok","tgstat_restrictions":[]}}`))
+		})
+
+		results, err := channels.GetMany(ctx, channels.ManyRequest{
+			ChannelIds: []string{"a", "b", "c"},
+			Progress: func(done, total int) {
+				if done == 1 {
+					cancel()
+				}
+			},
+		})
+
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(results).To(HaveLen(3))
+		Expect(results[0].Err).ToNot(HaveOccurred())
+		Expect(results[0].Result.Response.Title).To(Equal("ok"))
+		Expect(results[2].Err).To(MatchError(context.Canceled))
+	})
+
+	t.Run("Test no error once every channel was processed", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		testServer.Mux.HandleFunc(endpoints.ChannelsGet, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"status":"ok","response":{"title":"ok","tgstat_restrictions":[]}}`))
+		})
+
+		results, err := channels.GetMany(ctx, channels.ManyRequest{
+			ChannelIds: []string{"a", "b"},
+			Progress: func(done, total int) {
+				if done == total {
+					cancel()
+				}
+			},
+		})
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results[0].Err).ToNot(HaveOccurred())
+		Expect(results[1].Err).ToNot(HaveOccurred())
+	})
+}
+
+func TestWithRateLimit(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test requests are spaced by the limiter", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		tgstat.WithRateLimit(1, 50*time.Millisecond, 1)
+		defer tgstat.WithRateLimit(0, 0, 0)
+
+		testServer.Mux.HandleFunc(endpoints.ChannelsStat, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"status":"ok","response":{}}`))
+		})
+
+		start := time.Now()
+		results, err := channels.StatMany(context.Background(), channels.ManyRequest{
+			ChannelIds:  []string{"a", "b", "c"},
+			Concurrency: 3,
+		})
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(3))
+		Expect(time.Since(start)).To(BeNumerically(">=", 100*time.Millisecond))
+	})
+
+	t.Run("Test rates above one request per nanosecond are allowed", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		tgstat.WithRateLimit(2, time.Nanosecond, 1)
+		defer tgstat.WithRateLimit(0, 0, 0)
+
+		testServer.Mux.HandleFunc(endpoints.ChannelsStat, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"status":"ok","response":{}}`))
+		})
+
+		results, err := channels.StatMany(context.Background(), channels.ManyRequest{
+			ChannelIds:  []string{"a", "b", "c"},
+			Concurrency: 3,
+		})
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(3))
+	})
+}
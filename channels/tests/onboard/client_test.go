@@ -0,0 +1,233 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/channels"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func prepareClient(URL string) {
+	tgstat.Token = "token"
+	tgstat.WithEndpoint(URL)
+}
+
+// fakeIndex answers channels/add and channels/get, a channel becomes
+// indexed after the given number of channels/get requests. The first
+// channels/get requests of a channel in failures answer 502.
+type fakeIndex struct {
+	mu       sync.Mutex
+	ids      map[string]int
+	pending  map[string]int
+	gets     map[string]int
+	failures map[string]int
+}
+
+func newFakeIndex(testServer server.Server) *fakeIndex {
+	index := &fakeIndex{ids: map[string]int{}, pending: map[string]int{}, gets: map[string]int{}, failures: map[string]int{}}
+	testServer.Mux.HandleFunc(endpoints.ChannelsAdd, func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		name := body["channelName"]
+
+		index.mu.Lock()
+		defer index.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		id, known := index.ids[name]
+		switch {
+		case !known:
+			_, _ = w.Write([]byte(`{"status":"error","error":"channel not found"}`))
+		case index.pending[name] > 0:
+			_, _ = w.Write([]byte(`{"status":"pending"}`))
+		default:
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "response": map[string]int{"channelId": id}})
+		}
+	})
+	testServer.Mux.HandleFunc(endpoints.ChannelsGet, func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Query().Get("channelId")
+
+		index.mu.Lock()
+		defer index.mu.Unlock()
+		if index.failures[name] > 0 {
+			index.failures[name]--
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+			return
+		}
+		index.gets[name]++
+		w.Header().Set("Content-Type", "application/json")
+		if index.gets[name] < index.pending[name] {
+			_, _ = w.Write([]byte(`{"status":"error","error":"channel not found"}`))
+			return
+		}
+		json.NewEncoder(w).Encode(tgstat.ChannelResponseResult{Status: "ok", Response: tgstat.ChannelResponse{Id: index.ids[name], Username: name}})
+	})
+	return index
+}
+
+func TestClient_Onboard(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test onboard indexed and pending channels", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		index := newFakeIndex(testServer)
+		index.ids["@indexed"] = 1
+		index.ids["@pending"] = 2
+		index.pending["@pending"] = 3
+
+		results, err := channels.Onboard(context.Background(), channels.OnboardRequest{
+			Channels: []channels.ChannelAddRequest{
+				{ChannelName: "@indexed"},
+				{ChannelName: "@pending"},
+				{ChannelName: "@unknown"},
+			},
+			Interval:    time.Millisecond,
+			MaxWait:     4 * time.Millisecond,
+			Concurrency: 2,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(3))
+
+		Expect(results[0].ChannelName).To(Equal("@indexed"))
+		Expect(results[0].Status).To(Equal(channels.OnboardIndexed))
+		Expect(results[0].ChannelId).To(Equal(1))
+		Expect(results[0].Polls).To(Equal(0))
+
+		Expect(results[1].Status).To(Equal(channels.OnboardIndexed))
+		Expect(results[1].ChannelId).To(Equal(2))
+		Expect(results[1].Polls).To(Equal(3))
+
+		Expect(results[2].Status).To(Equal(channels.OnboardFailed))
+		Expect(results[2].Err).To(HaveOccurred())
+		Expect(results[2].ChannelId).To(Equal(0))
+	})
+
+	t.Run("Test onboard timeout leaves channel pending", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		index := newFakeIndex(testServer)
+		index.ids["@slow"] = 7
+		index.pending["@slow"] = 1000
+
+		results, err := channels.Onboard(context.Background(), channels.OnboardRequest{
+			Channels: []channels.ChannelAddRequest{{ChannelName: "@slow"}},
+			Timeout:  30 * time.Millisecond,
+			Interval: time.Millisecond,
+			MaxWait:  2 * time.Millisecond,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results[0].Status).To(Equal(channels.OnboardPending))
+		Expect(results[0].Err).ToNot(HaveOccurred())
+		Expect(results[0].ChannelId).To(Equal(0))
+		Expect(results[0].Polls).To(BeNumerically(">", 1))
+	})
+
+	t.Run("Test duplicate channels keep their results", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		index := newFakeIndex(testServer)
+		index.ids["@indexed"] = 1
+
+		results, err := channels.Onboard(context.Background(), channels.OnboardRequest{
+			Channels: []channels.ChannelAddRequest{{ChannelName: "@indexed"}, {ChannelName: "@indexed"}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results).To(HaveLen(2))
+		for _, result := range results {
+			Expect(result.ChannelName).To(Equal("@indexed"))
+			Expect(result.Status).To(Equal(channels.OnboardIndexed))
+		}
+	})
+
+	t.Run("Test transient poll errors are retried", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		index := newFakeIndex(testServer)
+		index.ids["@flaky"] = 5
+		index.pending["@flaky"] = 1
+		index.failures["@flaky"] = 2
+		index.ids["@down"] = 6
+		index.pending["@down"] = 1
+		index.failures["@down"] = 1000
+
+		results, err := channels.Onboard(context.Background(), channels.OnboardRequest{
+			Channels:    []channels.ChannelAddRequest{{ChannelName: "@flaky"}, {ChannelName: "@down"}},
+			Timeout:     30 * time.Millisecond,
+			Interval:    time.Millisecond,
+			MaxWait:     2 * time.Millisecond,
+			Concurrency: 2,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results[0].Status).To(Equal(channels.OnboardIndexed))
+		Expect(results[0].ChannelId).To(Equal(5))
+		Expect(results[0].Polls).To(Equal(3))
+
+		Expect(results[1].Status).To(Equal(channels.OnboardPending))
+		Expect(results[1].Err).To(MatchError(ContainSubstring("502")))
+	})
+
+	t.Run("Test onboard validation", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+
+		results, err := channels.Onboard(context.Background(), channels.OnboardRequest{
+			Channels: []channels.ChannelAddRequest{{ChannelName: ""}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(results[0].Status).To(Equal(channels.OnboardFailed))
+		Expect(results[0].Err.Error()).To(ContainSubstring("ChannelName: cannot be blank"))
+	})
+}
+
+func TestOnboardCSV(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test read onboard csv", func(t *testing.T) {
+		requests, err := channels.ReadOnboardCSV(strings.NewReader("Channel,country,category\n@one,ru,\n @two , ,tech\n"))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(requests).To(HaveLen(2))
+		Expect(requests[0].ChannelName).To(Equal("@one"))
+		Expect(*requests[0].Country).To(Equal("ru"))
+		Expect(requests[0].Category).To(BeNil())
+		Expect(requests[0].Language).To(BeNil())
+		Expect(requests[1].ChannelName).To(Equal("@two"))
+		Expect(requests[1].Country).To(BeNil())
+		Expect(*requests[1].Category).To(Equal("tech"))
+	})
+
+	t.Run("Test read onboard csv errors", func(t *testing.T) {
+		_, err := channels.ReadOnboardCSV(strings.NewReader("name\n@one\n"))
+		Expect(err).To(MatchError(ContainSubstring("missing channel column")))
+
+		_, err = channels.ReadOnboardCSV(strings.NewReader("channel,country\n,ru\n"))
+		Expect(err).To(MatchError(ContainSubstring("line 2: empty channel")))
+	})
+
+	t.Run("Test write onboard csv", func(t *testing.T) {
+		var b bytes.Buffer
+		err := channels.WriteOnboardCSV(&b, []channels.OnboardResult{
+			{ChannelName: "@one", ChannelId: 1, Status: channels.OnboardIndexed},
+			{ChannelName: "@two", Status: channels.OnboardPending, Polls: 5},
+			{ChannelName: "@three", Status: channels.OnboardFailed, Err: context.Canceled},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(b.String()).To(Equal("channel,channel_id,status,polls,error\n" +
+			"@one,1,indexed,0,\n" +
+			"@two,,pending,5,\n" +
+			"@three,,failed,0,context canceled\n"))
+	})
+}
@@ -35,7 +35,7 @@ func TestClient_ChannelPosts(t *testing.T) {
 				Views:         148382,
 				Link:          "t.me/breakingmash",
 				ChannelID:     0,
-				ForwardedFrom: "",
+				ForwardedFrom: tgstat.ForwardSource{},
 				IsDeleted:     0,
 				Text:          "",
 				Media: tgstat.ChannelMedia{
@@ -89,7 +89,7 @@ func TestClient_ChannelPosts(t *testing.T) {
 				Views:         148382,
 				Link:          "t.me/breakingmash",
 				ChannelID:     0,
-				ForwardedFrom: "",
+				ForwardedFrom: tgstat.ForwardSource{},
 				IsDeleted:     0,
 				Text:          "",
 				Media: tgstat.ChannelMedia{
@@ -154,7 +154,7 @@ func TestClient_ChannelPostsExtended(t *testing.T) {
 				Views:         148382,
 				Link:          "t.me/breakingmash",
 				ChannelID:     0,
-				ForwardedFrom: "",
+				ForwardedFrom: tgstat.ForwardSource{},
 				IsDeleted:     0,
 				Text:          "",
 				Media: tgstat.ChannelMedia{
@@ -208,7 +208,7 @@ func TestClient_ChannelPostsExtended(t *testing.T) {
 				Views:         148382,
 				Link:          "t.me/breakingmash",
 				ChannelID:     0,
-				ForwardedFrom: "",
+				ForwardedFrom: tgstat.ForwardSource{},
 				IsDeleted:     0,
 				Text:          "",
 				Media: tgstat.ChannelMedia{
@@ -80,4 +80,20 @@ func TestClient_ChannelSearch(t *testing.T) {
 			"Status": Equal("ok"),
 		})))
 	})
+
+	t.Run("Test channel search reference codes validation", func(t *testing.T) {
+		request := channels.SearchRequest{
+			Q:        "test",
+			Country:  "atlantis",
+			Language: tgstat.String("klingon"),
+			Category: "tech",
+		}
+		Expect(request.Validate()).To(Succeed())
+
+		_, _, err := channels.NewClient("token").WithReferenceCheck().Search(context.Background(), request)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring(`Country: unknown country code "atlantis"`))
+		Expect(err.Error()).To(ContainSubstring(`Language: unknown language code "klingon"`))
+		Expect(err.Error()).ToNot(ContainSubstring("Category"))
+	})
 }
@@ -28,7 +28,6 @@ func TestClient_Mentions(t *testing.T) {
 			ChannelId: "",
 			StartDate: nil,
 			EndDate:   nil,
-			Group:     nil,
 		}
 		_, _, err := channels.Subscribers(context.Background(), request)
 		Expect(err).To(HaveOccurred())
@@ -0,0 +1,279 @@
+package tgstat_go
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	CircuitClosed CircuitState = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// ErrCircuitOpen is returned without calling the API while the breaker of an endpoint group is open.
+type ErrCircuitOpen struct {
+	Group      string
+	RetryAfter time.Duration
+}
+
+func (e *ErrCircuitOpen) Error() string {
+	return fmt.Sprintf("circuit breaker for %s is open, retry after %s", e.Group, e.RetryAfter)
+}
+
+// CircuitBreakerConfig configures a circuit breaker.
+type CircuitBreakerConfig struct {
+	// FailureRate in (0, 1] opens the circuit once reached within Window.
+	FailureRate float64
+	// MinRequests is the number of requests within Window required before the rate is evaluated.
+	MinRequests int
+	// Window is the sliding window the failure rate is computed over.
+	Window time.Duration
+	// Cooldown is how long the circuit stays open before probing the API again.
+	Cooldown time.Duration
+	// HalfOpenRequests is the number of probe requests allowed while half-open,
+	// that many have to succeed to close the circuit.
+	HalfOpenRequests int
+	// OnStateChange, when set, is called on every transition.
+	OnStateChange func(group string, from, to CircuitState)
+}
+
+func (config CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	if config.FailureRate <= 0 || config.FailureRate > 1 {
+		config.FailureRate = 0.5
+	}
+	if config.MinRequests < 1 {
+		config.MinRequests = 10
+	}
+	if config.Window <= 0 {
+		config.Window = time.Minute
+	}
+	if config.Cooldown <= 0 {
+		config.Cooldown = 30 * time.Second
+	}
+	if config.HalfOpenRequests < 1 {
+		config.HalfOpenRequests = 1
+	}
+	return config
+}
+
+type outcome struct {
+	at     time.Time
+	failed bool
+}
+
+type circuitBreaker struct {
+	mu        sync.Mutex
+	group     string
+	config    CircuitBreakerConfig
+	state     CircuitState
+	openedAt  time.Time
+	probes    int
+	successes int
+	outcomes  []outcome
+	now       func() time.Time
+}
+
+func newCircuitBreaker(group string, config CircuitBreakerConfig) *circuitBreaker {
+	return &circuitBreaker{
+		group:  group,
+		config: config.withDefaults(),
+		now:    time.Now,
+	}
+}
+
+// allow reports whether a request may pass.
+func (b *circuitBreaker) allow() error {
+	b.mu.Lock()
+	from := b.state
+	now := b.now()
+
+	if b.state == CircuitOpen {
+		if wait := b.openedAt.Add(b.config.Cooldown).Sub(now); wait > 0 {
+			b.mu.Unlock()
+			return &ErrCircuitOpen{Group: b.group, RetryAfter: wait}
+		}
+		b.state = CircuitHalfOpen
+		b.probes = 0
+		b.successes = 0
+	}
+
+	if b.state == CircuitHalfOpen {
+		if b.probes >= b.config.HalfOpenRequests {
+			b.mu.Unlock()
+			return &ErrCircuitOpen{Group: b.group}
+		}
+		b.probes++
+	}
+
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+	return nil
+}
+
+// record stores the outcome of a request that was allowed.
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	from := b.state
+	now := b.now()
+
+	switch b.state {
+	case CircuitHalfOpen:
+		if failed {
+			b.trip(now)
+			break
+		}
+		b.successes++
+		if b.successes >= b.config.HalfOpenRequests {
+			b.state = CircuitClosed
+			b.outcomes = nil
+		}
+	case CircuitClosed:
+		b.outcomes = append(b.outcomes, outcome{at: now, failed: failed})
+		cutoff := now.Add(-b.config.Window)
+		for len(b.outcomes) > 0 && b.outcomes[0].at.Before(cutoff) {
+			b.outcomes = b.outcomes[1:]
+		}
+		if len(b.outcomes) >= b.config.MinRequests && b.failureRate() >= b.config.FailureRate {
+			b.trip(now)
+		}
+	}
+
+	to := b.state
+	b.mu.Unlock()
+	b.notify(from, to)
+}
+
+// release gives back a request that was allowed but cancelled by the caller,
+// it neither counts as a success nor as a failure.
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == CircuitHalfOpen && b.probes > 0 {
+		b.probes--
+	}
+}
+
+func (b *circuitBreaker) trip(now time.Time) {
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.outcomes = nil
+}
+
+func (b *circuitBreaker) failureRate() float64 {
+	failures := 0
+	for _, o := range b.outcomes {
+		if o.failed {
+			failures++
+		}
+	}
+	return float64(failures) / float64(len(b.outcomes))
+}
+
+func (b *circuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *circuitBreaker) notify(from, to CircuitState) {
+	if from != to && b.config.OnStateChange != nil {
+		b.config.OnStateChange(b.group, from, to)
+	}
+}
+
+// isBackendFailure reports whether the outcome of a request counts against the breaker.
+// Cancellations by the caller are not recorded at all, see release.
+func isBackendFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500
+}
+
+// endpointGroup returns the group of an endpoint, e.g. "channels" for "/channels/get".
+func endpointGroup(endpoint string) string {
+	group, _, _ := strings.Cut(strings.TrimPrefix(endpoint, "/"), "/")
+	return group
+}
+
+// WithCircuitBreaker adds a circuit breaker to the shared client for each of the
+// given endpoint groups, see the Group constants of the endpoints package.
+// Each group gets its own breaker, without groups every group is covered.
+func WithCircuitBreaker(config CircuitBreakerConfig, groups ...string) {
+	if TGStatClient.breakers == nil {
+		TGStatClient.breakers = &circuitBreakers{groups: make(map[string]*circuitBreaker)}
+	}
+	TGStatClient.breakers.configure(config, groups)
+}
+
+// WithoutCircuitBreaker removes every circuit breaker from the shared client.
+func WithoutCircuitBreaker() {
+	TGStatClient.breakers = nil
+}
+
+// BreakerState returns the state of the circuit breaker of an endpoint group.
+func BreakerState(group string) CircuitState {
+	if b := TGStatClient.breakers.get(group); b != nil {
+		return b.State()
+	}
+	return CircuitClosed
+}
+
+type circuitBreakers struct {
+	mu       sync.RWMutex
+	groups   map[string]*circuitBreaker
+	fallback *CircuitBreakerConfig
+}
+
+func (b *circuitBreakers) configure(config CircuitBreakerConfig, groups []string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(groups) == 0 {
+		b.fallback = &config
+		return
+	}
+	for _, group := range groups {
+		b.groups[group] = newCircuitBreaker(group, config)
+	}
+}
+
+func (b *circuitBreakers) get(group string) *circuitBreaker {
+	if b == nil || group == "" {
+		return nil
+	}
+
+	b.mu.RLock()
+	breaker, ok := b.groups[group]
+	fallback := b.fallback
+	b.mu.RUnlock()
+	if ok || fallback == nil {
+		return breaker
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if breaker, ok = b.groups[group]; !ok {
+		breaker = newCircuitBreaker(group, *b.fallback)
+		b.groups[group] = breaker
+	}
+	return breaker
+}
@@ -0,0 +1,159 @@
+package tgstat_go
+
+import (
+	"context"
+	"errors"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test breaker opens, fails fast and recovers", func(t *testing.T) {
+		now := time.Now()
+		var transitions []string
+		breaker := newCircuitBreaker(endpoints.GroupChannels, CircuitBreakerConfig{
+			FailureRate: 0.5,
+			MinRequests: 4,
+			Window:      time.Minute,
+			Cooldown:    10 * time.Second,
+			OnStateChange: func(group string, from, to CircuitState) {
+				transitions = append(transitions, group+":"+from.String()+"->"+to.String())
+			},
+		})
+		breaker.now = func() time.Time { return now }
+
+		for _, failed := range []bool{false, true, false, true} {
+			Expect(breaker.allow()).To(Succeed())
+			breaker.record(failed)
+		}
+		Expect(breaker.State()).To(Equal(CircuitOpen))
+
+		var openErr *ErrCircuitOpen
+		Expect(errors.As(breaker.allow(), &openErr)).To(BeTrue())
+		Expect(openErr.Group).To(Equal(endpoints.GroupChannels))
+
+		now = now.Add(10 * time.Second)
+		Expect(breaker.allow()).To(Succeed())
+		Expect(breaker.allow()).To(HaveOccurred())
+		breaker.record(false)
+		Expect(breaker.State()).To(Equal(CircuitClosed))
+
+		Expect(transitions).To(Equal([]string{
+			"channels:closed->open",
+			"channels:open->half-open",
+			"channels:half-open->closed",
+		}))
+	})
+
+	t.Run("Test every half-open probe has to succeed", func(t *testing.T) {
+		now := time.Now()
+		breaker := newCircuitBreaker(endpoints.GroupPosts, CircuitBreakerConfig{MinRequests: 1, Cooldown: time.Second, HalfOpenRequests: 2})
+		breaker.now = func() time.Time { return now }
+
+		breaker.record(true)
+		Expect(breaker.State()).To(Equal(CircuitOpen))
+
+		now = now.Add(time.Second)
+		Expect(breaker.allow()).To(Succeed())
+		Expect(breaker.allow()).To(Succeed())
+		breaker.record(false)
+		Expect(breaker.State()).To(Equal(CircuitHalfOpen))
+		breaker.record(false)
+		Expect(breaker.State()).To(Equal(CircuitClosed))
+	})
+
+	t.Run("Test a released probe can be retried", func(t *testing.T) {
+		now := time.Now()
+		breaker := newCircuitBreaker(endpoints.GroupPosts, CircuitBreakerConfig{MinRequests: 1, Cooldown: time.Second})
+		breaker.now = func() time.Time { return now }
+
+		breaker.record(true)
+		now = now.Add(time.Second)
+		Expect(breaker.allow()).To(Succeed())
+		breaker.release()
+		Expect(breaker.State()).To(Equal(CircuitHalfOpen))
+		Expect(breaker.allow()).To(Succeed())
+	})
+
+	t.Run("Test failures outside of the window are forgotten", func(t *testing.T) {
+		now := time.Now()
+		breaker := newCircuitBreaker(endpoints.GroupPosts, CircuitBreakerConfig{MinRequests: 2, Window: time.Second})
+		breaker.now = func() time.Time { return now }
+
+		breaker.record(true)
+		now = now.Add(2 * time.Second)
+		breaker.record(true)
+		Expect(breaker.State()).To(Equal(CircuitClosed))
+	})
+}
+
+func TestClientDoWithCircuitBreaker(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test breaker is configured per endpoint group", func(t *testing.T) {
+		newServer := server.NewServer()
+		defer newServer.Teardown()
+
+		calls := 0
+		newServer.Mux.HandleFunc(endpoints.ChannelsGet, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		})
+		newServer.Mux.HandleFunc(endpoints.PostsGet, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		})
+
+		WithCircuitBreaker(CircuitBreakerConfig{MinRequests: 2, Cooldown: time.Minute}, endpoints.GroupChannels)
+		defer WithoutCircuitBreaker()
+		WithEndpoint(newServer.URL)
+		api := GetAPI()
+		ctx := context.Background()
+
+		for i := 0; i < 3; i++ {
+			request, _ := api.NewRestRequest(ctx, "token", http.MethodGet, endpoints.ChannelsGet, make(map[string]string))
+			_, err := api.Do(request, nil)
+			Expect(err).To(HaveOccurred())
+		}
+		Expect(calls).To(Equal(2))
+		Expect(BreakerState(endpoints.GroupChannels)).To(Equal(CircuitOpen))
+
+		request, _ := api.NewRestRequest(ctx, "token", http.MethodGet, endpoints.ChannelsGet, make(map[string]string))
+		_, err := api.Do(request, nil)
+		var openErr *ErrCircuitOpen
+		Expect(errors.As(err, &openErr)).To(BeTrue())
+
+		for i := 0; i < 3; i++ {
+			request, _ := api.NewRestRequest(ctx, "token", http.MethodGet, endpoints.PostsGet, make(map[string]string))
+			_, err := api.Do(request, nil)
+			Expect(err.Error()).To(ContainSubstring("status code 502"))
+		}
+		Expect(BreakerState(endpoints.GroupPosts)).To(Equal(CircuitClosed))
+	})
+
+	t.Run("Test cancelled requests are not recorded", func(t *testing.T) {
+		newServer := server.NewServer()
+		defer newServer.Teardown()
+
+		newServer.Mux.HandleFunc(endpoints.ChannelsGet, func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"status":"ok","response":{}}`))
+		})
+
+		WithCircuitBreaker(CircuitBreakerConfig{MinRequests: 1}, endpoints.GroupChannels)
+		defer WithoutCircuitBreaker()
+		WithEndpoint(newServer.URL)
+		api := GetAPI()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		request, _ := api.NewRestRequest(ctx, "token", http.MethodGet, endpoints.ChannelsGet, make(map[string]string))
+		_, err := api.Do(request, nil)
+		Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+
+		breaker := TGStatClient.breakers.get(endpoints.GroupChannels)
+		Expect(breaker.outcomes).To(BeEmpty())
+	})
+}
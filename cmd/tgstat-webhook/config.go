@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/alert"
+	"github.com/helios-ag/tgstat-go/callback"
+	"github.com/helios-ag/tgstat-go/sinks"
+	"go.yaml.in/yaml/v3"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Config is the configuration file of the daemon, in YAML or JSON.
+type Config struct {
+	// Listen is the address of the HTTP server, defaults to :8080.
+	Listen string `json:"listen" yaml:"listen"`
+	// Path is where the callback receiver is served, defaults to /callback.
+	Path string `json:"path" yaml:"path"`
+	// Token is the TGStat token, read from the TokenEnv variable (TGSTAT_TOKEN by default) when empty.
+	Token    string `json:"token" yaml:"token"`
+	TokenEnv string `json:"token_env" yaml:"token_env"`
+	// APIURL overrides the TGStat API endpoint.
+	APIURL string `json:"api_url" yaml:"api_url"`
+	// CallbackURL is the public URL of Path registered with SetCallback, registration is skipped when empty.
+	CallbackURL string `json:"callback_url" yaml:"callback_url"`
+	// Spool is the directory events are stored in until delivered, defaults to spool.
+	Spool string `json:"spool" yaml:"spool"`
+	// DeliveryAttempts is the number of dispatches of an event before it is
+	// moved to the dead letter file, defaults to 5.
+	DeliveryAttempts int `json:"delivery_attempts" yaml:"delivery_attempts"`
+	// Rules is an alert rule file, without it every event goes to every sink.
+	Rules           string         `json:"rules" yaml:"rules"`
+	ShutdownTimeout alert.Duration `json:"shutdown_timeout" yaml:"shutdown_timeout"`
+	// HealthInterval is the interval of the GetCallbackInfo checks run when
+	// CallbackURL is set, defaults to callback.DefaultHealthInterval.
+	// BacklogThreshold is the pending update count reported as a backlog.
+	HealthInterval   alert.Duration `json:"health_interval" yaml:"health_interval"`
+	BacklogThreshold int            `json:"backlog_threshold" yaml:"backlog_threshold"`
+
+	Security      SecurityConfig      `json:"security" yaml:"security"`
+	Subscriptions SubscriptionsConfig `json:"subscriptions" yaml:"subscriptions"`
+	Sinks         []SinkConfig        `json:"sinks" yaml:"sinks"`
+}
+
+// SecurityConfig restricts the requests accepted by the callback receiver.
+type SecurityConfig struct {
+	// Secret is added to the registered callback URL and required on every request.
+	Secret string `json:"secret" yaml:"secret"`
+	// AllowedIPs and TrustedProxies are addresses or CIDR ranges.
+	AllowedIPs     []string `json:"allowed_ips" yaml:"allowed_ips"`
+	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies"`
+	MaxBodySize    int64    `json:"max_body_size" yaml:"max_body_size"`
+	// RequestTimeout bounds reading and storing a delivery, defaults to 30s.
+	RequestTimeout alert.Duration `json:"request_timeout" yaml:"request_timeout"`
+	// Strict rejects events with unknown fields or missing identifiers.
+	Strict bool `json:"strict" yaml:"strict"`
+}
+
+// SubscriptionsConfig is reconciled with callback.Reconcile on start.
+type SubscriptionsConfig struct {
+	Channels []ChannelSubscription `json:"channels" yaml:"channels"`
+	Keywords []KeywordSubscription `json:"keywords" yaml:"keywords"`
+	// Prune removes the subscriptions of the account missing from the file.
+	Prune bool `json:"prune" yaml:"prune"`
+}
+
+type ChannelSubscription struct {
+	Channel    string             `json:"channel" yaml:"channel"`
+	EventTypes []tgstat.EventType `json:"event_types" yaml:"event_types"`
+}
+
+type KeywordSubscription struct {
+	Q              string             `json:"q" yaml:"q"`
+	EventTypes     []tgstat.EventType `json:"event_types" yaml:"event_types"`
+	StrongSearch   *bool              `json:"strong_search" yaml:"strong_search"`
+	MinusWords     *string            `json:"minus_words" yaml:"minus_words"`
+	ExtendedSyntax *bool              `json:"extended_syntax" yaml:"extended_syntax"`
+	PeerTypes      tgstat.PeerType    `json:"peer_types" yaml:"peer_types"`
+}
+
+// SinkConfig configures a sink, the fields used depend on Type:
+// webhook, file, telegram or exec.
+type SinkConfig struct {
+	Name string `json:"name" yaml:"name"`
+	Type string `json:"type" yaml:"type"`
+
+	// webhook
+	URL     string            `json:"url" yaml:"url"`
+	Method  string            `json:"method" yaml:"method"`
+	Headers map[string]string `json:"headers" yaml:"headers"`
+	Body    string            `json:"body" yaml:"body"`
+
+	// file
+	Path       string `json:"path" yaml:"path"`
+	MaxSize    int64  `json:"max_size" yaml:"max_size"`
+	MaxBackups int    `json:"max_backups" yaml:"max_backups"`
+
+	// telegram, Token is read from TokenEnv when empty
+	Token                 string `json:"token" yaml:"token"`
+	TokenEnv              string `json:"token_env" yaml:"token_env"`
+	ChatID                string `json:"chat_id" yaml:"chat_id"`
+	BaseURL               string `json:"base_url" yaml:"base_url"`
+	Text                  string `json:"text" yaml:"text"`
+	ParseMode             string `json:"parse_mode" yaml:"parse_mode"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview" yaml:"disable_web_page_preview"`
+
+	// exec
+	Command string         `json:"command" yaml:"command"`
+	Args    []string       `json:"args" yaml:"args"`
+	Env     []string       `json:"env" yaml:"env"`
+	Dir     string         `json:"dir" yaml:"dir"`
+	Timeout alert.Duration `json:"timeout" yaml:"timeout"`
+
+	Retries int            `json:"retries" yaml:"retries"`
+	Backoff alert.Duration `json:"backoff" yaml:"backoff"`
+	Rate    alert.Duration `json:"rate" yaml:"rate"`
+	Burst   int            `json:"burst" yaml:"burst"`
+}
+
+// loadConfig reads a .json, .yaml or .yml configuration file and applies the defaults.
+func loadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(config)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		err = decoder.Decode(config)
+	default:
+		return nil, fmt.Errorf("%s: unknown configuration format", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if config.Listen == "" {
+		config.Listen = ":8080"
+	}
+	if config.Path == "" {
+		config.Path = "/callback"
+	}
+	if config.TokenEnv == "" {
+		config.TokenEnv = "TGSTAT_TOKEN"
+	}
+	if config.Token == "" {
+		config.Token = os.Getenv(config.TokenEnv)
+	}
+	if config.Spool == "" {
+		config.Spool = "spool"
+	}
+	if config.DeliveryAttempts <= 0 {
+		config.DeliveryAttempts = 5
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = alert.Duration(10 * time.Second)
+	}
+	if config.Security.RequestTimeout <= 0 {
+		config.Security.RequestTimeout = alert.Duration(30 * time.Second)
+	}
+	if config.Token == "" && (config.CallbackURL != "" || config.hasSubscriptions()) {
+		return nil, fmt.Errorf("%s: no TGStat token, set token or %s", path, config.TokenEnv)
+	}
+	if len(config.Sinks) == 0 {
+		return nil, fmt.Errorf("%s: no sinks", path)
+	}
+	return config, nil
+}
+
+func (c *Config) hasSubscriptions() bool {
+	return len(c.Subscriptions.Channels) != 0 || len(c.Subscriptions.Keywords) != 0
+}
+
+// reconcileRequest converts the subscriptions of the file.
+func (c *Config) reconcileRequest() callback.ReconcileRequest {
+	request := callback.ReconcileRequest{Prune: c.Subscriptions.Prune}
+	for _, channel := range c.Subscriptions.Channels {
+		request.Channels = append(request.Channels, callback.SubscribeChannelRequest{
+			ChannelId:  channel.Channel,
+			EventTypes: channel.EventTypes,
+		})
+	}
+	for _, keyword := range c.Subscriptions.Keywords {
+		request.Words = append(request.Words, callback.SubscribeWordRequest{
+			Q:              keyword.Q,
+			EventTypes:     keyword.EventTypes,
+			StrongSearch:   keyword.StrongSearch,
+			MinusWords:     keyword.MinusWords,
+			ExtendedSyntax: keyword.ExtendedSyntax,
+			PeerTypes:      keyword.PeerTypes,
+		})
+	}
+	return request
+}
+
+// receiver creates the callback receiver with the security options.
+func (c *Config) receiver(handler callback.Handler) (*callback.Receiver, error) {
+	receiver := callback.NewReceiver(handler)
+	receiver.Secret = c.Security.Secret
+	receiver.MaxBodySize = c.Security.MaxBodySize
+	receiver.Timeout = time.Duration(c.Security.RequestTimeout)
+	receiver.Strict = c.Security.Strict
+
+	var err error
+	if receiver.AllowedNetworks, err = callback.ParsePrefixes(c.Security.AllowedIPs); err != nil {
+		return nil, fmt.Errorf("allowed_ips: %w", err)
+	}
+	if receiver.TrustedProxies, err = callback.ParsePrefixes(c.Security.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted_proxies: %w", err)
+	}
+	return receiver, nil
+}
+
+// namedSink is a configured sink, closed on shutdown when it holds a file.
+type namedSink struct {
+	name string
+	sink sinks.Sink
+}
+
+// buildSinks creates the sinks of the configuration.
+func buildSinks(configs []SinkConfig) ([]namedSink, error) {
+	var result []namedSink
+	names := make(map[string]bool, len(configs))
+	for i, config := range configs {
+		if config.Name == "" {
+			config.Name = config.Type
+		}
+		if names[config.Name] {
+			return nil, fmt.Errorf("sink %d: duplicate name %s, set name", i, config.Name)
+		}
+		names[config.Name] = true
+
+		options := sinks.Options{
+			Retries: config.Retries,
+			Backoff: time.Duration(config.Backoff),
+			Rate:    time.Duration(config.Rate),
+			Burst:   config.Burst,
+		}
+		var sink sinks.Sink
+		var err error
+		switch config.Type {
+		case "webhook":
+			sink, err = sinks.NewWebhook(sinks.WebhookConfig{
+				URL: config.URL, Method: config.Method, Headers: config.Headers, Body: config.Body, Options: options,
+			})
+		case "file":
+			sink, err = sinks.NewFile(sinks.FileConfig{
+				Path: config.Path, MaxSize: config.MaxSize, MaxBackups: config.MaxBackups, Options: options,
+			})
+		case "telegram":
+			token := config.Token
+			if token == "" && config.TokenEnv != "" {
+				token = os.Getenv(config.TokenEnv)
+			}
+			sink, err = sinks.NewTelegram(sinks.TelegramConfig{
+				Token: token, ChatID: config.ChatID, BaseURL: config.BaseURL, Text: config.Text,
+				ParseMode: config.ParseMode, DisableWebPagePreview: config.DisableWebPagePreview, Options: options,
+			})
+		case "exec":
+			sink, err = sinks.NewExec(sinks.ExecConfig{
+				Command: config.Command, Args: config.Args, Env: config.Env, Dir: config.Dir,
+				Timeout: time.Duration(config.Timeout), Options: options,
+			})
+		default:
+			err = fmt.Errorf("unknown type %q", config.Type)
+		}
+		if err != nil {
+			closeSinks(result)
+			return nil, fmt.Errorf("sink %s: %w", config.Name, err)
+		}
+		result = append(result, namedSink{config.Name, sink})
+	}
+	return result, nil
+}
+
+func closeSinks(list []namedSink) error {
+	var errs []error
+	for _, s := range list {
+		if closer, ok := s.sink.(interface{ Close() error }); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
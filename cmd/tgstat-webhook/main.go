@@ -0,0 +1,422 @@
+// Command tgstat-webhook receives TGStat callback events and forwards them to sinks.
+//
+// On start it serves the callback receiver, registers the callback URL with
+// SetCallback and reconciles the subscriptions listed in the configuration.
+// Events are written to a spool directory before being acknowledged, then
+// dispatched to the sinks, through alert rules when configured. Events that
+// keep failing are moved to dead.ndjson in the spool.
+//
+// Usage:
+//
+//	tgstat-webhook -config tgstat-webhook.yaml
+//
+// The server also exposes /healthz and /metrics (Prometheus text format).
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/alert"
+	"github.com/helios-ag/tgstat-go/callback"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// retryDelay is the wait before dispatching an event again.
+var retryDelay = 5 * time.Second
+
+// maxRetrying bounds the failed events retried in the background, the spool
+// is read further only once one of them settles.
+var maxRetrying = 16
+
+func main() {
+	configPath := flag.String("config", "tgstat-webhook.yaml", "configuration file, YAML or JSON")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := run(ctx, *configPath); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func run(ctx context.Context, configPath string) error {
+	config, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	d, err := newDaemon(config)
+	if err != nil {
+		return err
+	}
+	defer d.close()
+
+	listener, err := net.Listen("tcp", config.Listen)
+	if err != nil {
+		return err
+	}
+	timeout := time.Duration(config.Security.RequestTimeout)
+	server := &http.Server{
+		Handler:           d.handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       timeout,
+		WriteTimeout:      timeout + 5*time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.Serve(listener) }()
+	log.Printf("listening on %s", listener.Addr())
+
+	forwardCtx, stopForward := context.WithCancel(context.Background())
+	forwarded := make(chan struct{})
+	go func() {
+		defer close(forwarded)
+		d.forward(forwardCtx)
+	}()
+	if d.engine != nil {
+		go d.engine.Run(forwardCtx, func(err error) { log.Printf("digest: %v", err) }) //nolint
+	}
+
+	if err := d.setup(ctx); err != nil {
+		stopForward()
+		<-forwarded
+		server.Close() //nolint
+		return err
+	}
+	if d.health != nil {
+		go d.health.Run(forwardCtx) //nolint
+	}
+
+	select {
+	case <-ctx.Done():
+	case err := <-serveErr:
+		stopForward()
+		<-forwarded
+		return err
+	}
+
+	log.Print("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Duration(config.ShutdownTimeout))
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+	stopForward()
+	<-forwarded
+	if d.engine != nil {
+		if err := d.engine.Flush(shutdownCtx); err != nil {
+			log.Printf("flush digests: %v", err)
+		}
+	}
+	return nil
+}
+
+// daemon wires the receiver, the spool and the sinks.
+type daemon struct {
+	config   *Config
+	spool    *spool
+	sinks    []namedSink
+	engine   *alert.Engine
+	receiver *callback.Receiver
+	// callbackURL is the registered URL, with the secret.
+	callbackURL string
+	health      *callback.HealthMonitor
+	metrics     metrics
+}
+
+func newDaemon(config *Config) (*daemon, error) {
+	if config.Token != "" {
+		tgstat.Token = config.Token
+	}
+	if config.APIURL != "" {
+		tgstat.WithEndpoint(config.APIURL)
+	}
+
+	spool, err := openSpool(config.Spool)
+	if err != nil {
+		return nil, err
+	}
+	d := &daemon{config: config, spool: spool}
+
+	if d.sinks, err = buildSinks(config.Sinks); err != nil {
+		spool.Close() //nolint
+		return nil, err
+	}
+	if config.Rules != "" {
+		rules, err := alert.LoadFile(config.Rules)
+		if err == nil {
+			sinks := make(map[string]alert.Sink, len(d.sinks))
+			for _, s := range d.sinks {
+				sinks[s.name] = s.sink
+			}
+			d.engine, err = alert.NewEngine(rules, sinks)
+		}
+		if err == nil {
+			d.engine.OnThrottle = func(rule string, event callback.Event) {
+				log.Printf("event %d throttled by rule %s", event.EventID, rule)
+			}
+		}
+		if err != nil {
+			d.close()
+			return nil, err
+		}
+	}
+
+	d.receiver, err = config.receiver(callback.HandlerFunc(func(ctx context.Context, event callback.Event) error {
+		if err := d.spool.HandleEvent(ctx, event); err != nil {
+			return err
+		}
+		d.metrics.received.Add(1)
+		return nil
+	}))
+	if err == nil && config.CallbackURL != "" {
+		d.callbackURL, err = d.receiver.SecretURL(config.CallbackURL)
+	}
+	if err != nil {
+		d.close()
+		return nil, err
+	}
+	d.receiver.OnError = func(r *http.Request, err error) {
+		d.metrics.rejected.Add(1)
+		log.Printf("callback from %s rejected: %v", r.RemoteAddr, err)
+	}
+	if config.CallbackURL != "" {
+		d.health = &callback.HealthMonitor{
+			ExpectedURL:      d.callbackURL,
+			Receiver:         d.receiver,
+			BacklogThreshold: config.BacklogThreshold,
+			Interval:         time.Duration(config.HealthInterval),
+			OnEvent:          func(event callback.HealthEvent) { log.Printf("callback health: %s", event) },
+		}
+	}
+	return d, nil
+}
+
+// setup registers the callback URL and reconciles the subscriptions.
+func (d *daemon) setup(ctx context.Context) error {
+	if d.callbackURL != "" {
+		if err := callback.Register(ctx, d.receiver, d.callbackURL); err != nil {
+			return err
+		}
+		log.Printf("callback URL %s registered", d.config.CallbackURL)
+	}
+	if d.config.hasSubscriptions() {
+		result, err := callback.Reconcile(ctx, d.config.reconcileRequest())
+		if err != nil {
+			return err
+		}
+		log.Printf("subscriptions: %d subscribed, %d updated, %d unsubscribed, %d unchanged",
+			len(result.Subscribed), len(result.Updated), len(result.Unsubscribed), result.Unchanged)
+	}
+	return nil
+}
+
+func (d *daemon) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(d.config.Path, d.receiver)
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		status := map[string]interface{}{"status": "ok", "pending": d.spool.Pending()}
+		if d.health != nil {
+			stats := d.health.Stats()
+			status["callback_healthy"] = stats.Healthy()
+			status["callback_pending"] = stats.Pending
+		}
+		_ = json.NewEncoder(w).Encode(status)
+	})
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		if err := d.writeMetrics(w); err != nil {
+			log.Printf("metrics: %v", err)
+		}
+	})
+	return mux
+}
+
+// outbound is a spooled event being delivered.
+type outbound struct {
+	line     []byte
+	end      int64
+	event    callback.Event
+	attempts int
+	// sent holds the sinks the event reached, without rules.
+	sent    map[string]bool
+	settled bool
+}
+
+// forward dispatches the spooled events until ctx is done. Every event is
+// dispatched once in spool order, an event that fails is retried in the
+// background so that it does not hold back the events behind it. The spool
+// is acknowledged up to the first event still being retried.
+func (d *daemon) forward(ctx context.Context) {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		window []*outbound
+	)
+	defer wg.Wait()
+
+	settle := func(o *outbound) {
+		mu.Lock()
+		defer mu.Unlock()
+		o.settled = true
+		n := 0
+		for n < len(window) && window[n].settled {
+			n++
+		}
+		if n == 0 {
+			return
+		}
+		end := window[n-1].end
+		window = window[n:]
+		if err := d.spool.ack(end, n); err != nil {
+			log.Print(err)
+		}
+	}
+
+	retrying := make(chan struct{}, maxRetrying)
+	pos := d.spool.start()
+	for {
+		line, end, ok, err := d.spool.next(pos)
+		if err != nil {
+			log.Print(err)
+			if !sleep(ctx, retryDelay) {
+				return
+			}
+			continue
+		}
+		if !ok {
+			select {
+			case <-ctx.Done():
+				return
+			case <-d.spool.notify:
+			}
+			continue
+		}
+		pos = end
+
+		o := &outbound{line: line, end: end, sent: make(map[string]bool)}
+		mu.Lock()
+		window = append(window, o)
+		mu.Unlock()
+		if err := json.Unmarshal(line, &o.event); err != nil {
+			d.giveUp(line, fmt.Errorf("decode: %w", err))
+			settle(o)
+			continue
+		}
+		if d.attempt(ctx, o) {
+			settle(o)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case retrying <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-retrying }()
+			if d.retry(ctx, o) {
+				settle(o)
+			}
+		}()
+	}
+}
+
+// retry dispatches a failed event again until it is settled. It returns
+// false when ctx is done first.
+func (d *daemon) retry(ctx context.Context, o *outbound) bool {
+	for {
+		if !sleep(ctx, retryDelay) {
+			return false
+		}
+		if d.attempt(ctx, o) {
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+	}
+}
+
+// attempt dispatches the event once and reports whether it is settled:
+// delivered, or moved to the dead letters after DeliveryAttempts failures.
+func (d *daemon) attempt(ctx context.Context, o *outbound) bool {
+	o.attempts++
+	err := d.dispatch(ctx, o)
+	if err == nil {
+		d.metrics.delivered.Add(1)
+		return true
+	}
+	d.metrics.failures.Add(1)
+	if ctx.Err() != nil {
+		return false
+	}
+	log.Printf("event %d, attempt %d: %v", o.event.EventID, o.attempts, err)
+	if o.attempts >= d.config.DeliveryAttempts {
+		d.giveUp(o.line, err)
+		return true
+	}
+	return false
+}
+
+func (d *daemon) giveUp(line []byte, reason error) {
+	d.metrics.deadLettered.Add(1)
+	if err := d.spool.deadLetter(line, reason); err != nil {
+		log.Print(err)
+	}
+}
+
+// dispatch sends the event through the rules, or to every sink without
+// rules. The engine and o.sent keep the sinks an event already reached from
+// getting it again on the next attempt.
+func (d *daemon) dispatch(ctx context.Context, o *outbound) error {
+	if d.engine != nil {
+		return d.engine.HandleEvent(ctx, o.event)
+	}
+	var errs []error
+	for _, s := range d.sinks {
+		if o.sent[s.name] {
+			continue
+		}
+		if err := s.sink.Send(ctx, []callback.Event{o.event}); err != nil {
+			errs = append(errs, fmt.Errorf("sink %s: %w", s.name, err))
+			continue
+		}
+		o.sent[s.name] = true
+	}
+	return errors.Join(errs...)
+}
+
+func (d *daemon) close() {
+	if err := closeSinks(d.sinks); err != nil {
+		log.Print(err)
+	}
+	if err := d.spool.Close(); err != nil {
+		log.Print(err)
+	}
+}
+
+func sleep(ctx context.Context, delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/callback"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readLines(path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func event(id int64) callback.Event {
+	return callback.Event{EventID: id, EventType: tgstat.EventNewPost, Post: tgstat.Post{ID: id, Text: "post"}}
+}
+
+func TestLoadConfig(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test defaults and subscriptions", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.yaml")
+		writeFile(t, path, `
+token: secret
+callback_url: https://example.com/callback
+security:
+  secret: abc
+  allowed_ips: [192.0.2.0/24]
+subscriptions:
+  channels:
+    - channel: "@durov"
+      event_types: [new_post]
+  keywords:
+    - q: bitcoin
+  prune: true
+sinks:
+  - type: file
+    path: events.ndjson
+`)
+		config, err := loadConfig(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.Listen).To(Equal(":8080"))
+		Expect(config.Path).To(Equal("/callback"))
+		Expect(config.Spool).To(Equal("spool"))
+		Expect(config.DeliveryAttempts).To(Equal(5))
+		Expect(time.Duration(config.ShutdownTimeout)).To(Equal(10 * time.Second))
+		Expect(time.Duration(config.Security.RequestTimeout)).To(Equal(30 * time.Second))
+
+		receiver, err := config.receiver(nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(receiver.Secret).To(Equal("abc"))
+		Expect(receiver.AllowedNetworks).To(HaveLen(1))
+		config.Security.TrustedProxies = []string{"proxy"}
+		_, err = config.receiver(nil)
+		Expect(err).To(MatchError(ContainSubstring("trusted_proxies")))
+
+		request := config.reconcileRequest()
+		Expect(request.Prune).To(BeTrue())
+		Expect(request.Channels).To(HaveLen(1))
+		Expect(request.Channels[0].ChannelId).To(Equal("@durov"))
+		Expect(request.Words).To(HaveLen(1))
+		Expect(request.Words[0].Q).To(Equal("bitcoin"))
+	})
+
+	t.Run("Test token from the environment", func(t *testing.T) {
+		t.Setenv("TGSTAT_TEST_TOKEN", "from-env")
+		path := filepath.Join(t.TempDir(), "config.json")
+		writeFile(t, path, `{"token_env": "TGSTAT_TEST_TOKEN", "callback_url": "https://example.com", "sinks": [{"type": "file", "path": "x"}]}`)
+		config, err := loadConfig(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.Token).To(Equal("from-env"))
+	})
+
+	t.Run("Test invalid configurations", func(t *testing.T) {
+		dir := t.TempDir()
+		for name, content := range map[string]string{
+			"unknown.yaml":  "sinks: [{type: file, path: x}]\nlisen: :80\n",
+			"no-sinks.yaml": "listen: :80\n",
+			"no-token.yaml": "callback_url: https://example.com\nsinks: [{type: file, path: x}]\n",
+			"config.toml":   "",
+		} {
+			path := filepath.Join(dir, name)
+			writeFile(t, path, content)
+			_, err := loadConfig(path)
+			Expect(err).To(HaveOccurred(), name)
+		}
+	})
+
+	t.Run("Test sinks", func(t *testing.T) {
+		dir := t.TempDir()
+		list, err := buildSinks([]SinkConfig{{Type: "file", Path: filepath.Join(dir, "a")}, {Name: "b", Type: "file", Path: filepath.Join(dir, "b")}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list).To(HaveLen(2))
+		Expect(list[0].name).To(Equal("file"))
+		Expect(closeSinks(list)).To(Succeed())
+
+		_, err = buildSinks([]SinkConfig{{Type: "file", Path: filepath.Join(dir, "a")}, {Type: "file", Path: filepath.Join(dir, "b")}})
+		Expect(err).To(MatchError(ContainSubstring("duplicate name")))
+		_, err = buildSinks([]SinkConfig{{Type: "smtp"}})
+		Expect(err).To(MatchError(ContainSubstring("unknown type")))
+	})
+}
+
+func TestSpool(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test resume after restart", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := openSpool(dir)
+		Expect(err).ToNot(HaveOccurred())
+		for id := int64(1); id <= 3; id++ {
+			Expect(s.HandleEvent(context.Background(), event(id))).To(Succeed())
+		}
+		line, end, ok, err := s.next(s.start())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(string(line)).To(ContainSubstring(`"event_id":1`))
+		Expect(s.ack(end, 1)).To(Succeed())
+		Expect(s.Close()).To(Succeed())
+
+		s, err = openSpool(dir)
+		Expect(err).ToNot(HaveOccurred())
+		defer s.Close()
+		Expect(s.Pending()).To(Equal(2))
+		line, end, ok, _ = s.next(s.start())
+		Expect(ok).To(BeTrue())
+		Expect(string(line)).To(ContainSubstring(`"event_id":2`))
+		_, end, _, _ = s.next(end)
+		Expect(s.ack(end, 2)).To(Succeed())
+
+		_, _, ok, err = s.next(end)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ok).To(BeFalse())
+		info, err := os.Stat(filepath.Join(dir, spoolLog))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(info.Size()).To(BeZero())
+	})
+
+	t.Run("Test partial line is dropped", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, spoolLog), "{\"event_id\":1}\n{\"event_id\":2,\"ev")
+		s, err := openSpool(dir)
+		Expect(err).ToNot(HaveOccurred())
+		defer s.Close()
+		Expect(s.Pending()).To(Equal(1))
+		Expect(s.HandleEvent(context.Background(), event(3))).To(Succeed())
+		Expect(readLines(filepath.Join(dir, spoolLog))).To(Equal([]string{
+			`{"event_id":1}`,
+			mustMarshal(event(3)),
+		}))
+	})
+
+	t.Run("Test delivered prefix is compacted", func(t *testing.T) {
+		defer func(size int64) { compactSize = size }(compactSize)
+		compactSize = 1
+
+		dir := t.TempDir()
+		s, err := openSpool(dir)
+		Expect(err).ToNot(HaveOccurred())
+		for id := int64(1); id <= 3; id++ {
+			Expect(s.HandleEvent(context.Background(), event(id))).To(Succeed())
+		}
+		_, end, _, _ := s.next(s.start())
+		_, end, _, _ = s.next(end)
+		Expect(s.ack(end, 2)).To(Succeed())
+		Expect(readLines(filepath.Join(dir, spoolLog))).To(Equal([]string{mustMarshal(event(3))}))
+
+		line, last, ok, err := s.next(end)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ok).To(BeTrue())
+		Expect(string(line)).To(ContainSubstring(`"event_id":3`))
+		Expect(s.HandleEvent(context.Background(), event(4))).To(Succeed())
+		line, _, _, _ = s.next(last)
+		Expect(string(line)).To(ContainSubstring(`"event_id":4`))
+		Expect(s.Close()).To(Succeed())
+
+		s, err = openSpool(dir)
+		Expect(err).ToNot(HaveOccurred())
+		defer s.Close()
+		Expect(s.Pending()).To(Equal(2))
+		line, _, _, _ = s.next(s.start())
+		Expect(string(line)).To(ContainSubstring(`"event_id":3`))
+	})
+
+	t.Run("Test offset past an emptied log", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, spoolLog), "")
+		writeFile(t, filepath.Join(dir, spoolOffset), "120")
+		s, err := openSpool(dir)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(s.Pending()).To(BeZero())
+		Expect(readLines(filepath.Join(dir, spoolOffset))).To(Equal([]string{"0"}))
+
+		// events spooled after the reset survive the next restart
+		Expect(s.HandleEvent(context.Background(), event(1))).To(Succeed())
+		Expect(s.Close()).To(Succeed())
+		s, err = openSpool(dir)
+		Expect(err).ToNot(HaveOccurred())
+		defer s.Close()
+		Expect(s.Pending()).To(Equal(1))
+	})
+}
+
+func mustMarshal(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
+
+func newTestDaemon(t *testing.T, sinks ...SinkConfig) *daemon {
+	t.Helper()
+	dir := t.TempDir()
+	d, err := newDaemon(&Config{
+		Path:             "/callback",
+		Spool:            filepath.Join(dir, "spool"),
+		DeliveryAttempts: 2,
+		Sinks:            sinks,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(d.close)
+	return d
+}
+
+func post(t *testing.T, url, body string) (int, string) {
+	t.Helper()
+	resp, err := http.Post(url, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestDaemon(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test events are delivered to the sinks", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "events.ndjson")
+		d := newTestDaemon(t, SinkConfig{Type: "file", Path: out})
+		testServer := httptest.NewServer(d.handler())
+		defer testServer.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			d.forward(ctx)
+		}()
+		defer func() {
+			cancel()
+			<-done
+		}()
+
+		status, body := post(t, testServer.URL+"/callback", `{"verify_code": "abc"}`)
+		Expect(status).To(Equal(http.StatusOK))
+		Expect(body).To(Equal("abc"))
+
+		for id := int64(1); id <= 2; id++ {
+			status, _ = post(t, testServer.URL+"/callback", mustMarshal(event(id)))
+			Expect(status).To(Equal(http.StatusOK))
+		}
+		Eventually(func() []string { return readLines(out) }).Should(HaveLen(2))
+		Expect(readLines(out)[0]).To(ContainSubstring(`"event_id":1`))
+		Eventually(d.spool.Pending).Should(BeZero())
+
+		status, _ = post(t, testServer.URL+"/callback", `{"event_type": "new_post", "event_id": `)
+		Expect(status).To(Equal(http.StatusBadRequest))
+
+		resp, err := http.Get(testServer.URL + "/healthz")
+		Expect(err).ToNot(HaveOccurred())
+		var health map[string]interface{}
+		Expect(json.NewDecoder(resp.Body).Decode(&health)).To(Succeed())
+		resp.Body.Close()
+		Expect(health).To(Equal(map[string]interface{}{"status": "ok", "pending": float64(0)}))
+
+		resp, err = http.Get(testServer.URL + "/metrics")
+		Expect(err).ToNot(HaveOccurred())
+		metrics, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		Expect(string(metrics)).To(ContainSubstring("tgstat_webhook_events_received_total 2\n"))
+		Expect(string(metrics)).To(ContainSubstring("tgstat_webhook_events_delivered_total 2\n"))
+		Expect(string(metrics)).To(ContainSubstring("tgstat_webhook_requests_rejected_total 1\n"))
+	})
+
+	t.Run("Test failing events are dead lettered", func(t *testing.T) {
+		defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+		retryDelay = time.Millisecond
+
+		d := newTestDaemon(t, SinkConfig{Type: "exec", Command: "false"})
+		Expect(d.spool.HandleEvent(context.Background(), event(7))).To(Succeed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			d.forward(ctx)
+		}()
+		Eventually(d.spool.Pending).Should(BeZero())
+		cancel()
+		<-done
+
+		Expect(d.metrics.failures.Load()).To(Equal(int64(2)))
+		Expect(d.metrics.deadLettered.Load()).To(Equal(int64(1)))
+		lines := readLines(filepath.Join(d.config.Spool, spoolDead))
+		Expect(lines).To(HaveLen(1))
+		var record struct {
+			Error string         `json:"error"`
+			Event callback.Event `json:"event"`
+		}
+		Expect(json.Unmarshal([]byte(lines[0]), &record)).To(Succeed())
+		Expect(record.Event.EventID).To(Equal(int64(7)))
+		Expect(record.Error).To(ContainSubstring("sink exec"))
+	})
+	t.Run("Test a failing event does not hold back the next ones", func(t *testing.T) {
+		defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+		retryDelay = time.Hour
+
+		out := filepath.Join(t.TempDir(), "events.ndjson")
+		d := newTestDaemon(t,
+			SinkConfig{Type: "exec", Command: "sh", Args: []string{"-c", `! grep -q '"event_id":7,'`}},
+			SinkConfig{Type: "file", Path: out},
+		)
+		Expect(d.spool.HandleEvent(context.Background(), event(7))).To(Succeed())
+		Expect(d.spool.HandleEvent(context.Background(), event(8))).To(Succeed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			d.forward(ctx)
+		}()
+		Eventually(func() []string { return readLines(out) }).Should(HaveLen(2))
+		Expect(readLines(out)[1]).To(ContainSubstring(`"event_id":8`))
+		Consistently(d.spool.Pending, 50*time.Millisecond).Should(Equal(2))
+		cancel()
+		<-done
+	})
+
+	t.Run("Test retries skip the sinks already reached", func(t *testing.T) {
+		defer func(delay time.Duration) { retryDelay = delay }(retryDelay)
+		retryDelay = time.Millisecond
+
+		out := filepath.Join(t.TempDir(), "events.ndjson")
+		d := newTestDaemon(t,
+			SinkConfig{Type: "exec", Command: "false"},
+			SinkConfig{Type: "file", Path: out},
+		)
+		Expect(d.spool.HandleEvent(context.Background(), event(7))).To(Succeed())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			d.forward(ctx)
+		}()
+		Eventually(d.spool.Pending).Should(BeZero())
+		cancel()
+		<-done
+
+		Expect(d.metrics.deadLettered.Load()).To(Equal(int64(1)))
+		Expect(readLines(out)).To(HaveLen(1))
+	})
+
+	t.Run("Test callback health metrics", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		testServer.Mux.HandleFunc(endpoints.GetCallbackURL, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tgstat.GetCallbackResponse{Status: "ok", Response: tgstat.CallbackResponse{
+				Url:                "https://example.com/callback?secret=abc",
+				PendingUpdateCount: 12,
+			}})
+		})
+
+		d, err := newDaemon(&Config{
+			Path:             "/callback",
+			Token:            "token",
+			APIURL:           testServer.URL,
+			CallbackURL:      "https://example.com/callback",
+			Security:         SecurityConfig{Secret: "abc"},
+			BacklogThreshold: 10,
+			Spool:            t.TempDir(),
+			DeliveryAttempts: 1,
+			Sinks:            []SinkConfig{{Type: "file", Path: filepath.Join(t.TempDir(), "events.ndjson")}},
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer d.close()
+		events := d.health.Check(context.Background())
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Type).To(Equal(callback.HealthBacklog))
+
+		recorder := httptest.NewRecorder()
+		d.handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		Expect(recorder.Body.String()).To(ContainSubstring("tgstat_webhook_callback_healthy 0\n"))
+		Expect(recorder.Body.String()).To(ContainSubstring("tgstat_webhook_callback_pending_updates 12\n"))
+	})
+}
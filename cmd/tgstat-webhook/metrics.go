@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// metrics are the counters exposed on /metrics in the Prometheus text format.
+type metrics struct {
+	received     atomic.Int64
+	rejected     atomic.Int64
+	delivered    atomic.Int64
+	failures     atomic.Int64
+	deadLettered atomic.Int64
+}
+
+type metric struct {
+	name  string
+	kind  string
+	help  string
+	value int64
+}
+
+func (d *daemon) writeMetrics(w io.Writer) error {
+	list := []metric{
+		{"tgstat_webhook_events_received_total", "counter", "Events stored in the spool.", d.metrics.received.Load()},
+		{"tgstat_webhook_requests_rejected_total", "counter", "Callback requests rejected.", d.metrics.rejected.Load()},
+		{"tgstat_webhook_events_delivered_total", "counter", "Events dispatched to the sinks.", d.metrics.delivered.Load()},
+		{"tgstat_webhook_delivery_failures_total", "counter", "Failed dispatch attempts.", d.metrics.failures.Load()},
+		{"tgstat_webhook_events_dead_lettered_total", "counter", "Events given up on.", d.metrics.deadLettered.Load()},
+		{"tgstat_webhook_spool_pending", "gauge", "Events waiting for delivery.", int64(d.spool.Pending())},
+	}
+	if d.health != nil {
+		stats := d.health.Stats()
+		healthy := int64(0)
+		if stats.Healthy() {
+			healthy = 1
+		}
+		list = append(list,
+			metric{"tgstat_webhook_callback_healthy", "gauge", "Whether the last GetCallbackInfo check found no problem.", healthy},
+			metric{"tgstat_webhook_callback_pending_updates", "gauge", "Updates TGStat has not delivered yet.", int64(stats.Pending)},
+			metric{"tgstat_webhook_callback_delivery_errors_total", "counter", "Delivery errors reported by TGStat.", int64(stats.DeliveryErrors)},
+			metric{"tgstat_webhook_callback_check_failures_total", "counter", "Failed GetCallbackInfo checks.", int64(stats.CheckFailures)},
+			metric{"tgstat_webhook_callback_registrations_total", "counter", "Registrations of the callback URL after a mismatch.", int64(stats.Registrations)},
+		)
+	}
+	for _, m := range list {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", m.name, m.help, m.name, m.kind, m.name, m.value); err != nil {
+			return err
+		}
+	}
+
+	if d.engine == nil {
+		return nil
+	}
+	stats := d.engine.Stats()
+	for _, m := range []struct {
+		name, help string
+		value      func(i int) int
+	}{
+		{"tgstat_webhook_rule_matched_total", "Events matched by the rule.", func(i int) int { return stats[i].Matched }},
+		{"tgstat_webhook_rule_sent_total", "Events of the rule sent to its sinks.", func(i int) int { return stats[i].Sent }},
+		{"tgstat_webhook_rule_throttled_total", "Events of the rule dropped by throttling.", func(i int) int { return stats[i].Throttled }},
+		{"tgstat_webhook_rule_failed_total", "Events of the rule a sink failed on.", func(i int) int { return stats[i].Failed }},
+	} {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", m.name, m.help, m.name); err != nil {
+			return err
+		}
+		for i, rule := range stats {
+			if _, err := fmt.Fprintf(w, "%s{rule=%q} %d\n", m.name, rule.Rule, m.value(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/helios-ag/tgstat-go/callback"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// spool is a durable queue of events: an append-only log of JSON lines and
+// the offset of the first undelivered one. Events are acknowledged to TGStat
+// once synced to disk, and delivered at least once.
+//
+// Positions returned by next are logical: they keep growing when the
+// delivered prefix of the log is dropped, base being its total size.
+type spool struct {
+	dir string
+
+	mu      sync.Mutex
+	log     *os.File
+	size    int64
+	offset  int64
+	base    int64
+	pending int
+	notify  chan struct{}
+}
+
+// compactSize is the size of the delivered prefix above which the log is rewritten.
+var compactSize int64 = 1 << 20
+
+const (
+	spoolLog    = "events.log"
+	spoolOffset = "events.offset"
+	spoolDead   = "dead.ndjson"
+)
+
+func openSpool(dir string) (*spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	log, err := os.OpenFile(filepath.Join(dir, spoolLog), os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &spool{dir: dir, log: log, notify: make(chan struct{}, 1)}
+
+	info, err := log.Stat()
+	if err != nil {
+		log.Close() //nolint
+		return nil, err
+	}
+	s.size = info.Size()
+
+	data, err := os.ReadFile(filepath.Join(dir, spoolOffset))
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		log.Close() //nolint
+		return nil, err
+	default:
+		if s.offset, err = strconv.ParseInt(string(bytes.TrimSpace(data)), 10, 64); err != nil || (s.offset > s.size && s.size != 0) {
+			log.Close() //nolint
+			return nil, fmt.Errorf("spool: corrupted offset %q", data)
+		}
+		// the log was emptied before the offset was reset, save the reset so
+		// the stale offset does not apply once the log grows again
+		if s.offset > s.size {
+			s.offset = s.size
+			if err := s.saveOffset(); err != nil {
+				log.Close() //nolint
+				return nil, err
+			}
+		}
+	}
+
+	// an interrupted append leaves a partial line, drop it
+	if err := s.repair(); err != nil {
+		log.Close() //nolint
+		return nil, err
+	}
+	return s, nil
+}
+
+// repair counts the pending events and truncates a trailing partial line.
+func (s *spool) repair() error {
+	reader := bufio.NewReader(io.NewSectionReader(s.log, s.offset, s.size-s.offset))
+	end := s.offset
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			if len(line) != 0 {
+				if err := s.log.Truncate(end); err != nil {
+					return err
+				}
+			}
+			break
+		}
+		if err != nil {
+			return err
+		}
+		end += int64(len(line))
+		s.pending++
+	}
+	s.size = end
+	return nil
+}
+
+// HandleEvent appends the event and syncs the log, so the delivery can be acknowledged.
+func (s *spool) HandleEvent(_ context.Context, event callback.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, err := s.log.Write(line)
+	if err == nil {
+		err = s.log.Sync()
+	}
+	if err != nil {
+		// drop what was written of the line
+		_ = s.log.Truncate(s.size)
+		return fmt.Errorf("spool: %w", err)
+	}
+	s.size += int64(n)
+	s.pending++
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// start returns the position of the first undelivered line.
+func (s *spool) start() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.base + s.offset
+}
+
+// next returns the line at pos and the position following it, false once
+// pos is at the end of the log.
+func (s *spool) next(pos int64) ([]byte, int64, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	at := pos - s.base
+	if at >= s.size {
+		return nil, 0, false, nil
+	}
+	line, err := bufio.NewReader(io.NewSectionReader(s.log, at, s.size-at)).ReadBytes('\n')
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("spool: %w", err)
+	}
+	return line, pos + int64(len(line)), true, nil
+}
+
+// ack marks the events before end as delivered, count being their number.
+// The log is emptied once everything is delivered, and compacted once the
+// delivered prefix grows past compactSize.
+func (s *spool) ack(end int64, count int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = end - s.base
+	s.pending -= count
+	switch {
+	case s.offset == s.size:
+		if err := s.log.Truncate(0); err != nil {
+			return fmt.Errorf("spool: %w", err)
+		}
+		s.base += s.size
+		s.offset, s.size = 0, 0
+	case s.offset >= compactSize:
+		if err := s.compact(); err != nil {
+			return err
+		}
+	}
+	return s.saveOffset()
+}
+
+// compact replaces the log with its undelivered lines. The offset is reset
+// before the new log is renamed into place, a crash in between delivers the
+// old prefix again rather than skipping events.
+func (s *spool) compact() error {
+	tmp, err := os.CreateTemp(s.dir, ".log-*")
+	if err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint
+	_, err = io.Copy(tmp, io.NewSectionReader(s.log, s.offset, s.size-s.offset))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	log, err := os.OpenFile(tmp.Name(), os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+
+	offset := s.offset
+	s.offset = 0
+	if err := s.saveOffset(); err != nil {
+		s.offset = offset
+		log.Close() //nolint
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, spoolLog)); err != nil {
+		s.offset = offset
+		log.Close() //nolint
+		return fmt.Errorf("spool: %w", err)
+	}
+	s.log.Close() //nolint
+	s.log = log
+	s.base += offset
+	s.size -= offset
+	return nil
+}
+
+// saveOffset replaces the offset file, syncing it and its directory so it
+// survives a crash.
+func (s *spool) saveOffset() error {
+	tmp, err := os.CreateTemp(s.dir, ".offset-*")
+	if err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	defer os.Remove(tmp.Name()) //nolint
+	_, err = tmp.WriteString(strconv.FormatInt(s.offset, 10))
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if err != nil {
+		tmp.Close() //nolint
+		return fmt.Errorf("spool: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(s.dir, spoolOffset)); err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	if err := syncDir(s.dir); err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	return nil
+}
+
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return file.Sync()
+}
+
+// deadLetter keeps an event that could not be delivered, with the reason.
+func (s *spool) deadLetter(line []byte, reason error) error {
+	record, err := json.Marshal(struct {
+		Time  time.Time       `json:"time"`
+		Error string          `json:"error"`
+		Event json.RawMessage `json:"event"`
+	}{time.Now().UTC(), reason.Error(), json.RawMessage(bytes.TrimSpace(line))})
+	if err != nil {
+		// not JSON, keep it as a string
+		record, _ = json.Marshal(map[string]string{"error": reason.Error(), "line": string(line)})
+	}
+
+	file, err := os.OpenFile(filepath.Join(s.dir, spoolDead), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("spool: %w", err)
+	}
+	if _, err := file.Write(append(record, '\n')); err != nil {
+		file.Close() //nolint
+		return fmt.Errorf("spool: %w", err)
+	}
+	return file.Close()
+}
+
+// Pending returns the number of undelivered events.
+func (s *spool) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+func (s *spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.log.Close()
+}
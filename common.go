@@ -9,3 +9,12 @@ type ErrorResult struct {
 type SuccessResult struct {
 	Status string `json:"status"`
 }
+
+// APIError is an error reported by TGStat in the response body.
+type APIError struct {
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
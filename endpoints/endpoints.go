@@ -36,3 +36,13 @@ const (
 	SubscriptionsList string = "/callback/subscriptions-list"
 	Unsubscribe       string = "/callback/unsubscribe"
 )
+
+// Endpoint groups
+const (
+	GroupChannels string = "channels"
+	GroupPosts    string = "posts"
+	GroupWords    string = "words"
+	GroupUsage    string = "usage"
+	GroupDatabase string = "database"
+	GroupCallback string = "callback"
+)
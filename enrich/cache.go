@@ -0,0 +1,124 @@
+package enrich
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// cache is a least recently used cache with expiring entries. Concurrent
+// loads of a key share one request.
+type cache[V any] struct {
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+	loading map[string]*call[V]
+}
+
+type entry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+func newCache[V any](size int, ttl time.Duration, now func() time.Time) *cache[V] {
+	return &cache[V]{
+		size:    size,
+		ttl:     ttl,
+		now:     now,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+		loading: make(map[string]*call[V]),
+	}
+}
+
+// get returns the value of key unless missing or expired.
+func (c *cache[V]) get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	e := element.Value.(*entry[V])
+	if !c.now().Before(e.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(element)
+	return e.value, true
+}
+
+// load returns the cached value of key, or calls fn and caches its result.
+// Errors are not cached.
+func (c *cache[V]) load(ctx context.Context, key string, fn func(context.Context) (V, error)) (V, error) {
+	if value, ok := c.get(key); ok {
+		return value, nil
+	}
+
+	c.mu.Lock()
+	if pending, ok := c.loading[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-pending.done:
+			return pending.value, pending.err
+		case <-ctx.Done():
+			var zero V
+			return zero, ctx.Err()
+		}
+	}
+	pending := &call[V]{done: make(chan struct{})}
+	c.loading[key] = pending
+	c.mu.Unlock()
+
+	pending.value, pending.err = fn(ctx)
+	c.mu.Lock()
+	delete(c.loading, key)
+	c.mu.Unlock()
+	close(pending.done)
+
+	if pending.err == nil {
+		c.add(key, pending.value)
+	}
+	return pending.value, pending.err
+}
+
+func (c *cache[V]) add(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	expires := c.now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		element.Value = &entry[V]{key, value, expires}
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&entry[V]{key, value, expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*entry[V]).key)
+	}
+}
+
+func (c *cache[V]) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
@@ -0,0 +1,396 @@
+// Package enrich resolves the full post and channel of callback events before
+// they reach the handlers.
+//
+// Callback deliveries carry a partial post and channel. Enricher looks them up
+// with posts.Get and channels.Get, and optionally posts.PostStat, and attaches
+// the results to Event.Enrichment. Lookups are cached and go through the
+// client of the tgstat package, so its rate limit and token pool apply.
+// Behind a callback.Router every tenant has its own caches and quota, and its
+// token is used for the lookups.
+//
+// When quota runs low the enricher falls back, the event still reaching the
+// handler with what could be resolved and Enrichment.Degraded set:
+//
+//   - below LowQuota remaining requests, only channels are requested, posts and
+//     statistics come from the cache;
+//   - when no request is left or the API answers with a quota error, only the
+//     cache is used until QuotaCooldown passes.
+package enrich
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/callback"
+	"github.com/helios-ag/tgstat-go/channels"
+	"github.com/helios-ag/tgstat-go/posts"
+	"github.com/helios-ag/tgstat-go/usage"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultChannelTTL is how long a channel is cached.
+	DefaultChannelTTL = time.Hour
+	// DefaultPostTTL is how long a post and its statistics are cached.
+	DefaultPostTTL = 5 * time.Minute
+	// DefaultCacheSize is the number of posts, channels and statistics cached each.
+	DefaultCacheSize = 10000
+	// DefaultLowQuota is the number of remaining requests below which only
+	// channels are requested.
+	DefaultLowQuota = 100
+	// DefaultQuotaInterval is the wait between two calls of Enricher.Quota.
+	DefaultQuotaInterval = 5 * time.Minute
+	// DefaultQuotaCooldown is how long only the cache is used after a quota error.
+	DefaultQuotaCooldown = 10 * time.Minute
+)
+
+// QuotaFunc returns the number of requests left on the tariff.
+type QuotaFunc func(ctx context.Context) (int, error)
+
+// UsageQuota returns a QuotaFunc calling usage.Stat with token. When empty the
+// token of the tenant of a callback.Router is used, or tgstat.Token.
+func UsageQuota(token string) QuotaFunc {
+	return func(ctx context.Context) (int, error) {
+		current := token
+		if tenant, ok := callback.TenantFromContext(ctx); ok && current == "" {
+			current = tenant.Token
+		}
+		if current == "" {
+			current = tgstat.Token
+		}
+		result, _, err := usage.NewClient(current).Stat(ctx)
+		if err != nil {
+			return 0, err
+		}
+		remaining, ok := result.Remaining()
+		if !ok {
+			return 0, errors.New("enrich: usage stat does not count requests")
+		}
+		return remaining, nil
+	}
+}
+
+// Stats are the counters of an Enricher.
+type Stats struct {
+	// Hits and Misses count the cache lookups.
+	Hits   int64
+	Misses int64
+	// Requests counts the API requests, Failures those that failed.
+	Requests int64
+	Failures int64
+	// Degraded counts the events passed on without some of their objects.
+	Degraded int64
+}
+
+// Enricher is a callback.Handler attaching the full post and channel to the
+// events before passing them to Next.
+type Enricher struct {
+	Next callback.Handler
+	// Token authenticates the requests, tgstat.Token when empty. The token of
+	// the tenant takes precedence behind a callback.Router.
+	Token string
+	// Stat also resolves the statistics of the post with posts.PostStat.
+	Stat bool
+	// ChannelTTL, PostTTL and CacheSize default to DefaultChannelTTL,
+	// DefaultPostTTL and DefaultCacheSize.
+	ChannelTTL time.Duration
+	PostTTL    time.Duration
+	CacheSize  int
+	// Quota, when set, is called every QuotaInterval to apply LowQuota.
+	Quota         QuotaFunc
+	LowQuota      int
+	QuotaInterval time.Duration
+	QuotaCooldown time.Duration
+	// Required fails the events whose lookups failed for a reason other than
+	// quota, for the source to deliver them again. By default they are passed
+	// on degraded.
+	Required bool
+	// Now defaults to time.Now.
+	Now func() time.Time
+
+	once   sync.Once
+	mu     sync.Mutex
+	scopes map[string]*scope
+
+	hits, misses, requests, failures, degraded atomic.Int64
+}
+
+// scope is the caches and quota of a tenant, or of the enricher outside a router.
+type scope struct {
+	token    string
+	posts    *cache[*tgstat.PostResponse]
+	channels *cache[*tgstat.ChannelResponse]
+	stats    *cache[*tgstat.PostStatResponse]
+
+	mu             sync.Mutex
+	remaining      int
+	quotaChecked   time.Time
+	exhaustedUntil time.Time
+}
+
+// New returns an enricher passing the events to next.
+func New(next callback.Handler) *Enricher {
+	return &Enricher{Next: next}
+}
+
+func (e *Enricher) init() {
+	e.once.Do(func() {
+		if e.ChannelTTL <= 0 {
+			e.ChannelTTL = DefaultChannelTTL
+		}
+		if e.PostTTL <= 0 {
+			e.PostTTL = DefaultPostTTL
+		}
+		if e.CacheSize <= 0 {
+			e.CacheSize = DefaultCacheSize
+		}
+		if e.LowQuota <= 0 {
+			e.LowQuota = DefaultLowQuota
+		}
+		if e.QuotaInterval <= 0 {
+			e.QuotaInterval = DefaultQuotaInterval
+		}
+		if e.QuotaCooldown <= 0 {
+			e.QuotaCooldown = DefaultQuotaCooldown
+		}
+		if e.Now == nil {
+			e.Now = time.Now
+		}
+		e.scopes = make(map[string]*scope)
+	})
+}
+
+// scope returns the scope of the tenant of ctx.
+func (e *Enricher) scope(ctx context.Context) *scope {
+	name, token := "", e.Token
+	if tenant, ok := callback.TenantFromContext(ctx); ok {
+		name = tenant.Name
+		if tenant.Token != "" {
+			token = tenant.Token
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if s, ok := e.scopes[name]; ok {
+		return s
+	}
+	s := &scope{
+		token:     token,
+		posts:     newCache[*tgstat.PostResponse](e.CacheSize, e.PostTTL, e.Now),
+		channels:  newCache[*tgstat.ChannelResponse](e.CacheSize, e.ChannelTTL, e.Now),
+		stats:     newCache[*tgstat.PostStatResponse](e.CacheSize, e.PostTTL, e.Now),
+		remaining: -1,
+	}
+	e.scopes[name] = s
+	return s
+}
+
+// mode is what may be requested from the API.
+type mode int
+
+const (
+	full mode = iota
+	// lowQuota only requests channels.
+	lowQuota
+	// cacheOnly requests nothing.
+	cacheOnly
+)
+
+// HandleEvent enriches the event and passes it to Next.
+func (e *Enricher) HandleEvent(ctx context.Context, event callback.Event) error {
+	e.init()
+	s := e.scope(ctx)
+	event.Enrichment = &callback.Enrichment{}
+	var errs []error
+
+	channelID := event.Channel.ID
+	if channelID == 0 {
+		channelID = event.Post.ChannelID
+	}
+	if channelID != 0 {
+		channel, err := resolve(ctx, e, s, s.channels, "channel", strconv.Itoa(channelID), lowQuota, s.getChannel)
+		errs = e.record(event.Enrichment, "channel", err, errs)
+		if channel != nil {
+			event.Enrichment.Channel = channel
+			if event.Channel.Title == "" {
+				event.Channel = channel.Summary()
+			}
+		}
+	}
+
+	if event.Post.ID != 0 && event.EventType != tgstat.EventRemovePost {
+		id := strconv.FormatInt(event.Post.ID, 10)
+		if event.EventType == tgstat.EventEditPost {
+			s.posts.remove(id)
+		}
+		post, err := resolve(ctx, e, s, s.posts, "post", id, full, s.getPost)
+		errs = e.record(event.Enrichment, "post", err, errs)
+		event.Enrichment.Post = post
+
+		if e.Stat {
+			stat, err := resolve(ctx, e, s, s.stats, "stat", id, full, s.getStat)
+			errs = e.record(event.Enrichment, "stat", err, errs)
+			event.Enrichment.Stat = stat
+		}
+	}
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if len(event.Enrichment.Degraded) != 0 {
+		e.degraded.Add(1)
+	}
+	if e.Required && len(errs) != 0 {
+		return fmt.Errorf("enrich: event %d: %w", event.EventID, errors.Join(errs...))
+	}
+	return e.Next.HandleEvent(ctx, event)
+}
+
+var (
+	errQuotaLow       = errors.New("quota low")
+	errQuotaExhausted = errors.New("quota exhausted")
+)
+
+// resolve returns the cached object, or requests it unless the quota is
+// below the given mode.
+func resolve[V any](ctx context.Context, e *Enricher, s *scope, c *cache[*V], kind, id string, until mode, get func(context.Context, string) (*V, error)) (*V, error) {
+	if value, ok := c.get(id); ok {
+		e.hits.Add(1)
+		return value, nil
+	}
+	e.misses.Add(1)
+	switch current := e.mode(ctx, s); {
+	case current == cacheOnly:
+		return nil, errQuotaExhausted
+	case current > until:
+		return nil, errQuotaLow
+	}
+	return c.load(ctx, id, func(ctx context.Context) (*V, error) {
+		e.requests.Add(1)
+		s.spend()
+		value, err := get(ctx, id)
+		if err != nil {
+			e.failures.Add(1)
+			if tgstat.IsQuotaError(err) {
+				s.exhausted(e.Now().Add(e.QuotaCooldown))
+			}
+			return nil, fmt.Errorf("%s %s: %w", kind, id, err)
+		}
+		return value, nil
+	})
+}
+
+// record notes a failed lookup, returning the errors Required fails on.
+func (e *Enricher) record(enrichment *callback.Enrichment, kind string, err error, errs []error) []error {
+	switch {
+	case err == nil:
+		return errs
+	case errors.Is(err, errQuotaLow), errors.Is(err, errQuotaExhausted):
+		enrichment.Degraded = append(enrichment.Degraded, fmt.Sprintf("%s: %v", kind, err))
+		return errs
+	case tgstat.IsQuotaError(err):
+		enrichment.Degraded = append(enrichment.Degraded, kind+": quota exhausted")
+		return errs
+	default:
+		enrichment.Degraded = append(enrichment.Degraded, fmt.Sprintf("%s: %v", kind, err))
+		return append(errs, err)
+	}
+}
+
+// mode checks the quota when due and returns what may be requested. The
+// check runs outside the lock, concurrent lookups use the last known value.
+func (e *Enricher) mode(ctx context.Context, s *scope) mode {
+	now := e.Now()
+	s.mu.Lock()
+	if now.Before(s.exhaustedUntil) {
+		s.mu.Unlock()
+		return cacheOnly
+	}
+	check := e.Quota != nil && !now.Before(s.quotaChecked.Add(e.QuotaInterval))
+	if check {
+		s.quotaChecked = now
+	}
+	s.mu.Unlock()
+
+	if check {
+		// keep the last known value when the check fails
+		if remaining, err := e.Quota(ctx); err == nil {
+			s.mu.Lock()
+			s.remaining = remaining
+			s.mu.Unlock()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.remaining == 0:
+		return cacheOnly
+	case s.remaining > 0 && s.remaining < e.LowQuota:
+		return lowQuota
+	default:
+		return full
+	}
+}
+
+// spend counts a request against the last known quota.
+func (s *scope) spend() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.remaining > 0 {
+		s.remaining--
+	}
+}
+
+func (s *scope) exhausted(until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exhaustedUntil = until
+}
+
+// Stats returns the counters of the enricher.
+func (e *Enricher) Stats() Stats {
+	return Stats{
+		Hits:     e.hits.Load(),
+		Misses:   e.misses.Load(),
+		Requests: e.requests.Load(),
+		Failures: e.failures.Load(),
+		Degraded: e.degraded.Load(),
+	}
+}
+
+func (s *scope) tokenOrDefault() string {
+	if s.token != "" {
+		return s.token
+	}
+	return tgstat.Token
+}
+
+func (s *scope) getPost(ctx context.Context, id string) (*tgstat.PostResponse, error) {
+	result, _, err := posts.NewClient(s.tokenOrDefault()).Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &result.Response, nil
+}
+
+func (s *scope) getChannel(ctx context.Context, id string) (*tgstat.ChannelResponse, error) {
+	result, _, err := channels.NewClient(s.tokenOrDefault()).Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &result.Response, nil
+}
+
+func (s *scope) getStat(ctx context.Context, id string) (*tgstat.PostStatResponse, error) {
+	result, _, err := posts.NewClient(s.tokenOrDefault()).PostStat(ctx, posts.PostStatRequest{PostId: id})
+	if err != nil {
+		return nil, err
+	}
+	return &result.Response, nil
+}
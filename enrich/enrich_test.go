@@ -0,0 +1,344 @@
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/callback"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func prepareClient(URL string) {
+	tgstat.Token = "token"
+	tgstat.WithEndpoint(URL)
+}
+
+// fakeAPI serves posts/get, posts/stat, channels/get and usage/stat.
+type fakeAPI struct {
+	mu       sync.Mutex
+	requests map[string]int
+	tokens   map[string]bool
+	// quotaError makes every request fail with a quota error.
+	quotaError bool
+	spent      int
+}
+
+func newFakeAPI(testServer server.Server) *fakeAPI {
+	api := &fakeAPI{requests: map[string]int{}, tokens: map[string]bool{}}
+	serve := func(path string, response func(id string) interface{}) {
+		testServer.Mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			api.mu.Lock()
+			api.requests[path]++
+			api.tokens[r.URL.Query().Get("token")] = true
+			quotaError := api.quotaError
+			api.mu.Unlock()
+
+			w.Header().Set("Content-Type", "application/json")
+			if quotaError {
+				json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": "quota_requests_exceeded"})
+				return
+			}
+			query := r.URL.Query()
+			id := query.Get("postId") + query.Get("channelId")
+			json.NewEncoder(w).Encode(response(id))
+		})
+	}
+	serve(endpoints.PostsGet, func(id string) interface{} {
+		postID, _ := strconv.Atoi(id)
+		return tgstat.PostResult{Status: "ok", Response: tgstat.PostResponse{ID: postID, Text: "full text " + id, Views: 1000}}
+	})
+	serve(endpoints.PostsStat, func(id string) interface{} {
+		return tgstat.PostStatResult{Status: "ok", Response: tgstat.PostStatResponse{ViewsCount: 1000, ForwardsCount: 3}}
+	})
+	serve(endpoints.ChannelsGet, func(id string) interface{} {
+		channelID, _ := strconv.Atoi(id)
+		return tgstat.ChannelResponseResult{Status: "ok", Response: tgstat.ChannelResponse{Id: channelID, Title: "Channel " + id, ParticipantsCount: 500}}
+	})
+	serve(endpoints.UsageStat, func(string) interface{} {
+		api.mu.Lock()
+		defer api.mu.Unlock()
+		return tgstat.StatResult{Status: "ok", Response: []tgstat.StatResponse{{SpentRequests: strconv.Itoa(api.spent) + "/1000"}}}
+	})
+	return api
+}
+
+func (f *fakeAPI) count(path string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requests[path]
+}
+
+func (f *fakeAPI) set(update func(f *fakeAPI)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	update(f)
+}
+
+type recorder struct {
+	mu     sync.Mutex
+	events []callback.Event
+}
+
+func (r *recorder) HandleEvent(_ context.Context, event callback.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recorder) last() callback.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.events[len(r.events)-1]
+}
+
+func newPost(id int64, channelID int) callback.Event {
+	return callback.Event{
+		EventID:   id,
+		EventType: tgstat.EventNewPost,
+		Post:      tgstat.Post{ID: id, ChannelID: channelID, Text: "short"},
+	}
+}
+
+func TestEnricher(t *testing.T) {
+	RegisterTestingT(t)
+	ctx := context.Background()
+
+	t.Run("Test post, channel and stat are attached and cached", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		api := newFakeAPI(testServer)
+
+		next := &recorder{}
+		enricher := New(next)
+		enricher.Stat = true
+		enricher.Token = "tenant-token"
+
+		Expect(enricher.HandleEvent(ctx, newPost(1, 7))).To(Succeed())
+		event := next.last()
+		Expect(event.Enrichment.Post.Text).To(Equal("full text 1"))
+		Expect(event.Enrichment.Channel.Title).To(Equal("Channel 7"))
+		Expect(event.Enrichment.Stat.ForwardsCount).To(Equal(3))
+		Expect(event.Enrichment.Degraded).To(BeEmpty())
+		Expect(event.Channel.ID).To(Equal(7))
+		Expect(event.Channel.Title).To(Equal("Channel 7"))
+		Expect(event.Post.Text).To(Equal("short"))
+
+		Expect(enricher.HandleEvent(ctx, newPost(2, 7))).To(Succeed())
+		Expect(enricher.HandleEvent(ctx, newPost(1, 7))).To(Succeed())
+		Expect(api.count(endpoints.ChannelsGet)).To(Equal(1))
+		Expect(api.count(endpoints.PostsGet)).To(Equal(2))
+		Expect(api.tokens).To(Equal(map[string]bool{"tenant-token": true}))
+
+		// edits invalidate the cached post
+		edit := newPost(1, 7)
+		edit.EventType = tgstat.EventEditPost
+		Expect(enricher.HandleEvent(ctx, edit)).To(Succeed())
+		Expect(api.count(endpoints.PostsGet)).To(Equal(3))
+
+		// removed posts are not requested
+		removal := newPost(3, 7)
+		removal.EventType = tgstat.EventRemovePost
+		Expect(enricher.HandleEvent(ctx, removal)).To(Succeed())
+		Expect(next.last().Enrichment.Post).To(BeNil())
+		Expect(api.count(endpoints.PostsGet)).To(Equal(3))
+
+		Expect(enricher.Stats()).To(Equal(Stats{Hits: 7, Misses: 6, Requests: 6}))
+	})
+
+	t.Run("Test low quota only requests channels", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		api := newFakeAPI(testServer)
+		api.set(func(f *fakeAPI) { f.spent = 950 })
+
+		now := time.Unix(1700000000, 0)
+		next := &recorder{}
+		enricher := New(next)
+		enricher.Quota = UsageQuota("")
+		enricher.Now = func() time.Time { return now }
+
+		Expect(enricher.HandleEvent(ctx, newPost(1, 7))).To(Succeed())
+		event := next.last()
+		Expect(event.Enrichment.Channel).ToNot(BeNil())
+		Expect(event.Enrichment.Post).To(BeNil())
+		Expect(event.Enrichment.Degraded).To(Equal([]string{"post: quota low"}))
+		Expect(api.count(endpoints.PostsGet)).To(BeZero())
+		Expect(api.count(endpoints.UsageStat)).To(Equal(1))
+
+		// the quota is checked again after QuotaInterval
+		api.set(func(f *fakeAPI) { f.spent = 0 })
+		Expect(enricher.HandleEvent(ctx, newPost(2, 7))).To(Succeed())
+		Expect(api.count(endpoints.UsageStat)).To(Equal(1))
+		now = now.Add(DefaultQuotaInterval)
+		Expect(enricher.HandleEvent(ctx, newPost(3, 7))).To(Succeed())
+		Expect(next.last().Enrichment.Post).ToNot(BeNil())
+		Expect(enricher.Stats().Degraded).To(Equal(int64(2)))
+	})
+
+	t.Run("Test quota errors fall back to the cache", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		api := newFakeAPI(testServer)
+
+		now := time.Unix(1700000000, 0)
+		next := &recorder{}
+		enricher := New(next)
+		enricher.Required = true
+		enricher.Now = func() time.Time { return now }
+
+		Expect(enricher.HandleEvent(ctx, newPost(1, 7))).To(Succeed())
+		api.set(func(f *fakeAPI) { f.quotaError = true })
+
+		// quota errors do not fail the event, even when required
+		Expect(enricher.HandleEvent(ctx, newPost(2, 8))).To(Succeed())
+		Expect(next.last().Enrichment.Degraded).To(Equal([]string{"channel: quota exhausted", "post: quota exhausted"}))
+		requests := api.count(endpoints.PostsGet) + api.count(endpoints.ChannelsGet)
+
+		// cached objects are still attached, nothing is requested
+		Expect(enricher.HandleEvent(ctx, newPost(1, 7))).To(Succeed())
+		Expect(next.last().Enrichment.Post).ToNot(BeNil())
+		Expect(enricher.HandleEvent(ctx, newPost(3, 9))).To(Succeed())
+		Expect(api.count(endpoints.PostsGet) + api.count(endpoints.ChannelsGet)).To(Equal(requests))
+
+		now = now.Add(DefaultQuotaCooldown)
+		api.set(func(f *fakeAPI) { f.quotaError = false })
+		Expect(enricher.HandleEvent(ctx, newPost(3, 9))).To(Succeed())
+		Expect(next.last().Enrichment.Degraded).To(BeEmpty())
+	})
+
+	t.Run("Test tenants of a router have their own token and cache", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		api := newFakeAPI(testServer)
+
+		next := &recorder{}
+		enricher := New(next)
+		enricher.Quota = UsageQuota("")
+		router := callback.NewRouter("")
+		for _, name := range []string{"a", "b"} {
+			_, err := router.Add(name, "token-"+name, enricher)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		for _, name := range []string{"a", "b"} {
+			body, _ := json.Marshal(newPost(1, 7))
+			response := httptest.NewRecorder()
+			router.ServeHTTP(response, httptest.NewRequest(http.MethodPost, "/callback/"+name, bytes.NewReader(body)))
+			Expect(response.Code).To(Equal(http.StatusOK))
+		}
+		Expect(next.events).To(HaveLen(2))
+		Expect(api.count(endpoints.PostsGet)).To(Equal(2))
+		Expect(api.count(endpoints.UsageStat)).To(Equal(2))
+		Expect(api.tokens).To(Equal(map[string]bool{"token-a": true, "token-b": true}))
+	})
+
+	t.Run("Test lookups do not wait for the quota check", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		newFakeAPI(testServer)
+
+		checking, release := make(chan struct{}), make(chan struct{})
+		next := &recorder{}
+		enricher := New(next)
+		enricher.Quota = func(ctx context.Context) (int, error) {
+			close(checking)
+			<-release
+			return 1000, nil
+		}
+
+		first := make(chan error, 1)
+		go func() { first <- enricher.HandleEvent(ctx, newPost(1, 7)) }()
+		<-checking
+
+		second := make(chan error, 1)
+		go func() { second <- enricher.HandleEvent(ctx, newPost(2, 8)) }()
+		Eventually(second).Should(Receive(BeNil()))
+
+		close(release)
+		Eventually(first).Should(Receive(BeNil()))
+	})
+
+	t.Run("Test required fails on lookup errors", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+		testServer.Mux.HandleFunc(endpoints.ChannelsGet, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]string{"status": "error", "error": "channel not found"})
+		})
+		testServer.Mux.HandleFunc(endpoints.PostsGet, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tgstat.PostResult{Status: "ok", Response: tgstat.PostResponse{ID: 1}})
+		})
+
+		next := &recorder{}
+		enricher := New(next)
+		Expect(enricher.HandleEvent(ctx, newPost(1, 7))).To(Succeed())
+		Expect(next.last().Enrichment.Degraded).To(Equal([]string{"channel: channel 7: channel not found"}))
+
+		enricher = New(next)
+		enricher.Required = true
+		err := enricher.HandleEvent(ctx, newPost(1, 7))
+		Expect(err).To(MatchError("enrich: event 1: channel 7: channel not found"))
+		Expect(next.events).To(HaveLen(1))
+	})
+}
+
+func TestCache(t *testing.T) {
+	RegisterTestingT(t)
+	now := time.Unix(0, 0)
+	c := newCache[int](2, time.Minute, func() time.Time { return now })
+
+	c.add("a", 1)
+	c.add("b", 2)
+	_, _ = c.get("a")
+	c.add("c", 3)
+	_, found := c.get("b")
+	Expect(found).To(BeFalse())
+	value, ok := c.get("a")
+	Expect(ok).To(BeTrue())
+	Expect(value).To(Equal(1))
+
+	now = now.Add(time.Minute)
+	_, ok = c.get("a")
+	Expect(ok).To(BeFalse())
+
+	var mu sync.Mutex
+	calls := 0
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, err := c.load(context.Background(), "d", func(context.Context) (int, error) {
+				mu.Lock()
+				calls++
+				mu.Unlock()
+				<-release
+				return 4, nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(value).To(Equal(4))
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	Expect(calls).To(Equal(1))
+}
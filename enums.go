@@ -0,0 +1,141 @@
+package tgstat_go
+
+import (
+	"errors"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	"strings"
+)
+
+// Group is the period statistics are grouped by.
+type Group string
+
+const (
+	GroupHour  Group = "hour"
+	GroupDay   Group = "day"
+	GroupWeek  Group = "week"
+	GroupMonth Group = "month"
+)
+
+// PeerType restricts searches to channels, chats or both.
+type PeerType string
+
+const (
+	PeerChannel PeerType = "channel"
+	PeerChat    PeerType = "chat"
+	PeerAll     PeerType = "all"
+)
+
+// EventType is a kind of callback event.
+type EventType string
+
+const (
+	EventNewPost    EventType = "new_post"
+	EventEditPost   EventType = "edit_post"
+	EventRemovePost EventType = "remove_post"
+)
+
+// EventTypes is a set of event types, sent to the API as a comma separated list.
+type EventTypes []EventType
+
+// SubscriptionType is the kind of callback subscription.
+type SubscriptionType string
+
+const (
+	SubscriptionChannel SubscriptionType = "channel"
+	SubscriptionKeyword SubscriptionType = "keyword"
+)
+
+var groupsByEndpoint = map[string][]Group{
+	endpoints.ChannelsSubscribers:   {GroupHour, GroupDay, GroupWeek, GroupMonth},
+	endpoints.ChannelsViews:         {GroupDay, GroupWeek, GroupMonth},
+	endpoints.ChannelAVGPostsReach:  {GroupDay, GroupWeek, GroupMonth},
+	endpoints.ChannelErr:            {GroupDay, GroupWeek, GroupMonth},
+	endpoints.PostsStat:             {GroupHour, GroupDay},
+	endpoints.WordsMentionsByPeriod: {GroupDay, GroupWeek, GroupMonth},
+}
+
+var eventTypesByEndpoint = map[string][]EventType{
+	endpoints.SubscribeChannel: {EventNewPost, EventEditPost, EventRemovePost},
+	endpoints.SubscribeWord:    {EventNewPost},
+}
+
+var peerTypes = []PeerType{PeerChannel, PeerChat, PeerAll}
+
+var subscriptionTypes = []SubscriptionType{SubscriptionChannel, SubscriptionKeyword}
+
+// GroupsFor returns the groups accepted by endpoint.
+func GroupsFor(endpoint string) []Group {
+	return groupsByEndpoint[endpoint]
+}
+
+// ValidFor reports whether endpoint accepts the group.
+func (g Group) ValidFor(endpoint string) bool {
+	return contains(groupsByEndpoint[endpoint], g)
+}
+
+// Valid reports whether the peer type is known.
+func (p PeerType) Valid() bool {
+	return contains(peerTypes, p)
+}
+
+// Valid reports whether the subscription type is known.
+func (t SubscriptionType) Valid() bool {
+	return contains(subscriptionTypes, t)
+}
+
+// ValidFor reports whether endpoint accepts every event type of the set.
+func (e EventTypes) ValidFor(endpoint string) bool {
+	for _, eventType := range e {
+		if !contains(eventTypesByEndpoint[endpoint], eventType) {
+			return false
+		}
+	}
+	return true
+}
+
+// String joins the set with commas, dropping duplicates.
+func (e EventTypes) String() string {
+	seen := make(map[EventType]bool, len(e))
+	values := make([]string, 0, len(e))
+	for _, eventType := range e {
+		if !seen[eventType] {
+			seen[eventType] = true
+			values = append(values, string(eventType))
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+var errInvalidValue = errors.New("must be a valid value")
+
+// AllowedFor returns a validation rule checking that a Group, PeerType,
+// EventTypes or SubscriptionType value is accepted by endpoint.
+// Empty values are considered valid.
+func AllowedFor(endpoint string) func(value interface{}) error {
+	return func(value interface{}) error {
+		valid := true
+		switch v := value.(type) {
+		case Group:
+			valid = v == "" || v.ValidFor(endpoint)
+		case PeerType:
+			valid = v == "" || v.Valid()
+		case EventTypes:
+			valid = v.ValidFor(endpoint)
+		case SubscriptionType:
+			valid = v == "" || v.Valid()
+		}
+		if !valid {
+			return errInvalidValue
+		}
+		return nil
+	}
+}
+
+func contains[T comparable](values []T, value T) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package tgstat_go
+
+import (
+	"github.com/helios-ag/tgstat-go/endpoints"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestEnums(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test groups are checked per endpoint", func(t *testing.T) {
+		Expect(GroupHour.ValidFor(endpoints.ChannelsSubscribers)).To(BeTrue())
+		Expect(GroupHour.ValidFor(endpoints.ChannelsViews)).To(BeFalse())
+		Expect(GroupWeek.ValidFor(endpoints.PostsStat)).To(BeFalse())
+		Expect(GroupsFor(endpoints.PostsStat)).To(Equal([]Group{GroupHour, GroupDay}))
+	})
+
+	t.Run("Test event types set", func(t *testing.T) {
+		events := EventTypes{EventNewPost, EventEditPost, EventNewPost}
+		Expect(events.String()).To(Equal("new_post,edit_post"))
+		Expect(events.ValidFor(endpoints.SubscribeChannel)).To(BeTrue())
+		Expect(events.ValidFor(endpoints.SubscribeWord)).To(BeFalse())
+	})
+
+	t.Run("Test AllowedFor rule", func(t *testing.T) {
+		rule := AllowedFor(endpoints.WordsMentionsByPeriod)
+		Expect(rule(GroupMonth)).To(Succeed())
+		Expect(rule(Group(""))).To(Succeed())
+		Expect(rule(GroupHour)).To(MatchError("must be a valid value"))
+		Expect(rule(PeerChat)).To(Succeed())
+		Expect(rule(PeerType("group"))).To(HaveOccurred())
+		Expect(AllowedFor(endpoints.SubscriptionsList)(SubscriptionKeyword)).To(Succeed())
+		Expect(AllowedFor(endpoints.SubscriptionsList)(SubscriptionType("word"))).To(HaveOccurred())
+	})
+}
@@ -0,0 +1,351 @@
+// Package export flattens results of the library into tables and writes them
+// as CSV, NDJSON or Parquet.
+//
+// Every table builder has a fixed schema, so files written from different
+// pages or runs of the same request always have the same columns.
+package export
+
+import (
+	"errors"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// ColumnType is the type of the values of a column.
+type ColumnType int
+
+const (
+	String ColumnType = iota
+	Int
+	Float
+	Bool
+	Time
+)
+
+// Column is a named, typed column of a table.
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// Schema is the ordered list of columns of a table.
+type Schema []Column
+
+// Row holds a value per column: string, int64, float64, bool or time.Time,
+// or nil for a missing value.
+type Row []interface{}
+
+// Table is a flattened result.
+type Table struct {
+	Schema Schema
+	Rows   []Row
+}
+
+// Writer streams rows of a single schema to a file format.
+type Writer interface {
+	Write(row Row) error
+	// Close flushes buffered rows, it does not close the underlying writer.
+	Close() error
+}
+
+// ErrSchemaMismatch is returned when a table does not match the schema of the writer.
+var ErrSchemaMismatch = errors.New("export: schema mismatch")
+
+// Copy writes the rows of tables to w, the tables must share schema.
+// It is meant to stream the pages of a paginated request into one file.
+func Copy(w Writer, schema Schema, tables ...Table) error {
+	for _, table := range tables {
+		if !schema.equal(table.Schema) {
+			return ErrSchemaMismatch
+		}
+		for _, row := range table.Rows {
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s Schema) equal(other Schema) bool {
+	if len(s) != len(other) {
+		return false
+	}
+	for i := range s {
+		if s[i] != other[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Schema) check(row Row) error {
+	if len(row) != len(s) {
+		return fmt.Errorf("%w: %d values for %d columns", ErrSchemaMismatch, len(row), len(s))
+	}
+	for i, column := range s {
+		ok := false
+		switch row[i].(type) {
+		case nil:
+			ok = true
+		case string:
+			ok = column.Type == String
+		case int64:
+			ok = column.Type == Int
+		case float64:
+			ok = column.Type == Float
+		case bool:
+			ok = column.Type == Bool
+		case time.Time:
+			ok = column.Type == Time
+		}
+		if !ok {
+			return fmt.Errorf("%w: column %s got %T", ErrSchemaMismatch, column.Name, row[i])
+		}
+	}
+	return nil
+}
+
+// PostSchema is the schema of Posts tables.
+var PostSchema = Schema{
+	{"id", Int},
+	{"date", Time},
+	{"views", Int},
+	{"link", String},
+	{"channel_id", Int},
+	{"forwarded_from_channel_id", Int},
+	{"forwarded_from_post_id", Int},
+	{"forwarded_from_link", String},
+	{"is_deleted", Bool},
+	{"text", String},
+	{"snippet", String},
+	{"media_type", String},
+	{"media_mime_type", String},
+	{"media_size", Int},
+	{"media_caption", String},
+	{"channel_link", String},
+	{"channel_username", String},
+	{"channel_title", String},
+	{"channel_participants_count", Int},
+}
+
+// ChannelSchema is the schema of Channels tables.
+var ChannelSchema = Schema{
+	{"id", Int},
+	{"link", String},
+	{"username", String},
+	{"title", String},
+	{"about", String},
+	{"category", String},
+	{"country", String},
+	{"language", String},
+	{"image100", String},
+	{"image640", String},
+	{"participants_count", Int},
+	{"red_label", Bool},
+	{"black_label", Bool},
+}
+
+// MentionsByChannelSchema is the schema of MentionsByChannel tables.
+var MentionsByChannelSchema = Schema{
+	{"channel_id", Int},
+	{"mentions_count", Int},
+	{"views_count", Int},
+	{"last_mention_date", Time},
+	{"channel_link", String},
+	{"channel_username", String},
+	{"channel_title", String},
+	{"channel_participants_count", Int},
+}
+
+// Posts flattens posts with their media and forward source, channel columns
+// are filled from the channel with the matching ID when one is given.
+// A post without a date has no date value.
+func Posts(posts []tgstat.Post, channels ...tgstat.ChannelSummary) Table {
+	byId := channelsById(channels)
+	table := Table{Schema: PostSchema, Rows: make([]Row, 0, len(posts))}
+	for _, p := range posts {
+		channel := byId[p.ChannelID]
+		table.Rows = append(table.Rows, Row{
+			p.ID,
+			unixTime(p.Date),
+			int64(p.Views),
+			p.Link,
+			int64(p.ChannelID),
+			int64(p.ForwardedFrom.ChannelID),
+			p.ForwardedFrom.PostID,
+			p.ForwardedFrom.Link,
+			p.IsDeleted,
+			p.Text,
+			p.Snippet,
+			p.Media.MediaType,
+			p.Media.MimeType,
+			int64(p.Media.Size),
+			p.Media.Caption,
+			channel.Link,
+			channel.Username,
+			channel.Title,
+			int64(channel.ParticipantsCount),
+		})
+	}
+	return table
+}
+
+// Channels flattens channels with their TGStat labels.
+func Channels(channels []tgstat.ChannelSummary) Table {
+	table := Table{Schema: ChannelSchema, Rows: make([]Row, 0, len(channels))}
+	for _, c := range channels {
+		table.Rows = append(table.Rows, Row{
+			int64(c.ID),
+			c.Link,
+			c.Username,
+			c.Title,
+			c.About,
+			c.Category,
+			c.Country,
+			c.Language,
+			c.Image100,
+			c.Image640,
+			int64(c.ParticipantsCount),
+			c.Restrictions.RedLabel,
+			c.Restrictions.BlackLabel,
+		})
+	}
+	return table
+}
+
+// MentionsByChannel flattens the mentions of a word joined with the channels.
+// A channel without a last mention date has no last_mention_date value.
+func MentionsByChannel(result tgstat.WordsMentionsByChannel) Table {
+	byId := channelsById(result.Channels())
+	table := Table{Schema: MentionsByChannelSchema, Rows: make([]Row, 0, len(result.Response.Items))}
+	for _, item := range result.Response.Items {
+		channel := byId[item.ChannelID]
+		table.Rows = append(table.Rows, Row{
+			int64(item.ChannelID),
+			int64(item.MentionsCount),
+			int64(item.ViewsCount),
+			unixTime(item.LastMentionDate),
+			channel.Link,
+			channel.Username,
+			channel.Title,
+			int64(channel.ParticipantsCount),
+		})
+	}
+	return table
+}
+
+// Series flattens time series sharing their periods into one table with a value
+// column per series, named by names. Periods missing from a series have no value.
+func Series(names []string, series ...tgstat.Series) (Table, error) {
+	if len(names) != len(series) {
+		return Table{}, fmt.Errorf("export: %d names for %d series", len(names), len(series))
+	}
+
+	schema := Schema{{"period_start", Time}, {"period_end", Time}}
+	for _, name := range names {
+		schema = append(schema, Column{name, Float})
+	}
+
+	var starts []time.Time
+	rows := make(map[time.Time]Row)
+	for i, s := range series {
+		for _, p := range s.Sorted().Points {
+			row, ok := rows[p.Start]
+			if !ok {
+				row = make(Row, len(schema))
+				row[0], row[1] = p.Start, p.End
+				rows[p.Start] = row
+				starts = append(starts, p.Start)
+			}
+			row[2+i] = p.Value
+		}
+	}
+
+	sort.Slice(starts, func(i, j int) bool { return starts[i].Before(starts[j]) })
+	table := Table{Schema: schema, Rows: make([]Row, 0, len(starts))}
+	for _, start := range starts {
+		table.Rows = append(table.Rows, rows[start])
+	}
+	return table, nil
+}
+
+// From builds the table of a supported result: post, channel and search results,
+// mentions by channel, the period based results, tgstat.Series, []tgstat.Post
+// and []tgstat.ChannelSummary. Pointers to them are accepted too.
+func From(result interface{}) (Table, error) {
+	switch r := result.(type) {
+	case tgstat.PostResult:
+		return Posts(r.Posts()), nil
+	case tgstat.ChannelPostsResult:
+		return Posts(r.Posts(), r.Response.Channel.Summary()), nil
+	case tgstat.ChannelPostsWithChannelResult:
+		return Posts(r.Posts(), r.Response.Channel.Summary()), nil
+	case tgstat.PostSearchResult:
+		return Posts(r.Posts()), nil
+	case tgstat.PostSearchExtendedResult:
+		return Posts(r.Posts(), r.Channels()...), nil
+	case []tgstat.Post:
+		return Posts(r), nil
+	case tgstat.ChannelResponseResult:
+		return Channels([]tgstat.ChannelSummary{r.Response.Summary()}), nil
+	case tgstat.ChannelSearchResult:
+		return Channels(r.Channels()), nil
+	case []tgstat.ChannelSummary:
+		return Channels(r), nil
+	case tgstat.WordsMentionsByChannel:
+		return MentionsByChannel(r), nil
+	case tgstat.ChannelSubscribers:
+		return seriesOf("participants_count", r.Series)
+	case tgstat.ChannelViews:
+		return seriesOf("views_count", r.Series)
+	case tgstat.ChannelAvgReach:
+		return seriesOf("avg_posts_reach", r.Series)
+	case tgstat.ChannelErr:
+		return seriesOf("err", r.Series)
+	case tgstat.WordsMentions:
+		mentions, err := r.MentionsSeries("")
+		if err != nil {
+			return Table{}, err
+		}
+		views, err := r.ViewsSeries("")
+		if err != nil {
+			return Table{}, err
+		}
+		return Series([]string{"mentions_count", "views_count"}, mentions, views)
+	case tgstat.Series:
+		return Series([]string{"value"}, r)
+	}
+
+	if v := reflect.ValueOf(result); v.Kind() == reflect.Ptr && !v.IsNil() {
+		return From(v.Elem().Interface())
+	}
+	return Table{}, fmt.Errorf("export: unsupported result %T", result)
+}
+
+func seriesOf(name string, build func(tgstat.Group) (tgstat.Series, error)) (Table, error) {
+	series, err := build("")
+	if err != nil {
+		return Table{}, err
+	}
+	return Series([]string{name}, series)
+}
+
+// unixTime converts a Unix timestamp of the API to a UTC time, 0 means no date.
+func unixTime(seconds int) interface{} {
+	if seconds == 0 {
+		return nil
+	}
+	return time.Unix(int64(seconds), 0).UTC()
+}
+
+func channelsById(channels []tgstat.ChannelSummary) map[int]tgstat.ChannelSummary {
+	byId := make(map[int]tgstat.ChannelSummary, len(channels))
+	for _, c := range channels {
+		byId[c.ID] = c
+	}
+	return byId
+}
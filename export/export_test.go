@@ -0,0 +1,170 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	tgstat "github.com/helios-ag/tgstat-go"
+	. "github.com/onsi/gomega"
+	"github.com/parquet-go/parquet-go"
+	"io"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTables(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test posts are flattened with media, forwards and channel", func(t *testing.T) {
+		result := tgstat.ChannelPostsResult{Response: tgstat.ChannelPostsResponse{
+			Channel: tgstat.Channel{ID: 7, Username: "@news", Title: "News", ParticipantsCount: 1000},
+			Items: []tgstat.ChannelPostsResponseItem{{
+				ID:            1,
+				Date:          1700000000,
+				Views:         50,
+				ChannelID:     7,
+				ForwardedFrom: tgstat.ForwardSource{ChannelID: 9, PostID: 3, Link: "t.me/source/3"},
+				Text:          "hello",
+				Media:         tgstat.ChannelMedia{MediaType: "mediaPhoto", MimeType: "image/jpeg", Size: 10},
+			}},
+		}}
+
+		table, err := From(&result)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(table.Schema).To(Equal(PostSchema))
+		Expect(table.Rows).To(Equal([]Row{{
+			int64(1), time.Unix(1700000000, 0).UTC(), int64(50), "", int64(7),
+			int64(9), int64(3), "t.me/source/3", false, "hello", "",
+			"mediaPhoto", "image/jpeg", int64(10), "",
+			"", "@news", "News", int64(1000),
+		}}))
+	})
+
+	t.Run("Test series share periods", func(t *testing.T) {
+		mentions := tgstat.WordsMentions{Response: tgstat.WordsMentionsResponse{Items: []tgstat.WordsMentionsResponseItem{
+			{Period: "2024-01-02", MentionsCount: 3, ViewsCount: 30},
+			{Period: "2024-01-01", MentionsCount: 1, ViewsCount: 10},
+		}}}
+
+		table, err := From(mentions)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(table.Schema[2:]).To(Equal(Schema{{"mentions_count", Float}, {"views_count", Float}}))
+		Expect(table.Rows).To(HaveLen(2))
+		Expect(table.Rows[0][0]).To(Equal(time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)))
+		Expect(table.Rows[0][2:]).To(Equal(Row{1.0, 10.0}))
+	})
+
+	t.Run("Test missing periods and dates have no value", func(t *testing.T) {
+		day := func(d int) time.Time { return time.Date(2024, time.January, d, 0, 0, 0, 0, time.UTC) }
+		views := tgstat.Series{Points: []tgstat.SeriesPoint{{Start: day(1), End: day(2), Value: 10}, {Start: day(2), End: day(3), Value: 20}}}
+		reach := tgstat.Series{Points: []tgstat.SeriesPoint{{Start: day(2), End: day(3), Value: 5}}}
+
+		table, err := Series([]string{"views", "reach"}, views, reach)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(table.Rows).To(Equal([]Row{{day(1), day(2), 10.0, nil}, {day(2), day(3), 20.0, 5.0}}))
+
+		Expect(Posts([]tgstat.Post{{ID: 1}}).Rows[0][1]).To(BeNil())
+		mentions := tgstat.WordsMentionsByChannel{}
+		mentions.Response.Items = append(mentions.Response.Items, tgstat.WordsMentionsByChannelItem{ChannelID: 7, MentionsCount: 1})
+		Expect(MentionsByChannel(mentions).Rows[0][3]).To(BeNil())
+	})
+
+	t.Run("Test unsupported results", func(t *testing.T) {
+		_, err := From(42)
+		Expect(err).To(MatchError(ContainSubstring("unsupported result int")))
+
+		var missing *tgstat.PostSearchResult
+		_, err = From(missing)
+		Expect(err).To(HaveOccurred())
+	})
+}
+
+func TestWriters(t *testing.T) {
+	RegisterTestingT(t)
+	schema := Schema{{"id", Int}, {"title", String}, {"score", Float}, {"active", Bool}, {"at", Time}}
+	page := func(ids ...int64) Table {
+		table := Table{Schema: schema}
+		for _, id := range ids {
+			table.Rows = append(table.Rows, Row{id, "a, \"b\"", 0.5, id%2 == 0, time.Unix(id, 0).UTC()})
+		}
+		return table
+	}
+
+	t.Run("Test CSV", func(t *testing.T) {
+		var out bytes.Buffer
+		Expect(WriteCSV(&out, page(1), page(2))).To(Succeed())
+		Expect(out.String()).To(Equal("id,title,score,active,at\n" +
+			"1,\"a, \"\"b\"\"\",0.5,false,1970-01-01T00:00:01Z\n" +
+			"2,\"a, \"\"b\"\"\",0.5,true,1970-01-01T00:00:02Z\n"))
+
+		out.Reset()
+		Expect(WriteCSV(&out, Table{Schema: schema})).To(Succeed())
+		Expect(out.String()).To(Equal("id,title,score,active,at\n"))
+	})
+
+	t.Run("Test NDJSON keeps column order", func(t *testing.T) {
+		var out bytes.Buffer
+		Expect(WriteNDJSON(&out, page(1, 2))).To(Succeed())
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines).To(HaveLen(2))
+		Expect(lines[0]).To(Equal(`{"id":1,"title":"a, \"b\"","score":0.5,"active":false,"at":"1970-01-01T00:00:01Z"}`))
+		Expect(json.Valid([]byte(lines[1]))).To(BeTrue())
+	})
+
+	t.Run("Test schema is enforced", func(t *testing.T) {
+		Expect(WriteCSV(&bytes.Buffer{}, page(1), Table{Schema: PostSchema})).To(MatchError(ErrSchemaMismatch))
+		Expect(NewNDJSONWriter(&bytes.Buffer{}, schema).Write(Row{"1", "", 0.0, false, time.Time{}})).
+			To(MatchError(ContainSubstring("column id got string")))
+	})
+
+	t.Run("Test NDJSON does not write partial lines", func(t *testing.T) {
+		var out bytes.Buffer
+		writer := NewNDJSONWriter(&out, schema)
+		Expect(writer.Write(Row{int64(1), "a", math.NaN(), true, time.Unix(1, 0).UTC()})).
+			To(MatchError(ContainSubstring("column score")))
+		Expect(writer.Write(Row{int64(2), nil, 0.5, nil, time.Unix(2, 0).UTC()})).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+		Expect(out.String()).To(Equal(`{"id":2,"title":null,"score":0.5,"active":null,"at":"1970-01-01T00:00:02Z"}` + "\n"))
+	})
+
+	t.Run("Test Parquet round trip", func(t *testing.T) {
+		var out bytes.Buffer
+		writer := newParquetWriter(&out, schema, 2)
+		rows := page(1, 2, 3)
+		rows.Rows = append(rows.Rows, Row{int64(4), nil, nil, nil, nil})
+		Expect(Copy(writer, schema, rows)).To(Succeed())
+		Expect(writer.Close()).To(Succeed())
+
+		file, err := parquet.OpenFile(bytes.NewReader(out.Bytes()), int64(out.Len()))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.NumRows()).To(Equal(int64(4)))
+		Expect(file.RowGroups()).To(HaveLen(2))
+
+		fields := file.Schema().Fields()
+		Expect(fields).To(HaveLen(len(schema)))
+		for i, kind := range []parquet.Kind{parquet.Int64, parquet.ByteArray, parquet.Double, parquet.Boolean, parquet.Int64} {
+			Expect(fields[i].Name()).To(Equal(schema[i].Name))
+			Expect(fields[i].Type().Kind()).To(Equal(kind))
+			Expect(fields[i].Optional()).To(BeTrue())
+		}
+		Expect(fields[1].Type().LogicalType().String()).To(Equal("STRING"))
+		Expect(fields[4].Type().LogicalType().String()).To(Equal("TIMESTAMP(isAdjustedToUTC=true,unit=MILLIS)"))
+
+		read := make([]parquet.Row, 5)
+		n, err := parquet.NewReader(file).ReadRows(read)
+		Expect(err).To(MatchError(io.EOF))
+		Expect(n).To(Equal(4))
+		for i, row := range read[:3] {
+			id := int64(i + 1)
+			Expect(row[0].Int64()).To(Equal(id))
+			Expect(string(row[1].ByteArray())).To(Equal("a, \"b\""))
+			Expect(row[2].Double()).To(Equal(0.5))
+			Expect(row[3].Boolean()).To(Equal(id%2 == 0))
+			Expect(row[4].Int64()).To(Equal(id * 1000))
+		}
+		Expect(read[3][0].Int64()).To(Equal(int64(4)))
+		for _, value := range read[3][1:] {
+			Expect(value.IsNull()).To(BeTrue())
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package export
+
+import (
+	"github.com/parquet-go/parquet-go"
+	"io"
+	"reflect"
+	"time"
+)
+
+// DefaultRowGroupSize is the number of rows buffered before a Parquet row group is written.
+const DefaultRowGroupSize = 10000
+
+type parquetWriter struct {
+	schema Schema
+	writer *parquet.Writer
+	row    parquet.Row
+}
+
+// NewParquetWriter returns a Writer producing a Parquet file with optional,
+// uncompressed columns in the order of the schema. Rows are buffered per row
+// group of DefaultRowGroupSize rows, the footer is written by Close.
+func NewParquetWriter(w io.Writer, schema Schema) Writer {
+	return newParquetWriter(w, schema, DefaultRowGroupSize)
+}
+
+func newParquetWriter(w io.Writer, schema Schema, rowGroupSize int64) *parquetWriter {
+	return &parquetWriter{
+		schema: schema,
+		writer: parquet.NewWriter(w,
+			parquetSchema(schema),
+			parquet.MaxRowsPerRowGroup(rowGroupSize),
+			parquet.CreatedBy("tgstat-go export", "", ""),
+		),
+		row: make(parquet.Row, len(schema)),
+	}
+}
+
+// WriteParquet writes tables sharing a schema as a single Parquet file.
+func WriteParquet(w io.Writer, tables ...Table) error {
+	return write(NewParquetWriter, w, tables)
+}
+
+func (p *parquetWriter) Write(row Row) error {
+	if err := p.schema.check(row); err != nil {
+		return err
+	}
+	for i, value := range row {
+		if value == nil {
+			p.row[i] = parquet.NullValue().Level(0, 0, i)
+			continue
+		}
+		p.row[i] = parquetValue(value).Level(0, 1, i)
+	}
+	_, err := p.writer.WriteRows([]parquet.Row{p.row})
+	return err
+}
+
+func (p *parquetWriter) Close() error {
+	return p.writer.Close()
+}
+
+func parquetValue(value interface{}) parquet.Value {
+	switch v := value.(type) {
+	case string:
+		return parquet.ByteArrayValue([]byte(v))
+	case int64:
+		return parquet.Int64Value(v)
+	case float64:
+		return parquet.DoubleValue(v)
+	case bool:
+		return parquet.BooleanValue(v)
+	case time.Time:
+		return parquet.Int64Value(v.UnixMilli())
+	}
+	return parquet.NullValue()
+}
+
+func parquetSchema(schema Schema) *parquet.Schema {
+	root := parquetGroup{Group: parquet.Group{}}
+	for _, column := range schema {
+		var node parquet.Node
+		switch column.Type {
+		case String:
+			node = parquet.String()
+		case Float:
+			node = parquet.Leaf(parquet.DoubleType)
+		case Bool:
+			node = parquet.Leaf(parquet.BooleanType)
+		case Time:
+			node = parquet.Timestamp(parquet.Millisecond)
+		default:
+			node = parquet.Int(64)
+		}
+		node = parquet.Optional(node)
+		root.Group[column.Name] = node
+		root.fields = append(root.fields, parquetField{Node: node, name: column.Name})
+	}
+	return parquet.NewSchema("schema", root)
+}
+
+// parquetGroup is a parquet.Group keeping the order of its fields, the
+// columns of parquet.Group are sorted by name.
+type parquetGroup struct {
+	parquet.Group
+	fields []parquet.Field
+}
+
+func (g parquetGroup) Fields() []parquet.Field { return g.fields }
+
+type parquetField struct {
+	parquet.Node
+	name string
+}
+
+func (f parquetField) Name() string { return f.name }
+
+func (f parquetField) Value(base reflect.Value) reflect.Value {
+	return base.MapIndex(reflect.ValueOf(f.name))
+}
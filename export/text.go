@@ -0,0 +1,143 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+type csvWriter struct {
+	schema Schema
+	writer *csv.Writer
+	header bool
+}
+
+// NewCSVWriter returns a Writer producing CSV with a header line of the column names.
+// Times are written in RFC 3339 and missing values as empty fields.
+func NewCSVWriter(w io.Writer, schema Schema) Writer {
+	return &csvWriter{schema: schema, writer: csv.NewWriter(w)}
+}
+
+func (c *csvWriter) Write(row Row) error {
+	if err := c.schema.check(row); err != nil {
+		return err
+	}
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	record := make([]string, len(row))
+	for i, value := range row {
+		record[i] = formatValue(value)
+	}
+	return c.writer.Write(record)
+}
+
+// Close writes the header of an empty table and flushes.
+func (c *csvWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+	c.header = true
+	names := make([]string, len(c.schema))
+	for i, column := range c.schema {
+		names[i] = column.Name
+	}
+	return c.writer.Write(names)
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return ""
+}
+
+type ndjsonWriter struct {
+	schema Schema
+	writer *bufio.Writer
+	keys   [][]byte
+	line   bytes.Buffer
+}
+
+// NewNDJSONWriter returns a Writer producing one JSON object per line,
+// with the keys in the order of the schema.
+func NewNDJSONWriter(w io.Writer, schema Schema) Writer {
+	keys := make([][]byte, len(schema))
+	for i, column := range schema {
+		keys[i], _ = json.Marshal(column.Name)
+	}
+	return &ndjsonWriter{schema: schema, writer: bufio.NewWriter(w), keys: keys}
+}
+
+func (n *ndjsonWriter) Write(row Row) error {
+	if err := n.schema.check(row); err != nil {
+		return err
+	}
+
+	// the line is written once encoded, a failing value leaves no partial line
+	n.line.Reset()
+	n.line.WriteByte('{')
+	for i, value := range row {
+		if i > 0 {
+			n.line.WriteByte(',')
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("export: column %s: %w", n.schema[i].Name, err)
+		}
+		n.line.Write(n.keys[i])
+		n.line.WriteByte(':')
+		n.line.Write(encoded)
+	}
+	n.line.WriteString("}\n")
+	_, err := n.writer.Write(n.line.Bytes())
+	return err
+}
+
+func (n *ndjsonWriter) Close() error {
+	return n.writer.Flush()
+}
+
+// WriteCSV writes tables sharing a schema as a single CSV document.
+func WriteCSV(w io.Writer, tables ...Table) error {
+	return write(NewCSVWriter, w, tables)
+}
+
+// WriteNDJSON writes tables sharing a schema as NDJSON.
+func WriteNDJSON(w io.Writer, tables ...Table) error {
+	return write(NewNDJSONWriter, w, tables)
+}
+
+func write(newWriter func(io.Writer, Schema) Writer, w io.Writer, tables []Table) error {
+	if len(tables) == 0 {
+		return nil
+	}
+	writer := newWriter(w, tables[0].Schema)
+	if err := Copy(writer, tables[0].Schema, tables...); err != nil {
+		return err
+	}
+	return writer.Close()
+}
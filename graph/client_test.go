@@ -0,0 +1,100 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+func prepareClient(URL string) {
+	tgstat.Token = "token"
+	tgstat.WithEndpoint(URL)
+}
+
+func TestCrawl(t *testing.T) {
+	RegisterTestingT(t)
+	// promoters[id] lists the channels mentioning id.
+	mentions := map[string][]int{"1": {2, 3, 2}, "2": {3}, "3": {4}}
+	forwards := map[string][]int{"1": {3}, "2": {1}}
+	channel := func(id int) tgstat.Channel {
+		return tgstat.Channel{ID: id, Title: "Channel " + strconv.Itoa(id)}
+	}
+
+	prepare := func(requested *[]string) server.Server {
+		testServer := server.NewServer()
+		prepareClient(testServer.URL)
+		testServer.Mux.HandleFunc(endpoints.ChannelsGet, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tgstat.ChannelResponseResult{ //nolint
+				Status:   "ok",
+				Response: tgstat.ChannelResponse{Id: 1, Title: "Seed", Username: "@seed"},
+			})
+		})
+		testServer.Mux.HandleFunc(endpoints.ChannelsMentions, func(w http.ResponseWriter, r *http.Request) {
+			id := r.URL.Query().Get("channelId")
+			*requested = append(*requested, "mentions:"+id)
+			response := tgstat.ChannelMentionsResponseExtended{}
+			for _, from := range mentions[id] {
+				response.Items = append(response.Items, tgstat.MentionItem{ChannelID: from})
+				response.Channels = append(response.Channels, channel(from))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tgstat.ChannelMentionsExtended{Status: "ok", Response: response}) //nolint
+		})
+		testServer.Mux.HandleFunc(endpoints.ChannelsForwards, func(w http.ResponseWriter, r *http.Request) {
+			id := r.URL.Query().Get("channelId")
+			*requested = append(*requested, "forwards:"+id)
+			response := tgstat.ChannelForwardsResponseExtended{}
+			for _, from := range forwards[id] {
+				response.Items = append(response.Items, tgstat.ForwardItem{ChannelID: from})
+				response.Channels = append(response.Channels, channel(from))
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tgstat.ChannelForwardsExtended{Status: "ok", Response: response}) //nolint
+		})
+		return testServer
+	}
+
+	t.Run("Test crawl to depth", func(t *testing.T) {
+		var requested []string
+		testServer := prepare(&requested)
+		defer testServer.Teardown()
+
+		g, err := Crawl(context.Background(), CrawlRequest{Seeds: []string{"@seed"}, Depth: 2})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(requested).To(Equal([]string{"mentions:1", "forwards:1", "mentions:2", "forwards:2", "mentions:3", "forwards:3"}))
+		Expect(g.Nodes()).To(HaveLen(4))
+		Expect(g.Edges()).To(Equal([]Edge{
+			{From: 1, To: 2, Forwards: 1},
+			{From: 2, To: 1, Mentions: 2},
+			{From: 3, To: 1, Mentions: 1, Forwards: 1},
+			{From: 3, To: 2, Mentions: 1},
+			{From: 4, To: 3, Mentions: 1},
+		}))
+
+		seed, _ := g.Node(1)
+		Expect(seed.Channel.Username).To(Equal("@seed"))
+		promoter, _ := g.Node(3)
+		Expect(promoter.Depth).To(Equal(1))
+		Expect(promoter.Channel.Title).To(Equal("Channel 3"))
+		outer, _ := g.Node(4)
+		Expect(outer.Depth).To(Equal(2))
+	})
+
+	t.Run("Test crawl options", func(t *testing.T) {
+		var requested []string
+		testServer := prepare(&requested)
+		defer testServer.Teardown()
+
+		g, err := Crawl(context.Background(), CrawlRequest{Seeds: []string{"@seed"}, Depth: 3, SkipForwards: true, MaxNodes: 2})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(requested).To(Equal([]string{"mentions:1", "mentions:2"}))
+		Expect(g.Edges()).To(Equal([]Edge{{From: 2, To: 1, Mentions: 2}}))
+	})
+}
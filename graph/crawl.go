@@ -0,0 +1,133 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/channels"
+	"strconv"
+)
+
+const (
+	// DefaultMaxNodes limits the size of a crawled graph.
+	DefaultMaxNodes = 500
+	// DefaultLimit is the number of mentions and forwards fetched per channel.
+	DefaultLimit = 50
+)
+
+// CrawlRequest describes a crawl from seed channels.
+type CrawlRequest struct {
+	Seeds []string
+	// Depth is the number of hops from the seeds, defaults to 1, the promoters of the seeds only.
+	Depth int
+	// SkipMentions and SkipForwards leave an edge kind out.
+	SkipMentions bool
+	SkipForwards bool
+	// Limit defaults to DefaultLimit.
+	Limit uint64
+	// MaxNodes defaults to DefaultMaxNodes, channels past it are not added.
+	MaxNodes  int
+	StartDate *string
+	EndDate   *string
+}
+
+// Crawl resolves the seeds with channels.Get, then fetches the mentions and
+// forwards of every channel breadth first. On error the graph built so far
+// is returned with it.
+func Crawl(ctx context.Context, request CrawlRequest) (*Graph, error) {
+	if request.Depth <= 0 {
+		request.Depth = 1
+	}
+	if request.Limit == 0 {
+		request.Limit = DefaultLimit
+	}
+	if request.MaxNodes <= 0 {
+		request.MaxNodes = DefaultMaxNodes
+	}
+
+	g := New()
+	var queue []int
+	for _, seed := range request.Seeds {
+		channel, _, err := channels.Get(ctx, seed)
+		if err != nil {
+			return g, fmt.Errorf("graph: seed %s: %w", seed, err)
+		}
+		g.AddNode(channel.Response.Summary(), 0)
+		queue = append(queue, channel.Response.Id)
+	}
+
+	visited := make(map[int]bool)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		node := g.nodes[id]
+		if visited[id] || node.Depth >= request.Depth {
+			continue
+		}
+		visited[id] = true
+
+		promoters, err := crawlChannel(ctx, g, request, node)
+		if err != nil {
+			return g, err
+		}
+		for _, promoter := range promoters {
+			if !visited[promoter] {
+				queue = append(queue, promoter)
+			}
+		}
+	}
+	return g, nil
+}
+
+// crawlChannel adds the channels promoting node and returns their IDs.
+func crawlChannel(ctx context.Context, g *Graph, request CrawlRequest, node *Node) ([]int, error) {
+	forwardRequest := channels.ChannelForwardRequest{
+		ChannelId: strconv.Itoa(node.ID),
+		Limit:     &request.Limit,
+		StartDate: request.StartDate,
+		EndDate:   request.EndDate,
+	}
+
+	var promoters []int
+	add := func(metadata []tgstat.Channel, from int, record func(from, to int)) {
+		if from == node.ID {
+			return
+		}
+		if _, known := g.nodes[from]; !known {
+			if len(g.nodes) >= request.MaxNodes {
+				return
+			}
+			summary := tgstat.ChannelSummary{ID: from}
+			for _, channel := range metadata {
+				if channel.ID == from {
+					summary = channel.Summary()
+				}
+			}
+			g.AddNode(summary, node.Depth+1)
+		}
+		record(from, node.ID)
+		promoters = append(promoters, from)
+	}
+
+	if !request.SkipMentions {
+		mentions, _, err := channels.MentionsExtended(ctx, forwardRequest)
+		if err != nil {
+			return nil, fmt.Errorf("graph: mentions of %d: %w", node.ID, err)
+		}
+		for _, item := range mentions.Response.Items {
+			add(mentions.Response.Channels, item.ChannelID, g.AddMention)
+		}
+	}
+
+	if !request.SkipForwards {
+		forwards, _, err := channels.ForwardsExtended(ctx, forwardRequest)
+		if err != nil {
+			return nil, fmt.Errorf("graph: forwards of %d: %w", node.ID, err)
+		}
+		for _, item := range forwards.Response.Items {
+			add(forwards.Response.Channels, item.ChannelID, g.AddForward)
+		}
+	}
+
+	return promoters, nil
+}
@@ -0,0 +1,224 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+func nodeLabel(node *Node) string {
+	switch {
+	case node.Channel.Title != "":
+		return node.Channel.Title
+	case node.Channel.Username != "":
+		return node.Channel.Username
+	}
+	return strconv.Itoa(node.ID)
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+type graphmlKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphmlNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphmlData `xml:"data"`
+}
+
+type graphmlDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphmlKey `xml:"key"`
+	Graph   struct {
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphmlNode `xml:"node"`
+		Edges       []graphmlEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+// WriteGraphML writes the graph with its channel metadata, ranks and communities as GraphML.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	doc := graphmlDocument{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphmlKey{
+			{"label", "node", "label", "string"},
+			{"username", "node", "username", "string"},
+			{"participants", "node", "participants_count", "int"},
+			{"depth", "node", "depth", "int"},
+			{"pagerank", "node", "pagerank", "double"},
+			{"community", "node", "community", "int"},
+			{"weight", "edge", "weight", "double"},
+			{"mentions", "edge", "mentions", "int"},
+			{"forwards", "edge", "forwards", "int"},
+		},
+	}
+	doc.Graph.EdgeDefault = "directed"
+
+	for _, node := range g.Nodes() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphmlNode{
+			ID: strconv.Itoa(node.ID),
+			Data: []graphmlData{
+				{"label", nodeLabel(node)},
+				{"username", node.Channel.Username},
+				{"participants", strconv.Itoa(node.Channel.ParticipantsCount)},
+				{"depth", strconv.Itoa(node.Depth)},
+				{"pagerank", formatFloat(node.PageRank)},
+				{"community", strconv.Itoa(node.Community)},
+			},
+		})
+	}
+	for _, edge := range g.Edges() {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphmlEdge{
+			Source: strconv.Itoa(edge.From),
+			Target: strconv.Itoa(edge.To),
+			Data: []graphmlData{
+				{"weight", formatFloat(edge.Weight())},
+				{"mentions", strconv.Itoa(edge.Mentions)},
+				{"forwards", strconv.Itoa(edge.Forwards)},
+			},
+		})
+	}
+	return writeXML(w, doc)
+}
+
+type gexfAttribute struct {
+	ID    string `xml:"id,attr"`
+	Title string `xml:"title,attr"`
+	Type  string `xml:"type,attr"`
+}
+
+type gexfValue struct {
+	For   string `xml:"for,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type gexfNode struct {
+	ID     string      `xml:"id,attr"`
+	Label  string      `xml:"label,attr"`
+	Values []gexfValue `xml:"attvalues>attvalue"`
+}
+
+type gexfEdge struct {
+	ID     string      `xml:"id,attr"`
+	Source string      `xml:"source,attr"`
+	Target string      `xml:"target,attr"`
+	Weight string      `xml:"weight,attr"`
+	Values []gexfValue `xml:"attvalues>attvalue"`
+}
+
+type gexfAttributes struct {
+	Class      string          `xml:"class,attr"`
+	Attributes []gexfAttribute `xml:"attribute"`
+}
+
+type gexfDocument struct {
+	XMLName xml.Name `xml:"gexf"`
+	Xmlns   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Graph   struct {
+		DefaultEdgeType string           `xml:"defaultedgetype,attr"`
+		Attributes      []gexfAttributes `xml:"attributes"`
+		Nodes           []gexfNode       `xml:"nodes>node"`
+		Edges           []gexfEdge       `xml:"edges>edge"`
+	} `xml:"graph"`
+}
+
+// WriteGEXF writes the graph with its channel metadata, ranks and communities as GEXF 1.2.
+func (g *Graph) WriteGEXF(w io.Writer) error {
+	doc := gexfDocument{Xmlns: "http://www.gexf.net/1.2draft", Version: "1.2"}
+	doc.Graph.DefaultEdgeType = "directed"
+	doc.Graph.Attributes = []gexfAttributes{
+		{Class: "node", Attributes: []gexfAttribute{
+			{"username", "username", "string"},
+			{"participants", "participants_count", "integer"},
+			{"depth", "depth", "integer"},
+			{"pagerank", "pagerank", "double"},
+			{"community", "community", "integer"},
+		}},
+		{Class: "edge", Attributes: []gexfAttribute{
+			{"mentions", "mentions", "integer"},
+			{"forwards", "forwards", "integer"},
+		}},
+	}
+
+	for _, node := range g.Nodes() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gexfNode{
+			ID:    strconv.Itoa(node.ID),
+			Label: nodeLabel(node),
+			Values: []gexfValue{
+				{"username", node.Channel.Username},
+				{"participants", strconv.Itoa(node.Channel.ParticipantsCount)},
+				{"depth", strconv.Itoa(node.Depth)},
+				{"pagerank", formatFloat(node.PageRank)},
+				{"community", strconv.Itoa(node.Community)},
+			},
+		})
+	}
+	for i, edge := range g.Edges() {
+		doc.Graph.Edges = append(doc.Graph.Edges, gexfEdge{
+			ID:     strconv.Itoa(i),
+			Source: strconv.Itoa(edge.From),
+			Target: strconv.Itoa(edge.To),
+			Weight: formatFloat(edge.Weight()),
+			Values: []gexfValue{
+				{"mentions", strconv.Itoa(edge.Mentions)},
+				{"forwards", strconv.Itoa(edge.Forwards)},
+			},
+		})
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language, nodes of a community share a color index.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintln(b, "digraph tgstat {")
+	for _, node := range g.Nodes() {
+		fmt.Fprintf(b, "  %d [label=%s, pagerank=%s, community=%d, colorscheme=set312, color=%d];\n",
+			node.ID, dotQuote(nodeLabel(node)), formatFloat(node.PageRank), node.Community, node.Community%12+1)
+	}
+	for _, edge := range g.Edges() {
+		fmt.Fprintf(b, "  %d -> %d [weight=%s, mentions=%d, forwards=%d];\n",
+			edge.From, edge.To, formatFloat(edge.Weight()), edge.Mentions, edge.Forwards)
+	}
+	fmt.Fprintln(b, "}")
+	return b.Flush()
+}
+
+func dotQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+}
@@ -0,0 +1,225 @@
+// Package graph builds the network of channels promoting each other through
+// mentions and forwards, ranks it and finds its clusters.
+package graph
+
+import (
+	tgstat "github.com/helios-ag/tgstat-go"
+	"math"
+	"sort"
+)
+
+// Node is a channel of the graph, keyed by its TGStat ID.
+type Node struct {
+	ID      int
+	Channel tgstat.ChannelSummary
+	// Depth is the crawl distance from the nearest seed, -1 for channels
+	// only known from an edge.
+	Depth     int
+	PageRank  float64
+	Community int
+}
+
+// Edge points from the promoting channel to the promoted one.
+type Edge struct {
+	From     int
+	To       int
+	Mentions int
+	Forwards int
+}
+
+// Weight is the number of mentions and forwards of the edge.
+func (e Edge) Weight() float64 {
+	return float64(e.Mentions + e.Forwards)
+}
+
+// Graph is a weighted directed graph of channels.
+type Graph struct {
+	nodes map[int]*Node
+	edges map[[2]int]*Edge
+}
+
+// New returns an empty graph.
+func New() *Graph {
+	return &Graph{nodes: make(map[int]*Node), edges: make(map[[2]int]*Edge)}
+}
+
+// AddNode adds a channel or fills the metadata of a known one.
+func (g *Graph) AddNode(channel tgstat.ChannelSummary, depth int) *Node {
+	node := g.node(channel.ID)
+	if node.Channel.ID == 0 || channel.Title != "" {
+		node.Channel = channel
+	}
+	if node.Depth < 0 || depth < node.Depth {
+		node.Depth = depth
+	}
+	return node
+}
+
+func (g *Graph) node(id int) *Node {
+	node, ok := g.nodes[id]
+	if !ok {
+		node = &Node{ID: id, Depth: -1}
+		g.nodes[id] = node
+	}
+	return node
+}
+
+// AddMention records that from mentioned to, adding the channels missing from the graph.
+func (g *Graph) AddMention(from, to int) {
+	g.edge(from, to).Mentions++
+}
+
+// AddForward records that from forwarded a post of to, adding the channels missing from the graph.
+func (g *Graph) AddForward(from, to int) {
+	g.edge(from, to).Forwards++
+}
+
+func (g *Graph) edge(from, to int) *Edge {
+	key := [2]int{from, to}
+	edge, ok := g.edges[key]
+	if !ok {
+		g.node(from)
+		g.node(to)
+		edge = &Edge{From: from, To: to}
+		g.edges[key] = edge
+	}
+	return edge
+}
+
+// Node returns the node with the given ID.
+func (g *Graph) Node(id int) (*Node, bool) {
+	node, ok := g.nodes[id]
+	return node, ok
+}
+
+// Nodes returns the nodes ordered by ID.
+func (g *Graph) Nodes() []*Node {
+	nodes := make([]*Node, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes
+}
+
+// Edges returns the edges ordered by source and target.
+func (g *Graph) Edges() []Edge {
+	edges := make([]Edge, 0, len(g.edges))
+	for _, edge := range g.edges {
+		edges = append(edges, *edge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// PageRank computes the weighted PageRank of every node and stores it in Node.PageRank.
+// The rank of channels promoting nobody is spread evenly.
+func (g *Graph) PageRank(damping float64, iterations int) {
+	nodes := g.Nodes()
+	n := float64(len(nodes))
+	if n == 0 {
+		return
+	}
+
+	outWeight := make(map[int]float64, len(nodes))
+	for _, edge := range g.edges {
+		outWeight[edge.From] += edge.Weight()
+	}
+
+	rank := make(map[int]float64, len(nodes))
+	for _, node := range nodes {
+		rank[node.ID] = 1 / n
+	}
+
+	for i := 0; i < iterations; i++ {
+		dangling := 0.0
+		for _, node := range nodes {
+			if outWeight[node.ID] == 0 {
+				dangling += rank[node.ID]
+			}
+		}
+
+		next := make(map[int]float64, len(nodes))
+		for _, node := range nodes {
+			next[node.ID] = (1-damping)/n + damping*dangling/n
+		}
+		for _, edge := range g.edges {
+			next[edge.To] += damping * rank[edge.From] * edge.Weight() / outWeight[edge.From]
+		}
+
+		delta := 0.0
+		for id, value := range next {
+			delta += math.Abs(value - rank[id])
+		}
+		rank = next
+		if delta < 1e-9 {
+			break
+		}
+	}
+
+	for _, node := range nodes {
+		node.PageRank = rank[node.ID]
+	}
+}
+
+// Communities groups the nodes with weighted label propagation over the
+// undirected graph and stores the group in Node.Community. Communities are
+// numbered from 0 by their smallest node ID, the count is returned.
+func (g *Graph) Communities(iterations int) int {
+	nodes := g.Nodes()
+	neighbours := make(map[int]map[int]float64, len(nodes))
+	for _, node := range nodes {
+		neighbours[node.ID] = make(map[int]float64)
+	}
+	for _, edge := range g.edges {
+		if edge.From == edge.To {
+			continue
+		}
+		neighbours[edge.From][edge.To] += edge.Weight()
+		neighbours[edge.To][edge.From] += edge.Weight()
+	}
+
+	label := make(map[int]int, len(nodes))
+	for _, node := range nodes {
+		label[node.ID] = node.ID
+	}
+
+	for i := 0; i < iterations; i++ {
+		changed := false
+		for _, node := range nodes {
+			scores := make(map[int]float64)
+			for neighbour, weight := range neighbours[node.ID] {
+				scores[label[neighbour]] += weight
+			}
+			best, bestScore := label[node.ID], scores[label[node.ID]]
+			for candidate, score := range scores {
+				if score > bestScore || (score == bestScore && candidate < best) {
+					best, bestScore = candidate, score
+				}
+			}
+			if best != label[node.ID] {
+				label[node.ID] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	communities := make(map[int]int)
+	for _, node := range nodes {
+		id, ok := communities[label[node.ID]]
+		if !ok {
+			id = len(communities)
+			communities[label[node.ID]] = id
+		}
+		node.Community = id
+	}
+	return len(communities)
+}
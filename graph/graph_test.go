@@ -0,0 +1,119 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/xml"
+	tgstat "github.com/helios-ag/tgstat-go"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+// clusters builds two triangles of mutual promotion joined by a single mention.
+func clusters() *Graph {
+	g := New()
+	for id := 1; id <= 6; id++ {
+		g.AddNode(tgstat.ChannelSummary{ID: id, Title: "Channel"}, 0)
+	}
+	for _, triangle := range [][]int{{1, 2, 3}, {4, 5, 6}} {
+		for _, from := range triangle {
+			for _, to := range triangle {
+				if from != to {
+					g.AddForward(from, to)
+					g.AddForward(from, to)
+				}
+			}
+		}
+	}
+	g.AddMention(3, 4)
+	return g
+}
+
+func TestAlgorithms(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test PageRank", func(t *testing.T) {
+		g := New()
+		for id := 1; id <= 3; id++ {
+			g.AddNode(tgstat.ChannelSummary{ID: id}, 0)
+		}
+		g.AddMention(2, 1)
+		g.AddMention(3, 1)
+		g.PageRank(0.85, 100)
+
+		first, _ := g.Node(1)
+		second, _ := g.Node(2)
+		third, _ := g.Node(3)
+		Expect(first.PageRank).To(BeNumerically(">", second.PageRank))
+		Expect(second.PageRank).To(BeNumerically("~", third.PageRank, 1e-12))
+		Expect(first.PageRank + second.PageRank + third.PageRank).To(BeNumerically("~", 1, 1e-9))
+	})
+
+	t.Run("Test graph built from mentions alone", func(t *testing.T) {
+		g := New()
+		g.AddMention(1, 2)
+		g.AddMention(2, 1)
+		g.AddMention(3, 4)
+		Expect(g.Nodes()).To(HaveLen(4))
+
+		g.PageRank(0.85, 100)
+		total := 0.0
+		for _, node := range g.Nodes() {
+			Expect(node.Depth).To(Equal(-1))
+			total += node.PageRank
+		}
+		Expect(total).To(BeNumerically("~", 1, 1e-9))
+		Expect(g.Communities(20)).To(Equal(2))
+
+		node := g.AddNode(tgstat.ChannelSummary{ID: 3, Title: "Three"}, 2)
+		Expect(node.Depth).To(Equal(2))
+		Expect(node.Channel.Title).To(Equal("Three"))
+	})
+
+	t.Run("Test communities", func(t *testing.T) {
+		g := clusters()
+		Expect(g.Communities(20)).To(Equal(2))
+		for _, node := range g.Nodes() {
+			Expect(node.Community).To(Equal((node.ID - 1) / 3))
+		}
+	})
+}
+
+func TestEncoders(t *testing.T) {
+	RegisterTestingT(t)
+	g := New()
+	g.AddNode(tgstat.ChannelSummary{ID: 1, Title: `Say "hi" & <bye>`, Username: "@one"}, 0)
+	g.AddNode(tgstat.ChannelSummary{ID: 2}, 1)
+	g.AddMention(2, 1)
+	g.AddForward(2, 1)
+	g.PageRank(0.85, 50)
+	g.Communities(10)
+
+	t.Run("Test GraphML", func(t *testing.T) {
+		var out bytes.Buffer
+		Expect(g.WriteGraphML(&out)).To(Succeed())
+		var doc graphmlDocument
+		Expect(xml.Unmarshal(out.Bytes(), &doc)).To(Succeed())
+		Expect(doc.Graph.Nodes).To(HaveLen(2))
+		Expect(doc.Graph.Nodes[0].Data[0].Value).To(Equal(`Say "hi" & <bye>`))
+		Expect(doc.Graph.Nodes[1].Data[0].Value).To(Equal("2"))
+		Expect(doc.Graph.Edges[0].Data[0]).To(Equal(graphmlData{"weight", "2"}))
+	})
+
+	t.Run("Test GEXF", func(t *testing.T) {
+		var out bytes.Buffer
+		Expect(g.WriteGEXF(&out)).To(Succeed())
+		var doc gexfDocument
+		Expect(xml.Unmarshal(out.Bytes(), &doc)).To(Succeed())
+		Expect(doc.Graph.Edges).To(HaveLen(1))
+		Expect(doc.Graph.Edges[0].Source).To(Equal("2"))
+		Expect(doc.Graph.Edges[0].Weight).To(Equal("2"))
+		Expect(doc.Graph.Nodes[0].Values[0]).To(Equal(gexfValue{"username", "@one"}))
+	})
+
+	t.Run("Test DOT", func(t *testing.T) {
+		var out bytes.Buffer
+		Expect(g.WriteDOT(&out)).To(Succeed())
+		Expect(out.String()).To(HavePrefix("digraph tgstat {\n"))
+		Expect(out.String()).To(ContainSubstring(`1 [label="Say \"hi\" & <bye>", pagerank=`))
+		Expect(out.String()).To(ContainSubstring("2 -> 1 [weight=2, mentions=1, forwards=1];"))
+	})
+}
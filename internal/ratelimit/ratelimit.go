@@ -0,0 +1,61 @@
+// Package ratelimit is the token bucket limiting the requests of the client
+// and the sends of the sinks.
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket refilled with a token every interval, holding up
+// to burst tokens. It is safe for concurrent use.
+type Limiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	tokens   int
+	last     time.Time
+}
+
+// New returns a full limiter. Intervals below a nanosecond are raised to one.
+func New(interval time.Duration, burst int) *Limiter {
+	if interval < 1 {
+		interval = 1
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &Limiter{interval: interval, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// Wait blocks until a token is available or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		if refill := int(now.Sub(l.last) / l.interval); refill > 0 {
+			l.tokens += refill
+			l.last = l.last.Add(time.Duration(refill) * l.interval)
+			if l.tokens >= l.burst {
+				l.tokens = l.burst
+				l.last = now
+			}
+		}
+		if l.tokens > 0 {
+			l.tokens--
+			l.mu.Unlock()
+			return nil
+		}
+		delay := l.interval - now.Sub(l.last)
+		l.mu.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
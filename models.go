@@ -0,0 +1,271 @@
+package tgstat_go
+
+import "time"
+
+// Post is the endpoint independent representation of a post.
+// Every post item returned by the API can be converted to it.
+type Post struct {
+	ID            int64         `json:"id"`
+	Date          int           `json:"date"`
+	Views         int           `json:"views"`
+	Link          string        `json:"link"`
+	ChannelID     int           `json:"channel_id"`
+	ForwardedFrom ForwardSource `json:"forwarded_from"`
+	IsDeleted     bool          `json:"is_deleted"`
+	Text          string        `json:"text"`
+	Snippet       string        `json:"snippet,omitempty"`
+	Media         PostMedia     `json:"media"`
+}
+
+// PostMedia merges the media shapes of the different endpoints.
+type PostMedia struct {
+	MediaType string `json:"media_type"`
+	MimeType  string `json:"mime_type,omitempty"`
+	Size      int    `json:"size,omitempty"`
+	Caption   string `json:"caption,omitempty"`
+}
+
+// Time returns the publication date of the post.
+func (p Post) Time() time.Time {
+	return time.Unix(int64(p.Date), 0)
+}
+
+// ChannelSummary is the endpoint independent representation of a channel.
+// Fields not returned by an endpoint are left empty.
+type ChannelSummary struct {
+	ID                int          `json:"id"`
+	Link              string       `json:"link"`
+	Username          string       `json:"username"`
+	Title             string       `json:"title"`
+	About             string       `json:"about"`
+	Category          string       `json:"category,omitempty"`
+	Country           string       `json:"country,omitempty"`
+	Language          string       `json:"language,omitempty"`
+	Image100          string       `json:"image100"`
+	Image640          string       `json:"image640"`
+	ParticipantsCount int          `json:"participants_count"`
+	Restrictions      Restrictions `json:"tgstat_restrictions"`
+}
+
+// Post converts the item to a Post.
+func (p PostResponse) Post() Post {
+	return Post{
+		ID:            int64(p.ID),
+		Date:          p.Date,
+		Views:         p.Views,
+		Link:          p.Link,
+		ChannelID:     p.ChannelID,
+		ForwardedFrom: p.ForwardedFrom,
+		IsDeleted:     p.IsDeleted != 0,
+		Text:          p.Text,
+		Media:         PostMedia{MediaType: p.Media.MediaType, Caption: p.Media.Caption},
+	}
+}
+
+// Post converts the item to a Post.
+func (i ChannelPostsResponseItem) Post() Post {
+	return Post{
+		ID:            i.ID,
+		Date:          i.Date,
+		Views:         i.Views,
+		Link:          i.Link,
+		ChannelID:     i.ChannelID,
+		ForwardedFrom: i.ForwardedFrom,
+		IsDeleted:     i.IsDeleted != 0,
+		Text:          i.Text,
+		Media:         i.Media.postMedia(),
+	}
+}
+
+// Post converts the item to a Post.
+func (i ChannelPostsWithChannelResponseItem) Post() Post {
+	return Post{
+		ID:            i.ID,
+		Date:          i.Date,
+		Views:         i.Views,
+		Link:          i.Link,
+		ChannelID:     i.ChannelID,
+		ForwardedFrom: i.ForwardedFrom,
+		IsDeleted:     i.IsDeleted != 0,
+		Text:          i.Text,
+		Media:         i.Media.postMedia(),
+	}
+}
+
+// Post converts the item to a Post.
+func (i PostSearchResultItem) Post() Post {
+	return Post{
+		ID:            i.ID,
+		Date:          i.Date,
+		Views:         i.Views,
+		Link:          i.Link,
+		ChannelID:     i.ChannelID,
+		ForwardedFrom: i.ForwardedFrom,
+		IsDeleted:     i.IsDeleted != 0,
+		Text:          i.Text,
+		Snippet:       i.Snippet,
+		Media:         PostMedia{MediaType: i.Media.MediaType, MimeType: i.Media.MimeType, Size: i.Media.Size},
+	}
+}
+
+// Post converts the item to a Post.
+func (i PostSearchExtendedResponseItem) Post() Post {
+	return Post{
+		ID:            i.ID,
+		Date:          i.Date,
+		Views:         i.Views,
+		Link:          i.Link,
+		ChannelID:     i.ChannelID,
+		ForwardedFrom: i.ForwardedFrom,
+		IsDeleted:     i.IsDeleted != 0,
+		Text:          i.Text,
+		Snippet:       i.Snippet,
+		Media:         PostMedia{MediaType: i.Media.MediaType, MimeType: i.Media.MimeType, Size: i.Media.Size},
+	}
+}
+
+func (m ChannelMedia) postMedia() PostMedia {
+	return PostMedia{MediaType: m.MediaType, MimeType: m.MimeType, Size: m.Size}
+}
+
+// Summary converts the channel to a ChannelSummary.
+func (c ChannelResponse) Summary() ChannelSummary {
+	return ChannelSummary{
+		ID:                c.Id,
+		Link:              c.Link,
+		Username:          c.Username,
+		Title:             c.Title,
+		About:             c.About,
+		Category:          c.Category,
+		Country:           c.Country,
+		Language:          c.Language,
+		Image100:          c.Image100,
+		Image640:          c.Image640,
+		ParticipantsCount: c.ParticipantsCount,
+		Restrictions:      c.TGStatRestriction,
+	}
+}
+
+// Summary converts the channel to a ChannelSummary.
+func (c Channel) Summary() ChannelSummary {
+	return ChannelSummary{
+		ID:                c.ID,
+		Link:              c.Link,
+		Username:          c.Username,
+		Title:             c.Title,
+		About:             c.About,
+		Image100:          c.Image100,
+		Image640:          c.Image640,
+		ParticipantsCount: c.ParticipantsCount,
+	}
+}
+
+// Summary converts the channel to a ChannelSummary.
+func (c ChannelSearchItem) Summary() ChannelSummary {
+	return Channel{
+		ID:                c.Id,
+		Link:              c.Link,
+		Username:          c.Username,
+		Title:             c.Title,
+		About:             c.About,
+		Image100:          c.Image100,
+		Image640:          c.Image640,
+		ParticipantsCount: c.ParticipantsCount,
+	}.Summary()
+}
+
+// Summary converts the channel to a ChannelSummary.
+func (c PostSearchExtendedChannel) Summary() ChannelSummary {
+	return Channel(c).Summary()
+}
+
+// Summary converts the channel to a ChannelSummary.
+func (c WordsMentionsByChannelChannel) Summary() ChannelSummary {
+	return Channel(c).Summary()
+}
+
+// Posts returns the post of the result as a single element slice.
+func (r PostResult) Posts() []Post {
+	return []Post{r.Response.Post()}
+}
+
+// Posts returns the posts of the result.
+func (r ChannelPostsResult) Posts() []Post {
+	posts := make([]Post, 0, len(r.Response.Items))
+	for _, item := range r.Response.Items {
+		posts = append(posts, item.Post())
+	}
+	return posts
+}
+
+// Posts returns the posts of the result.
+func (r ChannelPostsWithChannelResult) Posts() []Post {
+	posts := make([]Post, 0, len(r.Response.Items))
+	for _, item := range r.Response.Items {
+		posts = append(posts, item.Post())
+	}
+	return posts
+}
+
+// Posts returns the posts of the result.
+func (r PostSearchResult) Posts() []Post {
+	posts := make([]Post, 0, len(r.Response.Items))
+	for _, item := range r.Response.Items {
+		posts = append(posts, item.Post())
+	}
+	return posts
+}
+
+// Posts returns the posts of the result.
+func (r PostSearchExtendedResult) Posts() []Post {
+	posts := make([]Post, 0, len(r.Response.Items))
+	for _, item := range r.Response.Items {
+		posts = append(posts, item.Post())
+	}
+	return posts
+}
+
+// Channels returns the channels of the result.
+func (r ChannelSearchResult) Channels() []ChannelSummary {
+	channels := make([]ChannelSummary, 0, len(r.Response.Items))
+	for _, item := range r.Response.Items {
+		channels = append(channels, item.Summary())
+	}
+	return channels
+}
+
+// Channels returns the channels the posts of the result belong to.
+func (r PostSearchExtendedResult) Channels() []ChannelSummary {
+	channels := make([]ChannelSummary, 0, len(r.Response.Channels))
+	for _, item := range r.Response.Channels {
+		channels = append(channels, item.Summary())
+	}
+	return channels
+}
+
+// Channels returns the channels of the result.
+func (r WordsMentionsByChannel) Channels() []ChannelSummary {
+	channels := make([]ChannelSummary, 0, len(r.Response.Channels))
+	for _, item := range r.Response.Channels {
+		channels = append(channels, item.Summary())
+	}
+	return channels
+}
+
+// Channels returns the channels mentioning the requested one.
+func (r ChannelMentionsExtended) Channels() []ChannelSummary {
+	channels := make([]ChannelSummary, 0, len(r.Response.Channels))
+	for _, item := range r.Response.Channels {
+		channels = append(channels, item.Summary())
+	}
+	return channels
+}
+
+// Channels returns the channels forwarding the requested one.
+func (r ChannelForwardsExtended) Channels() []ChannelSummary {
+	channels := make([]ChannelSummary, 0, len(r.Response.Channels))
+	for _, item := range r.Response.Channels {
+		channels = append(channels, item.Summary())
+	}
+	return channels
+}
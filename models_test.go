@@ -0,0 +1,68 @@
+package tgstat_go
+
+import (
+	"encoding/json"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestPostConversions(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test posts of different endpoints are converted alike", func(t *testing.T) {
+		expected := Post{
+			ID:        12345,
+			Date:      1600000000,
+			Views:     100,
+			Link:      "t.me/channel/12345",
+			ChannelID: 1,
+			IsDeleted: true,
+			Text:      "text",
+			Media:     PostMedia{MediaType: "mediaPhoto", MimeType: "image/jpeg", Size: 10},
+		}
+		item := `{"id":12345,"date":1600000000,"views":100,"link":"t.me/channel/12345","channel_id":1,
+			"forwarded_from":null,"is_deleted":1,"text":"text","media":{"media_type":"mediaPhoto","mime_type":"image/jpeg","size":10}}`
+
+		var channelPosts ChannelPostsResult
+		Expect(json.Unmarshal([]byte(`{"response":{"items":[`+item+`]}}`), &channelPosts)).To(Succeed())
+		Expect(channelPosts.Posts()).To(Equal([]Post{expected}))
+
+		var extended ChannelPostsWithChannelResult
+		Expect(json.Unmarshal([]byte(`{"response":{"items":[`+item+`]}}`), &extended)).To(Succeed())
+		Expect(extended.Posts()).To(Equal([]Post{expected}))
+
+		var search PostSearchResult
+		Expect(json.Unmarshal([]byte(`{"response":{"items":[`+item+`]}}`), &search)).To(Succeed())
+		Expect(search.Posts()).To(Equal([]Post{expected}))
+
+		var post PostResult
+		Expect(json.Unmarshal([]byte(`{"response":`+item+`}`), &post)).To(Succeed())
+		Expect(post.Posts()[0].ID).To(Equal(expected.ID))
+		Expect(post.Posts()[0].Media.MediaType).To(Equal("mediaPhoto"))
+	})
+}
+
+func TestChannelSummaryConversions(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test channels of different endpoints are converted alike", func(t *testing.T) {
+		channel := `{"id":1,"link":"t.me/channel","username":"@channel","title":"Channel","participants_count":10}`
+		expected := ChannelSummary{ID: 1, Link: "t.me/channel", Username: "@channel", Title: "Channel", ParticipantsCount: 10}
+
+		var search ChannelSearchResult
+		Expect(json.Unmarshal([]byte(`{"response":{"items":[`+channel+`]}}`), &search)).To(Succeed())
+		Expect(search.Channels()).To(Equal([]ChannelSummary{expected}))
+
+		var words WordsMentionsByChannel
+		Expect(json.Unmarshal([]byte(`{"response":{"channels":[`+channel+`]}}`), &words)).To(Succeed())
+		Expect(words.Channels()).To(Equal([]ChannelSummary{expected}))
+
+		var posts PostSearchExtendedResult
+		Expect(json.Unmarshal([]byte(`{"response":{"channels":[`+channel+`]}}`), &posts)).To(Succeed())
+		Expect(posts.Channels()).To(Equal([]ChannelSummary{expected}))
+
+		var get ChannelResponseResult
+		Expect(json.Unmarshal([]byte(`{"response":{"id":1,"link":"t.me/channel","username":"@channel","title":"Channel",
+			"participants_count":10,"tgstat_restrictions":{"red_label":true}}}`), &get)).To(Succeed())
+		expected.Restrictions.RedLabel = true
+		Expect(get.Response.Summary()).To(Equal(expected))
+	})
+}
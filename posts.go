@@ -1,19 +1,123 @@
 package tgstat_go
 
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type Media struct {
 	MediaType string `json:"media_type"`
 	Caption   string `json:"caption"`
 }
 
+// ForwardSource is the origin of a forwarded post.
+// TGStat sends it as a link, a channel id or an object, it is empty for posts that are not forwards.
+type ForwardSource struct {
+	ChannelID int    `json:"channel_id,omitempty"`
+	PostID    int64  `json:"post_id,omitempty"`
+	Link      string `json:"link,omitempty"`
+}
+
+// IsZero reports whether the post is not a forward.
+func (f ForwardSource) IsZero() bool {
+	return f == ForwardSource{}
+}
+
+// UnmarshalJSON accepts null, a link string, a channel id number or an object.
+func (f *ForwardSource) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	*f = ForwardSource{}
+
+	switch {
+	case bytes.Equal(data, []byte("null")):
+		return nil
+	case bytes.HasPrefix(data, []byte(`"`)):
+		if err := json.Unmarshal(data, &f.Link); err != nil {
+			return err
+		}
+		f.PostID = postIdFromLink(f.Link)
+		return nil
+	case bytes.HasPrefix(data, []byte("[")):
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil || len(items) != 0 {
+			return fmt.Errorf("forwarded_from: unexpected array")
+		}
+		return nil
+	case len(data) != 0 && (data[0] == '-' || data[0] >= '0' && data[0] <= '9'):
+		id, err := flexibleInt(data)
+		if err != nil {
+			return fmt.Errorf("forwarded_from: %w", err)
+		}
+		f.ChannelID = int(id)
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("forwarded_from: %w", err)
+	}
+	for key, value := range fields {
+		switch key {
+		case "channel_id", "channelId":
+			id, err := flexibleInt(value)
+			if err != nil {
+				return fmt.Errorf("forwarded_from.%s: %w", key, err)
+			}
+			f.ChannelID = int(id)
+		case "post_id", "postId", "id":
+			id, err := flexibleInt(value)
+			if err != nil {
+				return fmt.Errorf("forwarded_from.%s: %w", key, err)
+			}
+			f.PostID = id
+		case "link", "post_link", "postLink":
+			if err := json.Unmarshal(value, &f.Link); err != nil {
+				return fmt.Errorf("forwarded_from.%s: %w", key, err)
+			}
+		}
+	}
+	if f.PostID == 0 {
+		f.PostID = postIdFromLink(f.Link)
+	}
+	return nil
+}
+
+// flexibleInt decodes a number that may be quoted.
+func flexibleInt(data json.RawMessage) (int64, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if s == "" {
+			return 0, nil
+		}
+		return strconv.ParseInt(s, 10, 64)
+	}
+	var n int64
+	err := json.Unmarshal(data, &n)
+	return n, err
+}
+
+// postIdFromLink extracts the message id from links like t.me/channel/123.
+func postIdFromLink(link string) int64 {
+	idx := strings.LastIndex(link, "/")
+	if idx < 0 {
+		return 0
+	}
+	id, _ := strconv.ParseInt(link[idx+1:], 10, 64)
+	return id
+}
+
 type PostResponse struct {
-	ID            int         `json:"id"`
-	Date          int         `json:"date"`
-	Views         int         `json:"views"`
-	Link          string      `json:"link"`
-	ChannelID     int         `json:"channel_id"`
-	ForwardedFrom interface{} `json:"forwarded_from"`
-	IsDeleted     int         `json:"is_deleted"`
-	Text          string      `json:"text"`
+	ID            int           `json:"id"`
+	Date          int           `json:"date"`
+	Views         int           `json:"views"`
+	Link          string        `json:"link"`
+	ChannelID     int           `json:"channel_id"`
+	ForwardedFrom ForwardSource `json:"forwarded_from"`
+	IsDeleted     int           `json:"is_deleted"`
+	Text          string        `json:"text"`
 	Media         `json:"media"`
 }
 
@@ -56,15 +160,15 @@ type PostStatResult struct {
 }
 
 type PostSearchResultItem struct {
-	ID            int64       `json:"id"`
-	Date          int         `json:"date"`
-	Views         int         `json:"views"`
-	Link          string      `json:"link"`
-	ChannelID     int         `json:"channel_id"`
-	ForwardedFrom interface{} `json:"forwarded_from"`
-	IsDeleted     int         `json:"is_deleted"`
-	Text          string      `json:"text"`
-	Snippet       string      `json:"snippet"`
+	ID            int64         `json:"id"`
+	Date          int           `json:"date"`
+	Views         int           `json:"views"`
+	Link          string        `json:"link"`
+	ChannelID     int           `json:"channel_id"`
+	ForwardedFrom ForwardSource `json:"forwarded_from"`
+	IsDeleted     int           `json:"is_deleted"`
+	Text          string        `json:"text"`
+	Snippet       string        `json:"snippet"`
 	Media         struct {
 		MediaType string `json:"media_type"`
 		MimeType  string `json:"mime_type"`
@@ -83,15 +187,15 @@ type PostSearchResult struct {
 	Response PostSearchResultResponse `json:"response"`
 }
 type PostSearchExtendedResponseItem struct {
-	ID            int64       `json:"id"`
-	Date          int         `json:"date"`
-	Views         int         `json:"views"`
-	Link          string      `json:"link"`
-	ChannelID     int         `json:"channel_id"`
-	ForwardedFrom interface{} `json:"forwarded_from"`
-	IsDeleted     int         `json:"is_deleted"`
-	Text          string      `json:"text"`
-	Snippet       string      `json:"snippet"`
+	ID            int64         `json:"id"`
+	Date          int           `json:"date"`
+	Views         int           `json:"views"`
+	Link          string        `json:"link"`
+	ChannelID     int           `json:"channel_id"`
+	ForwardedFrom ForwardSource `json:"forwarded_from"`
+	IsDeleted     int           `json:"is_deleted"`
+	Text          string        `json:"text"`
+	Snippet       string        `json:"snippet"`
 	Media         struct {
 		MediaType string `json:"media_type"`
 		MimeType  string `json:"mime_type"`
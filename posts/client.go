@@ -51,13 +51,13 @@ func (c Client) Get(ctx context.Context, postId string) (*tgstat.PostResult, *ht
 
 type PostStatRequest struct {
 	PostId string
-	Group  *string
+	Group  tgstat.Group
 }
 
 func (postStatRequest PostStatRequest) Validate() error {
 	return validation.ValidateStruct(&postStatRequest,
 		validation.Field(&postStatRequest.PostId, validation.Required),
-		validation.Field(&postStatRequest.Group, validation.In("hour", "day")),
+		validation.Field(&postStatRequest.Group, validation.By(tgstat.AllowedFor(endpoints.PostsStat))),
 	)
 }
 
@@ -78,8 +78,8 @@ func (c Client) PostStat(ctx context.Context, request PostStatRequest) (*tgstat.
 
 	body := make(map[string]string)
 	body["postId"] = request.PostId
-	if nil != request.Group {
-		body["group"] = *request.Group
+	if request.Group != "" {
+		body["group"] = string(request.Group)
 	}
 
 	req, err := c.api.NewRestRequest(ctx, c.token, http.MethodGet, path, body)
@@ -102,7 +102,7 @@ type PostSearchRequest struct {
 	Q              string
 	Limit          *int
 	Offset         *int
-	PeerType       *string
+	PeerType       tgstat.PeerType
 	StartDate      *string
 	EndDate        *string
 	HideForwards   *bool
@@ -115,6 +115,7 @@ type PostSearchRequest struct {
 func (postSearchRequest PostSearchRequest) Validate() error {
 	return validation.ValidateStruct(&postSearchRequest,
 		validation.Field(&postSearchRequest.Q, validation.Required),
+		validation.Field(&postSearchRequest.PeerType, validation.By(tgstat.AllowedFor(endpoints.PostsSearch))),
 		validation.Field(&postSearchRequest.Limit, validation.Max(50)),
 		validation.Field(&postSearchRequest.Offset, validation.Max(50)),
 	)
@@ -215,8 +216,8 @@ func makeRequestBody(request PostSearchRequest) map[string]string {
 		body["offset"] = strconv.Itoa(*request.Offset)
 	}
 
-	if nil != request.PeerType {
-		body["peerType"] = *request.PeerType
+	if request.PeerType != "" {
+		body["peerType"] = string(request.PeerType)
 	}
 
 	if nil != request.StartDate {
@@ -268,3 +269,8 @@ func makeRequestBody(request PostSearchRequest) map[string]string {
 func getClient() Client {
 	return Client{tgstat.GetAPI(), tgstat.Token}
 }
+
+// NewClient returns a client authenticated with token.
+func NewClient(token string) Client {
+	return Client{tgstat.GetAPI(), token}
+}
@@ -69,7 +69,7 @@ func TestClient_PostsGet(t *testing.T) {
 					Views:         0,
 					Link:          "",
 					ChannelID:     0,
-					ForwardedFrom: nil,
+					ForwardedFrom: tgstat.ForwardSource{},
 					IsDeleted:     0,
 					Text:          "",
 					Media:         tgstat.Media{},
@@ -83,6 +83,40 @@ func TestClient_PostsGet(t *testing.T) {
 			"Status": ContainSubstring("ok"),
 		})))
 	})
+
+	t.Run("Test PostsGet forwarded_from shapes", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+
+		forwards := map[string]string{
+			"null":   `null`,
+			"link":   `"https://t.me/source/42"`,
+			"object": `{"channel_id":"7","post_id":42,"link":"https://t.me/source/42"}`,
+			"number": `7`,
+		}
+		testServer.Mux.HandleFunc(endpoints.PostsGet, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"status":"ok","response":{"id":1,"forwarded_from":` + forwards[r.URL.Query().Get("postId")] + `}}`))
+		})
+
+		response, _, err := Get(context.Background(), "null")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.Response.ForwardedFrom.IsZero()).To(BeTrue())
+
+		response, _, err = Get(context.Background(), "link")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.Response.ForwardedFrom).To(Equal(tgstat.ForwardSource{PostID: 42, Link: "https://t.me/source/42"}))
+
+		response, _, err = Get(context.Background(), "object")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.Response.ForwardedFrom).To(Equal(tgstat.ForwardSource{ChannelID: 7, PostID: 42, Link: "https://t.me/source/42"}))
+
+		response, _, err = Get(context.Background(), "number")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.Response.ForwardedFrom).To(Equal(tgstat.ForwardSource{ChannelID: 7}))
+	})
 }
 
 func TestClient_PostsStat(t *testing.T) {
@@ -94,7 +128,6 @@ func TestClient_PostsStat(t *testing.T) {
 
 		req := PostStatRequest{
 			PostId: "",
-			Group:  nil,
 		}
 		_, _, err := PostStat(context.Background(), req)
 		Expect(err).To(HaveOccurred())
@@ -108,7 +141,7 @@ func TestClient_PostsStat(t *testing.T) {
 
 		req := PostStatRequest{
 			PostId: "t.me/123/123",
-			Group:  tgstat.String("test"),
+			Group:  tgstat.Group("test"),
 		}
 		_, _, err := PostStat(context.Background(), req)
 		Expect(err).To(HaveOccurred())
@@ -131,7 +164,7 @@ func TestClient_PostsStat(t *testing.T) {
 
 		req := PostStatRequest{
 			PostId: "t.me/123/123",
-			Group:  tgstat.String("day"),
+			Group:  tgstat.GroupDay,
 		}
 		_, _, err := PostStat(context.Background(), req)
 		Expect(err).ToNot(HaveOccurred())
@@ -145,7 +178,6 @@ func TestClient_PostsStat(t *testing.T) {
 		tgstat.NewRestRequest = NewRestRequestStub
 		req := PostStatRequest{
 			PostId: "321",
-			Group:  nil,
 		}
 
 		_, _, err := PostStat(context.Background(), req)
@@ -170,7 +202,6 @@ func TestClient_PostsStat(t *testing.T) {
 
 		req := PostStatRequest{
 			PostId: "321",
-			Group:  nil,
 		}
 		response, _, err := PostStat(context.Background(), req)
 		Expect(err).ToNot(HaveOccurred())
@@ -418,7 +449,7 @@ func Test_makeRequestBody(t *testing.T) {
 			Q:              "Test",
 			Limit:          tgstat.Int(30),
 			Offset:         tgstat.Int(20),
-			PeerType:       tgstat.String("1"),
+			PeerType:       tgstat.PeerType("1"),
 			StartDate:      tgstat.String("1"),
 			EndDate:        tgstat.String("1"),
 			HideForwards:   tgstat.Bool(true),
@@ -436,7 +467,7 @@ func Test_makeRequestBody(t *testing.T) {
 			Q:              "Test",
 			Limit:          tgstat.Int(30),
 			Offset:         tgstat.Int(20),
-			PeerType:       tgstat.String("1"),
+			PeerType:       tgstat.PeerType("1"),
 			StartDate:      tgstat.String("1"),
 			EndDate:        tgstat.String("1"),
 			HideForwards:   tgstat.Bool(false),
@@ -0,0 +1,216 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SyntaxError describes where an expression could not be parsed.
+type SyntaxError struct {
+	Offset  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("query: %s at offset %d", e.Message, e.Offset)
+}
+
+// Parse turns an extended syntax expression into its tree.
+// The result is validated, so Parse also serves as a linter.
+func Parse(expression string) (Node, error) {
+	p := &parser{input: []rune(expression)}
+	p.skipSpaces()
+	if p.eof() {
+		return nil, &SyntaxError{Offset: 0, Message: "empty expression"}
+	}
+
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	if err := Validate(node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+type parser struct {
+	input []rune
+	pos   int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.input)
+}
+
+func (p *parser) peek() rune {
+	return p.input[p.pos]
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{Offset: p.pos, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) skipSpaces() {
+	for !p.eof() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+// parseAnd parses operands separated by spaces.
+func (p *parser) parseAnd() (Node, error) {
+	var nodes []Node
+	for {
+		p.skipSpaces()
+		if p.eof() || p.peek() == ')' {
+			break
+		}
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	switch len(nodes) {
+	case 0:
+		return nil, p.errorf("empty group")
+	case 1:
+		return nodes[0], nil
+	}
+	return AndNode{Nodes: nodes}, nil
+}
+
+// parseOr parses operands separated by "|".
+func (p *parser) parseOr() (Node, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	nodes := []Node{node}
+	for {
+		save := p.pos
+		p.skipSpaces()
+		if p.eof() || p.peek() != '|' {
+			p.pos = save
+			break
+		}
+		p.pos++
+		p.skipSpaces()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	return OrNode{Nodes: nodes}, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	if p.eof() {
+		return nil, p.errorf("unexpected end of expression")
+	}
+	if r := p.peek(); r == '-' || r == '!' {
+		p.pos++
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return NotNode{Node: node}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	switch p.peek() {
+	case '(':
+		p.pos++
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if p.eof() || p.peek() != ')' {
+			return nil, p.errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return node, nil
+	case ')', '|':
+		return nil, p.errorf("unexpected %q", p.peek())
+	case '"':
+		return p.parsePhrase()
+	case '=':
+		p.pos++
+		term, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		term.Exact = true
+		return term, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *parser) parsePhrase() (Node, error) {
+	start := p.pos
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return nil, &SyntaxError{Offset: start, Message: "unterminated phrase"}
+		}
+		r := p.peek()
+		p.pos++
+		if r == '\\' && !p.eof() {
+			b.WriteRune(p.peek())
+			p.pos++
+			continue
+		}
+		if r == '"' {
+			break
+		}
+		b.WriteRune(r)
+	}
+
+	phrase := PhraseNode{Words: strings.Fields(b.String())}
+	if !p.eof() && p.peek() == '~' {
+		p.pos++
+		digits := p.pos
+		for !p.eof() && unicode.IsDigit(p.peek()) {
+			p.pos++
+		}
+		distance, err := strconv.Atoi(string(p.input[digits:p.pos]))
+		if err != nil {
+			return nil, p.errorf("proximity must be a number")
+		}
+		phrase.Proximity = distance
+	}
+	return phrase, nil
+}
+
+func (p *parser) parseTerm() (TermNode, error) {
+	start := p.pos
+	for !p.eof() {
+		r := p.peek()
+		if r == '\\' && p.pos+1 < len(p.input) {
+			p.pos += 2
+			continue
+		}
+		if unicode.IsSpace(r) || strings.ContainsRune(`()|"`, r) {
+			break
+		}
+		p.pos++
+	}
+	if p.pos == start {
+		return TermNode{}, p.errorf("expected a word")
+	}
+	return TermNode{Text: string(p.input[start:p.pos])}, nil
+}
@@ -0,0 +1,272 @@
+// Package query builds and parses TGStat extended syntax search expressions,
+// as accepted by the q parameter of posts/search, words and callback/subscribe-word
+// when extendedSyntax is enabled.
+//
+// Words separated by spaces must all match, "|" matches any of its operands,
+// "-" excludes, quotes match an exact phrase, "~N" after a phrase allows up to N words
+// between its words, "*" inside a word is a wildcard, "=" matches the exact word form.
+// As in TGStat, "|" binds tighter than the implicit AND.
+package query
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Node is an element of a search expression.
+type Node interface {
+	// String renders the node in extended syntax.
+	String() string
+	validate(top bool) error
+}
+
+// TermNode matches a single word.
+type TermNode struct {
+	Text string
+	// Exact disables morphology, the word is rendered as =word.
+	Exact bool
+}
+
+// PhraseNode matches words in order.
+type PhraseNode struct {
+	Words []string
+	// Proximity, when positive, allows up to that many words between the phrase words.
+	Proximity int
+}
+
+// AndNode matches when all of its nodes match.
+type AndNode struct {
+	Nodes []Node
+}
+
+// OrNode matches when any of its nodes match.
+type OrNode struct {
+	Nodes []Node
+}
+
+// NotNode excludes matches of its node.
+type NotNode struct {
+	Node Node
+}
+
+// Word matches a word, special characters are escaped.
+func Word(text string) TermNode {
+	return TermNode{Text: escape(text)}
+}
+
+// ExactWord matches a word in exactly this form.
+func ExactWord(text string) TermNode {
+	return TermNode{Text: escape(text), Exact: true}
+}
+
+// Prefix matches words starting with text.
+func Prefix(text string) TermNode {
+	return TermNode{Text: escape(text) + "*"}
+}
+
+// Wildcard matches words against a pattern where * stands for any characters.
+// Characters other than * are escaped.
+func Wildcard(pattern string) TermNode {
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = escape(part)
+	}
+	return TermNode{Text: strings.Join(parts, "*")}
+}
+
+// Phrase matches the words of text in order.
+func Phrase(text string) PhraseNode {
+	return PhraseNode{Words: strings.Fields(text)}
+}
+
+// Near matches the words of text with at most distance other words between them.
+func Near(distance int, text string) PhraseNode {
+	return PhraseNode{Words: strings.Fields(text), Proximity: distance}
+}
+
+// And matches when all nodes match.
+func And(nodes ...Node) AndNode {
+	return AndNode{Nodes: nodes}
+}
+
+// Or matches when any node matches.
+func Or(nodes ...Node) OrNode {
+	return OrNode{Nodes: nodes}
+}
+
+// Not excludes matches of node.
+func Not(node Node) NotNode {
+	return NotNode{Node: node}
+}
+
+// Build validates node and renders it in extended syntax.
+func Build(node Node) (string, error) {
+	if err := Validate(node); err != nil {
+		return "", err
+	}
+	return node.String(), nil
+}
+
+// Validate checks that node is a valid search expression.
+func Validate(node Node) error {
+	if node == nil {
+		return errors.New("query: empty expression")
+	}
+	return node.validate(true)
+}
+
+func (t TermNode) String() string {
+	if t.Exact {
+		return "=" + t.Text
+	}
+	return t.Text
+}
+
+func (t TermNode) validate(top bool) error {
+	if t.Text == "" {
+		return errors.New("query: empty word")
+	}
+	if strings.Trim(t.Text, "*") == "" {
+		return fmt.Errorf("query: wildcard %q must contain at least one character", t.Text)
+	}
+	if strings.ContainsAny(unescape(t.Text), " \t\n") {
+		return fmt.Errorf("query: word %q contains spaces, use a phrase", t.Text)
+	}
+	return nil
+}
+
+func (p PhraseNode) String() string {
+	words := make([]string, len(p.Words))
+	for i, word := range p.Words {
+		words[i] = escapePhrase(word)
+	}
+	s := `"` + strings.Join(words, " ") + `"`
+	if p.Proximity > 0 {
+		s += "~" + strconv.Itoa(p.Proximity)
+	}
+	return s
+}
+
+func (p PhraseNode) validate(top bool) error {
+	if len(p.Words) == 0 {
+		return errors.New("query: empty phrase")
+	}
+	if p.Proximity < 0 {
+		return fmt.Errorf("query: negative proximity %d", p.Proximity)
+	}
+	return nil
+}
+
+func (a AndNode) String() string {
+	parts := make([]string, len(a.Nodes))
+	for i, node := range a.Nodes {
+		parts[i] = node.String()
+	}
+	return strings.Join(parts, " ")
+}
+
+func (a AndNode) validate(top bool) error {
+	if len(a.Nodes) == 0 {
+		return errors.New("query: empty AND group")
+	}
+	positive := false
+	for _, node := range a.Nodes {
+		if node == nil {
+			return errors.New("query: empty expression")
+		}
+		if err := node.validate(false); err != nil {
+			return err
+		}
+		if _, ok := node.(NotNode); !ok {
+			positive = true
+		}
+	}
+	if !positive {
+		return errors.New("query: group must contain at least one word that is not excluded")
+	}
+	return nil
+}
+
+func (o OrNode) String() string {
+	parts := make([]string, len(o.Nodes))
+	for i, node := range o.Nodes {
+		if _, ok := node.(AndNode); ok {
+			parts[i] = "(" + node.String() + ")"
+			continue
+		}
+		parts[i] = node.String()
+	}
+	return strings.Join(parts, " | ")
+}
+
+func (o OrNode) validate(top bool) error {
+	if len(o.Nodes) == 0 {
+		return errors.New("query: empty OR group")
+	}
+	for _, node := range o.Nodes {
+		if node == nil {
+			return errors.New("query: empty expression")
+		}
+		if _, ok := node.(NotNode); ok {
+			return errors.New("query: excluded words can not be alternatives")
+		}
+		if err := node.validate(false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (n NotNode) String() string {
+	switch n.Node.(type) {
+	case TermNode, PhraseNode:
+		return "-" + n.Node.String()
+	}
+	return "-(" + n.Node.String() + ")"
+}
+
+func (n NotNode) validate(top bool) error {
+	if top {
+		return errors.New("query: expression can not consist of excluded words only")
+	}
+	if n.Node == nil {
+		return errors.New("query: empty expression")
+	}
+	if _, ok := n.Node.(NotNode); ok {
+		return errors.New("query: double negation")
+	}
+	return n.Node.validate(false)
+}
+
+const special = `\()|-!@~"&/^$=<*`
+
+func escape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if strings.ContainsRune(special, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func escapePhrase(word string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(word)
+}
+
+func unescape(text string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range text {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,97 @@
+package query_test
+
+import (
+	"errors"
+	"github.com/helios-ag/tgstat-go/query"
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test building a nested expression", func(t *testing.T) {
+		q, err := query.Build(query.And(
+			query.Or(query.Word("bitcoin"), query.Prefix("crypto"), query.And(query.Word("block"), query.Word("chain"))),
+			query.Near(3, "price growth"),
+			query.Not(query.Phrase("paid promotion")),
+			query.ExactWord("news"),
+		))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(q).To(Equal(`bitcoin | crypto* | (block chain) "price growth"~3 -"paid promotion" =news`))
+	})
+
+	t.Run("Test special characters are escaped", func(t *testing.T) {
+		q, err := query.Build(query.And(query.Word("c++|go"), query.Wildcard("tg-*stat"), query.Phrase(`say "hi"`)))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(q).To(Equal(`c++\|go tg\-*stat "say \"hi\""`))
+	})
+
+	t.Run("Test invalid expressions", func(t *testing.T) {
+		_, err := query.Build(query.Not(query.Word("spam")))
+		Expect(err).To(MatchError(ContainSubstring("excluded words only")))
+
+		_, err = query.Build(query.And(query.Not(query.Word("a")), query.Not(query.Word("b"))))
+		Expect(err).To(MatchError(ContainSubstring("not excluded")))
+
+		_, err = query.Build(query.Or(query.Word("a"), query.Not(query.Word("b"))))
+		Expect(err).To(MatchError(ContainSubstring("alternatives")))
+
+		_, err = query.Build(query.Wildcard("*"))
+		Expect(err).To(MatchError(ContainSubstring("at least one character")))
+
+		_, err = query.Build(query.Phrase(" "))
+		Expect(err).To(MatchError(ContainSubstring("empty phrase")))
+
+		_, err = query.Build(query.Or())
+		Expect(err).To(MatchError(ContainSubstring("empty OR group")))
+	})
+}
+
+func TestParse(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test OR binds tighter than AND", func(t *testing.T) {
+		node, err := query.Parse(`apple banana | cherry -"fruit salad"~2`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(node).To(Equal(query.AndNode{Nodes: []query.Node{
+			query.TermNode{Text: "apple"},
+			query.OrNode{Nodes: []query.Node{query.TermNode{Text: "banana"}, query.TermNode{Text: "cherry"}}},
+			query.NotNode{Node: query.PhraseNode{Words: []string{"fruit", "salad"}, Proximity: 2}},
+		}}))
+	})
+
+	t.Run("Test round trip", func(t *testing.T) {
+		for _, expression := range []string{
+			`bitcoin | crypto* | (block chain) "price growth"~3 -"paid promotion" =news`,
+			`c++\|go tg\-*stat "say \"hi\""`,
+			`a -(b | c)`,
+		} {
+			node, err := query.Parse(expression)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(node.String()).To(Equal(expression))
+		}
+	})
+
+	t.Run("Test syntax errors", func(t *testing.T) {
+		var syntaxErr *query.SyntaxError
+
+		_, err := query.Parse(`(a b`)
+		Expect(errors.As(err, &syntaxErr)).To(BeTrue())
+		Expect(syntaxErr.Message).To(Equal("missing closing parenthesis"))
+
+		_, err = query.Parse(`"open phrase`)
+		Expect(errors.As(err, &syntaxErr)).To(BeTrue())
+		Expect(syntaxErr.Offset).To(Equal(0))
+
+		_, err = query.Parse(`a | | b`)
+		Expect(err).To(HaveOccurred())
+
+		_, err = query.Parse(`a)`)
+		Expect(err).To(MatchError(ContainSubstring(`unexpected ')'`)))
+
+		_, err = query.Parse(`-spam`)
+		Expect(err).To(MatchError(ContainSubstring("excluded words only")))
+
+		_, err = query.Parse(`   `)
+		Expect(err).To(MatchError(ContainSubstring("empty expression")))
+	})
+}
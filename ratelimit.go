@@ -0,0 +1,20 @@
+package tgstat_go
+
+import (
+	"github.com/helios-ag/tgstat-go/internal/ratelimit"
+	"time"
+)
+
+func newRateLimiter(requests int, per time.Duration, burst int) *ratelimit.Limiter {
+	return ratelimit.New(per/time.Duration(requests), burst)
+}
+
+// WithRateLimit limits the shared client to the given number of requests per
+// interval, allowing bursts of up to burst requests.
+func WithRateLimit(requests int, per time.Duration, burst int) {
+	if requests <= 0 || per <= 0 {
+		TGStatClient.limiter = nil
+		return
+	}
+	TGStatClient.limiter = newRateLimiter(requests, per, burst)
+}
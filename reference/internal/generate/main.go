@@ -0,0 +1,59 @@
+// Command generate refreshes the reference snapshot from the TGStat database endpoints.
+// It reads the token from the TGSTAT_TOKEN environment variable.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/database"
+	"github.com/helios-ag/tgstat-go/reference"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	out := flag.String("out", "data", "directory the snapshot files are written to")
+	flag.Parse()
+
+	tgstat.Token = os.Getenv("TGSTAT_TOKEN")
+	if tgstat.Token == "" {
+		fmt.Fprintln(os.Stderr, "TGSTAT_TOKEN is not set")
+		os.Exit(1)
+	}
+
+	for _, lang := range reference.UILanguages {
+		if err := generate(context.Background(), lang, *out); err != nil {
+			fmt.Fprintf(os.Stderr, "error generating %s snapshot: %v\n", lang, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func generate(ctx context.Context, lang, out string) error {
+	countries, _, err := database.CountriesGet(ctx, lang)
+	if err != nil {
+		return err
+	}
+	categories, _, err := database.CategoriesGet(ctx, lang)
+	if err != nil {
+		return err
+	}
+	languages, _, err := database.LanguagesGet(ctx, lang)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(reference.Snapshot{
+		Countries:  countries.Response,
+		Categories: categories.Response,
+		Languages:  languages.Response,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(out, lang+".json"), append(data, '\n'), 0o644)
+}
@@ -0,0 +1,214 @@
+// Package reference is an offline snapshot of the TGStat database endpoints
+// (countries, categories and languages), so codes can be looked up and validated
+// without a token or a network call.
+//
+// The snapshot is embedded from the data directory and regenerated with
+//
+//	TGSTAT_TOKEN=yourtoken go generate ./reference
+//
+// The files checked in are a partial seed of the most common codes until they
+// are regenerated, a code missing from them is not necessarily rejected by the API.
+package reference
+
+//go:generate go run ./internal/generate -out data
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Languages the snapshot is available in.
+const (
+	LangRussian = "ru"
+	LangEnglish = "en"
+)
+
+// UILanguages lists the languages of the snapshot, the first one is the default.
+var UILanguages = []string{LangRussian, LangEnglish}
+
+// Snapshot is the reference data in one UI language.
+type Snapshot struct {
+	Countries  []tgstat.Country  `json:"countries"`
+	Categories []tgstat.Category `json:"categories"`
+	Languages  []tgstat.Language `json:"languages"`
+}
+
+//go:embed data/*.json
+var data embed.FS
+
+var (
+	loadOnce  sync.Once
+	snapshots map[string]Snapshot
+	loadErr   error
+)
+
+func load() (map[string]Snapshot, error) {
+	loadOnce.Do(func() {
+		snapshots = make(map[string]Snapshot)
+		for _, lang := range UILanguages {
+			raw, err := data.ReadFile(path.Join("data", lang+".json"))
+			if err != nil {
+				loadErr = err
+				return
+			}
+			var snapshot Snapshot
+			if err := json.Unmarshal(raw, &snapshot); err != nil {
+				loadErr = fmt.Errorf("reference: %s snapshot: %w", lang, err)
+				return
+			}
+			snapshots[lang] = snapshot
+		}
+	})
+	return snapshots, loadErr
+}
+
+// Get returns the snapshot in the given UI language.
+func Get(lang string) (Snapshot, error) {
+	all, err := load()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snapshot, ok := all[lang]
+	if !ok {
+		return Snapshot{}, fmt.Errorf("reference: language %q is not available", lang)
+	}
+	return snapshot, nil
+}
+
+// Countries returns the countries with names in the given UI language.
+func Countries(lang string) []tgstat.Country {
+	snapshot, _ := Get(lang)
+	return snapshot.Countries
+}
+
+// Categories returns the categories with names in the given UI language.
+func Categories(lang string) []tgstat.Category {
+	snapshot, _ := Get(lang)
+	return snapshot.Categories
+}
+
+// Languages returns the languages with names in the given UI language.
+func Languages(lang string) []tgstat.Language {
+	snapshot, _ := Get(lang)
+	return snapshot.Languages
+}
+
+// CountryByCode finds a country by code, its name is in the given UI language.
+func CountryByCode(code, lang string) (tgstat.Country, bool) {
+	for _, country := range Countries(lang) {
+		if strings.EqualFold(country.Code, code) {
+			return country, true
+		}
+	}
+	return tgstat.Country{}, false
+}
+
+// CategoryByCode finds a category by code, its name is in the given UI language.
+func CategoryByCode(code, lang string) (tgstat.Category, bool) {
+	for _, category := range Categories(lang) {
+		if strings.EqualFold(category.Code, code) {
+			return category, true
+		}
+	}
+	return tgstat.Category{}, false
+}
+
+// LanguageByCode finds a language by code, its name is in the given UI language.
+func LanguageByCode(code, lang string) (tgstat.Language, bool) {
+	for _, language := range Languages(lang) {
+		if strings.EqualFold(language.Code, code) {
+			return language, true
+		}
+	}
+	return tgstat.Language{}, false
+}
+
+// CountryByName finds a country by its name in any UI language, ignoring case.
+func CountryByName(name string) (tgstat.Country, bool) {
+	code, ok := byName(name, func(s Snapshot) []item {
+		return items(s.Countries, func(c tgstat.Country) item { return item(c) })
+	})
+	if !ok {
+		return tgstat.Country{}, false
+	}
+	return CountryByCode(code, UILanguages[0])
+}
+
+// CategoryByName finds a category by its name in any UI language, ignoring case.
+func CategoryByName(name string) (tgstat.Category, bool) {
+	code, ok := byName(name, func(s Snapshot) []item {
+		return items(s.Categories, func(c tgstat.Category) item { return item(c) })
+	})
+	if !ok {
+		return tgstat.Category{}, false
+	}
+	return CategoryByCode(code, UILanguages[0])
+}
+
+// LanguageByName finds a language by its name in any UI language, ignoring case.
+func LanguageByName(name string) (tgstat.Language, bool) {
+	code, ok := byName(name, func(s Snapshot) []item {
+		return items(s.Languages, func(l tgstat.Language) item { return item(l) })
+	})
+	if !ok {
+		return tgstat.Language{}, false
+	}
+	return LanguageByCode(code, UILanguages[0])
+}
+
+// ValidateCountry returns an error when code is not a known country code.
+func ValidateCountry(code string) error {
+	if _, ok := CountryByCode(code, UILanguages[0]); !ok {
+		return fmt.Errorf("unknown country code %q", code)
+	}
+	return nil
+}
+
+// ValidateCategory returns an error when code is not a known category code.
+func ValidateCategory(code string) error {
+	if _, ok := CategoryByCode(code, UILanguages[0]); !ok {
+		return fmt.Errorf("unknown category code %q", code)
+	}
+	return nil
+}
+
+// ValidateLanguage returns an error when code is not a known language code.
+func ValidateLanguage(code string) error {
+	if _, ok := LanguageByCode(code, UILanguages[0]); !ok {
+		return fmt.Errorf("unknown language code %q", code)
+	}
+	return nil
+}
+
+type item struct {
+	Code string
+	Name string
+}
+
+func items[T any](values []T, convert func(T) item) []item {
+	result := make([]item, len(values))
+	for i, value := range values {
+		result[i] = convert(value)
+	}
+	return result
+}
+
+func byName(name string, list func(Snapshot) []item) (string, bool) {
+	all, err := load()
+	if err != nil {
+		return "", false
+	}
+	for _, lang := range UILanguages {
+		for _, it := range list(all[lang]) {
+			if strings.EqualFold(it.Name, strings.TrimSpace(name)) {
+				return it.Code, true
+			}
+		}
+	}
+	return "", false
+}
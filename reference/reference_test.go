@@ -0,0 +1,51 @@
+package reference
+
+import (
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test every UI language is embedded", func(t *testing.T) {
+		for _, lang := range UILanguages {
+			snapshot, err := Get(lang)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(snapshot.Countries).ToNot(BeEmpty())
+			Expect(snapshot.Categories).ToNot(BeEmpty())
+			Expect(snapshot.Languages).ToNot(BeEmpty())
+		}
+
+		_, err := Get("xx")
+		Expect(err).To(MatchError(ContainSubstring(`language "xx" is not available`)))
+	})
+
+	t.Run("Test lookup by code", func(t *testing.T) {
+		country, ok := CountryByCode("RU", LangEnglish)
+		Expect(ok).To(BeTrue())
+		Expect(country.Name).To(Equal("Russia"))
+
+		category, ok := CategoryByCode("tech", LangRussian)
+		Expect(ok).To(BeTrue())
+		Expect(category.Name).To(Equal("Технологии"))
+
+		_, ok = LanguageByCode("klingon", LangEnglish)
+		Expect(ok).To(BeFalse())
+	})
+
+	t.Run("Test lookup by name in any UI language", func(t *testing.T) {
+		country, ok := CountryByName("ukraine")
+		Expect(ok).To(BeTrue())
+		Expect(country.Code).To(Equal("ua"))
+
+		language, ok := LanguageByName("Английский")
+		Expect(ok).To(BeTrue())
+		Expect(language.Code).To(Equal("english"))
+	})
+
+	t.Run("Test validation", func(t *testing.T) {
+		Expect(ValidateCountry("by")).To(Succeed())
+		Expect(ValidateCategory("crypto")).To(Succeed())
+		Expect(ValidateLanguage("nope")).To(MatchError(`unknown language code "nope"`))
+	})
+}
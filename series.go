@@ -0,0 +1,383 @@
+package tgstat_go
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SeriesPoint is a value of a Series over the period [Start, End).
+type SeriesPoint struct {
+	Start time.Time
+	End   time.Time
+	Value float64
+	// Missing marks points added by FillGaps.
+	Missing bool
+}
+
+// Series is a time series built from the period based responses of the API.
+// Periods are interpreted in UTC.
+type Series struct {
+	Group  Group
+	Points []SeriesPoint
+}
+
+// Fill is a strategy for values of missing periods.
+type Fill int
+
+const (
+	// FillZero sets missing values to zero.
+	FillZero Fill = iota
+	// FillPrevious repeats the previous known value.
+	FillPrevious
+	// FillLinear interpolates between the surrounding known values.
+	FillLinear
+)
+
+// Aggregation combines values when resampling.
+type Aggregation int
+
+const (
+	AggregateSum Aggregation = iota
+	AggregateAvg
+	AggregateLast
+	AggregateMin
+	AggregateMax
+)
+
+var periodLayouts = []struct {
+	layout string
+	group  Group
+}{
+	{"2006-01-02 15:04:05", GroupHour},
+	{"2006-01-02 15:04", GroupHour},
+	{"2006-01-02T15:04:05Z07:00", GroupHour},
+	{"2006-01-02", GroupDay},
+	{"02.01.2006", GroupDay},
+	{"2006-01", GroupMonth},
+	{"01.2006", GroupMonth},
+}
+
+// ParsePeriod parses a period string of the API into the range it covers.
+// When group is empty it is detected from the format of the period.
+func ParsePeriod(period string, group Group) (start, end time.Time, err error) {
+	period = strings.TrimSpace(period)
+
+	if from, to, ok := strings.Cut(period, " - "); ok {
+		start, _, err = ParsePeriod(from, GroupDay)
+		if err != nil {
+			return start, end, err
+		}
+		_, end, err = ParsePeriod(to, GroupDay)
+		return start, end, err
+	}
+
+	detected := Group("")
+	for _, l := range periodLayouts {
+		if t, parseErr := time.Parse(l.layout, period); parseErr == nil {
+			start, detected = t.UTC(), l.group
+			break
+		}
+	}
+	if detected == "" {
+		return start, end, fmt.Errorf("period: unknown format %q", period)
+	}
+
+	if group == "" {
+		group = detected
+	}
+	start = truncate(start, group)
+	return start, advance(start, group), nil
+}
+
+// NewSeries builds a series from parallel period and value slices, sorted by period.
+func NewSeries(group Group, periods []string, values []float64) (Series, error) {
+	if len(periods) != len(values) {
+		return Series{}, fmt.Errorf("series: %d periods for %d values", len(periods), len(values))
+	}
+	series := Series{Group: group, Points: make([]SeriesPoint, 0, len(periods))}
+	for i, period := range periods {
+		start, end, err := ParsePeriod(period, group)
+		if err != nil {
+			return Series{}, err
+		}
+		if series.Group == "" {
+			series.Group = detectGroup(start, end)
+		}
+		series.Points = append(series.Points, SeriesPoint{Start: start, End: end, Value: values[i]})
+	}
+	return series.Sorted(), nil
+}
+
+// Sorted returns a copy of the series ordered by period.
+func (s Series) Sorted() Series {
+	points := append([]SeriesPoint(nil), s.Points...)
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Start.Before(points[j].Start)
+	})
+	return Series{Group: s.Group, Points: points}
+}
+
+// Values returns the values of the series.
+func (s Series) Values() []float64 {
+	values := make([]float64, len(s.Points))
+	for i, p := range s.Points {
+		values[i] = p.Value
+	}
+	return values
+}
+
+// FillGaps returns a sorted copy of the series with a point for every period
+// between the first and the last one, added points are marked Missing.
+func (s Series) FillGaps(fill Fill) Series {
+	sorted := s.Sorted()
+	if len(sorted.Points) < 2 || sorted.Group == "" {
+		return sorted
+	}
+
+	filled := Series{Group: s.Group}
+	for i, p := range sorted.Points {
+		if i > 0 {
+			prev := sorted.Points[i-1]
+			var gap []SeriesPoint
+			for t := advance(prev.Start, s.Group); t.Before(p.Start); t = advance(t, s.Group) {
+				gap = append(gap, SeriesPoint{Start: t, End: advance(t, s.Group), Missing: true})
+			}
+			for j := range gap {
+				switch fill {
+				case FillPrevious:
+					gap[j].Value = prev.Value
+				case FillLinear:
+					gap[j].Value = prev.Value + (p.Value-prev.Value)*float64(j+1)/float64(len(gap)+1)
+				}
+			}
+			filled.Points = append(filled.Points, gap...)
+		}
+		filled.Points = append(filled.Points, p)
+	}
+	return filled
+}
+
+// Resample aggregates the series into coarser periods, e.g. days into weeks.
+// Missing points are ignored.
+func (s Series) Resample(group Group, aggregation Aggregation) (Series, error) {
+	if groupOrder(group) < groupOrder(s.Group) {
+		return Series{}, fmt.Errorf("series: can not resample %s into %s", s.Group, group)
+	}
+
+	resampled := Series{Group: group}
+	var bucket []float64
+	flush := func() {
+		if len(bucket) > 0 {
+			resampled.Points[len(resampled.Points)-1].Value = aggregate(bucket, aggregation)
+		}
+		bucket = nil
+	}
+
+	for _, p := range s.Sorted().Points {
+		start := truncate(p.Start, group)
+		if n := len(resampled.Points); n == 0 || !resampled.Points[n-1].Start.Equal(start) {
+			flush()
+			resampled.Points = append(resampled.Points, SeriesPoint{Start: start, End: advance(start, group), Missing: true})
+		}
+		if !p.Missing {
+			bucket = append(bucket, p.Value)
+			resampled.Points[len(resampled.Points)-1].Missing = false
+		}
+	}
+	flush()
+
+	return resampled, nil
+}
+
+// Deltas returns the change of every point against the previous one,
+// the series starts from its second point.
+func (s Series) Deltas() Series {
+	deltas := Series{Group: s.Group}
+	for i := 1; i < len(s.Points); i++ {
+		p := s.Points[i]
+		p.Value -= s.Points[i-1].Value
+		deltas.Points = append(deltas.Points, p)
+	}
+	return deltas
+}
+
+// Growth returns the percentage change of every point against the previous one,
+// the series starts from its second point. Points following a zero value are
+// left out, growth from zero is undefined.
+func (s Series) Growth() Series {
+	growth := Series{Group: s.Group}
+	for i := 1; i < len(s.Points); i++ {
+		p := s.Points[i]
+		var ok bool
+		if p.Value, ok = percentChange(s.Points[i-1].Value, p.Value); ok {
+			growth.Points = append(growth.Points, p)
+		}
+	}
+	return growth
+}
+
+// TotalDelta returns the difference between the last and the first value.
+func (s Series) TotalDelta() float64 {
+	if len(s.Points) == 0 {
+		return 0
+	}
+	return s.Points[len(s.Points)-1].Value - s.Points[0].Value
+}
+
+// TotalGrowth returns the percentage change between the first and the last value,
+// false when the series is empty or starts from zero.
+func (s Series) TotalGrowth() (float64, bool) {
+	if len(s.Points) == 0 {
+		return 0, false
+	}
+	return percentChange(s.Points[0].Value, s.Points[len(s.Points)-1].Value)
+}
+
+func percentChange(from, to float64) (float64, bool) {
+	if from == 0 {
+		return 0, false
+	}
+	return (to - from) / from * 100, true
+}
+
+func aggregate(values []float64, aggregation Aggregation) float64 {
+	result := values[0]
+	switch aggregation {
+	case AggregateLast:
+		return values[len(values)-1]
+	case AggregateMin:
+		for _, v := range values {
+			result = math.Min(result, v)
+		}
+		return result
+	case AggregateMax:
+		for _, v := range values {
+			result = math.Max(result, v)
+		}
+		return result
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	if aggregation == AggregateAvg {
+		return sum / float64(len(values))
+	}
+	return sum
+}
+
+func groupOrder(group Group) int {
+	switch group {
+	case GroupHour:
+		return 0
+	case GroupDay:
+		return 1
+	case GroupWeek:
+		return 2
+	case GroupMonth:
+		return 3
+	}
+	return -1
+}
+
+func detectGroup(start, end time.Time) Group {
+	switch d := end.Sub(start); {
+	case d <= time.Hour:
+		return GroupHour
+	case d <= 24*time.Hour:
+		return GroupDay
+	case d <= 7*24*time.Hour:
+		return GroupWeek
+	}
+	return GroupMonth
+}
+
+// truncate returns the start of the period containing t, weeks start on Monday.
+func truncate(t time.Time, group Group) time.Time {
+	switch group {
+	case GroupHour:
+		return t.Truncate(time.Hour)
+	case GroupWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case GroupMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func advance(t time.Time, group Group) time.Time {
+	switch group {
+	case GroupHour:
+		return t.Add(time.Hour)
+	case GroupWeek:
+		return t.AddDate(0, 0, 7)
+	case GroupMonth:
+		return t.AddDate(0, 1, 0)
+	}
+	return t.AddDate(0, 0, 1)
+}
+
+// Series returns the subscribers count series, group may be empty to detect it from the periods.
+func (c ChannelSubscribers) Series(group Group) (Series, error) {
+	periods := make([]string, len(c.Response))
+	values := make([]float64, len(c.Response))
+	for i, item := range c.Response {
+		periods[i], values[i] = item.Period, float64(item.ParticipantsCount)
+	}
+	return NewSeries(group, periods, values)
+}
+
+// Series returns the views series, group may be empty to detect it from the periods.
+func (c ChannelViews) Series(group Group) (Series, error) {
+	periods := make([]string, len(c.Response))
+	values := make([]float64, len(c.Response))
+	for i, item := range c.Response {
+		periods[i], values[i] = item.Period, item.ViewsCount
+	}
+	return NewSeries(group, periods, values)
+}
+
+// Series returns the average post reach series, group may be empty to detect it from the periods.
+func (c ChannelAvgReach) Series(group Group) (Series, error) {
+	periods := make([]string, len(c.Response))
+	values := make([]float64, len(c.Response))
+	for i, item := range c.Response {
+		periods[i], values[i] = item.Period, item.AvgPostsReach
+	}
+	return NewSeries(group, periods, values)
+}
+
+// Series returns the ERR series, group may be empty to detect it from the periods.
+func (c ChannelErr) Series(group Group) (Series, error) {
+	periods := make([]string, len(c.Response))
+	values := make([]float64, len(c.Response))
+	for i, item := range c.Response {
+		periods[i], values[i] = item.Period, item.Err
+	}
+	return NewSeries(group, periods, values)
+}
+
+// MentionsSeries returns the mentions count series, group may be empty to detect it from the periods.
+func (w WordsMentions) MentionsSeries(group Group) (Series, error) {
+	periods := make([]string, len(w.Response.Items))
+	values := make([]float64, len(w.Response.Items))
+	for i, item := range w.Response.Items {
+		periods[i], values[i] = item.Period, float64(item.MentionsCount)
+	}
+	return NewSeries(group, periods, values)
+}
+
+// ViewsSeries returns the views count series, group may be empty to detect it from the periods.
+func (w WordsMentions) ViewsSeries(group Group) (Series, error) {
+	periods := make([]string, len(w.Response.Items))
+	values := make([]float64, len(w.Response.Items))
+	for i, item := range w.Response.Items {
+		periods[i], values[i] = item.Period, float64(item.ViewsCount)
+	}
+	return NewSeries(group, periods, values)
+}
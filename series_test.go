@@ -0,0 +1,122 @@
+package tgstat_go
+
+import (
+	. "github.com/onsi/gomega"
+	"testing"
+	"time"
+)
+
+func day(d int) time.Time {
+	return time.Date(2024, time.January, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParsePeriod(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test period formats", func(t *testing.T) {
+		start, end, err := ParsePeriod("2024-01-03 15:00", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(start).To(Equal(time.Date(2024, time.January, 3, 15, 0, 0, 0, time.UTC)))
+		Expect(end.Sub(start)).To(Equal(time.Hour))
+
+		start, end, err = ParsePeriod("2024-01-03", GroupWeek)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(start).To(Equal(day(1)))
+		Expect(end).To(Equal(day(8)))
+
+		start, end, err = ParsePeriod("2024-01-01 - 2024-01-07", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(start).To(Equal(day(1)))
+		Expect(end).To(Equal(day(8)))
+
+		start, end, err = ParsePeriod("2024-02", "")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(start).To(Equal(time.Date(2024, time.February, 1, 0, 0, 0, 0, time.UTC)))
+		Expect(end).To(Equal(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)))
+
+		_, _, err = ParsePeriod("yesterday", "")
+		Expect(err).To(MatchError(ContainSubstring("unknown format")))
+	})
+}
+
+func TestSeries(t *testing.T) {
+	RegisterTestingT(t)
+	subscribers := ChannelSubscribers{Response: []ChannelSubscribersResponse{
+		{Period: "2024-01-04", ParticipantsCount: 130},
+		{Period: "2024-01-01", ParticipantsCount: 100},
+		{Period: "2024-01-02", ParticipantsCount: 110},
+		{Period: "2024-01-09", ParticipantsCount: 150},
+	}}
+
+	t.Run("Test series is sorted and group detected", func(t *testing.T) {
+		series, err := subscribers.Series("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(series.Group).To(Equal(GroupDay))
+		Expect(series.Values()).To(Equal([]float64{100, 110, 130, 150}))
+		Expect(series.TotalDelta()).To(Equal(50.0))
+		growth, ok := series.TotalGrowth()
+		Expect(ok).To(BeTrue())
+		Expect(growth).To(Equal(50.0))
+	})
+
+	t.Run("Test gaps are filled explicitly", func(t *testing.T) {
+		series, _ := subscribers.Series(GroupDay)
+
+		filled := series.FillGaps(FillLinear)
+		Expect(filled.Points).To(HaveLen(9))
+		Expect(filled.Points[2].Start).To(Equal(day(3)))
+		Expect(filled.Points[2].Missing).To(BeTrue())
+		Expect(filled.Points[2].Value).To(Equal(120.0))
+
+		Expect(series.FillGaps(FillPrevious).Points[5].Value).To(Equal(130.0))
+		Expect(series.FillGaps(FillZero).Points[5].Value).To(Equal(0.0))
+	})
+
+	t.Run("Test resampling days into weeks", func(t *testing.T) {
+		series, _ := subscribers.Series(GroupDay)
+
+		weekly, err := series.FillGaps(FillZero).Resample(GroupWeek, AggregateLast)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(weekly.Points).To(HaveLen(2))
+		Expect(weekly.Points[0].Start).To(Equal(day(1)))
+		Expect(weekly.Points[1].Start).To(Equal(day(8)))
+		Expect(weekly.Values()).To(Equal([]float64{130, 150}))
+
+		summed, _ := series.Resample(GroupWeek, AggregateSum)
+		Expect(summed.Values()).To(Equal([]float64{340, 150}))
+
+		averaged, _ := series.Resample(GroupWeek, AggregateAvg)
+		Expect(averaged.Points[0].Value).To(BeNumerically("~", 113.33, 0.01))
+
+		_, err = weekly.Resample(GroupDay, AggregateSum)
+		Expect(err).To(MatchError(ContainSubstring("can not resample")))
+	})
+
+	t.Run("Test deltas and growth", func(t *testing.T) {
+		series, _ := NewSeries(GroupDay, []string{"2024-01-01", "2024-01-02", "2024-01-03"}, []float64{0, 50, 75})
+		Expect(series.Deltas().Values()).To(Equal([]float64{50, 25}))
+
+		growth := series.Growth()
+		Expect(growth.Values()).To(Equal([]float64{50}))
+		Expect(growth.Points[0].Start).To(Equal(series.Points[2].Start))
+
+		_, ok := series.TotalGrowth()
+		Expect(ok).To(BeFalse())
+		_, ok = Series{}.TotalGrowth()
+		Expect(ok).To(BeFalse())
+	})
+
+	t.Run("Test words mentions series", func(t *testing.T) {
+		mentions := WordsMentions{Response: WordsMentionsResponse{Items: []WordsMentionsResponseItem{
+			{Period: "2024-02", MentionsCount: 4, ViewsCount: 40},
+			{Period: "2024-01", MentionsCount: 2, ViewsCount: 10},
+		}}}
+		series, err := mentions.MentionsSeries("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(series.Group).To(Equal(GroupMonth))
+		Expect(series.Values()).To(Equal([]float64{2, 4}))
+
+		views, _ := mentions.ViewsSeries(GroupMonth)
+		growth, _ := views.TotalGrowth()
+		Expect(growth).To(Equal(300.0))
+	})
+}
@@ -0,0 +1,75 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/helios-ag/tgstat-go/callback"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// ExecConfig configures an Exec sink.
+type ExecConfig struct {
+	Command string
+	Args    []string
+	// Env is added to the environment of the process.
+	Env []string
+	Dir string
+	// Timeout of a run, defaults to DefaultTimeout.
+	Timeout time.Duration
+	Options
+}
+
+// Exec runs a command for every batch, piping the events to its standard
+// input as JSON lines. A non-zero exit status is a failure.
+type Exec struct {
+	config ExecConfig
+	policy policy
+}
+
+// NewExec returns an exec sink.
+func NewExec(config ExecConfig) (*Exec, error) {
+	if config.Command == "" {
+		return nil, errors.New("sinks: exec command is required")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultTimeout
+	}
+	return &Exec{config: config, policy: newPolicy(config.Options)}, nil
+}
+
+// Send runs the command with the events.
+func (e *Exec) Send(ctx context.Context, events []callback.Event) error {
+	var input bytes.Buffer
+	encoder := json.NewEncoder(&input)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("sinks: exec: %w", err)
+		}
+	}
+
+	return e.policy.run(ctx, func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, e.config.Command, e.config.Args...)
+		cmd.Dir = e.config.Dir
+		cmd.Env = append(os.Environ(), e.config.Env...)
+		cmd.Stdin = bytes.NewReader(input.Bytes())
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+
+		if err := cmd.Run(); err != nil {
+			if message := strings.TrimSpace(stderr.String()); message != "" {
+				return fmt.Errorf("sinks: exec %s: %w: %s", e.config.Command, err, truncate(message, 512))
+			}
+			return fmt.Errorf("sinks: exec %s: %w", e.config.Command, err)
+		}
+		return nil
+	})
+}
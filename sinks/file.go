@@ -0,0 +1,125 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/helios-ag/tgstat-go/callback"
+	"os"
+	"sync"
+)
+
+// FileConfig configures a File sink.
+type FileConfig struct {
+	Path string
+	// MaxSize rotates the file before it grows past that many bytes, zero never rotates.
+	MaxSize int64
+	// MaxBackups is the number of rotated files kept as Path.1 (newest) to Path.N.
+	// It defaults to 1 when MaxSize is set.
+	MaxBackups int
+	Options
+}
+
+// File appends events to a file, one JSON object per line.
+type File struct {
+	config FileConfig
+	policy policy
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewFile opens or creates the file.
+func NewFile(config FileConfig) (*File, error) {
+	if config.Path == "" {
+		return nil, errors.New("sinks: file path is required")
+	}
+	if config.MaxSize > 0 && config.MaxBackups < 1 {
+		config.MaxBackups = 1
+	}
+	f := &File{config: config, policy: newPolicy(config.Options)}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Send writes the events, a batch is never split across files.
+func (f *File) Send(ctx context.Context, events []callback.Event) error {
+	var lines bytes.Buffer
+	encoder := json.NewEncoder(&lines)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return fmt.Errorf("sinks: file: %w", err)
+		}
+	}
+
+	return f.policy.run(ctx, func(context.Context) error {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.file == nil {
+			if err := f.open(); err != nil {
+				return err
+			}
+		}
+		if f.config.MaxSize > 0 && f.size > 0 && f.size+int64(lines.Len()) > f.config.MaxSize {
+			if err := f.rotate(); err != nil {
+				return err
+			}
+		}
+		n, err := f.file.Write(lines.Bytes())
+		f.size += int64(n)
+		if err != nil {
+			return fmt.Errorf("sinks: file: %w", err)
+		}
+		return nil
+	})
+}
+
+// Close closes the file.
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *File) open() error {
+	file, err := os.OpenFile(f.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("sinks: file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close() //nolint
+		return fmt.Errorf("sinks: file: %w", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// rotate shifts Path.N-1 to Path.N down to Path to Path.1 and reopens Path.
+func (f *File) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("sinks: file: %w", err)
+	}
+	f.file = nil
+	for i := f.config.MaxBackups - 1; i >= 0; i-- {
+		from := f.config.Path
+		if i > 0 {
+			from = fmt.Sprintf("%s.%d", f.config.Path, i)
+		}
+		err := os.Rename(from, fmt.Sprintf("%s.%d", f.config.Path, i+1))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("sinks: file: %w", err)
+		}
+	}
+	return f.open()
+}
@@ -0,0 +1,140 @@
+// Package sinks delivers events to external systems: HTTP webhooks, rotating
+// NDJSON files, Telegram chats and external commands.
+//
+// Every sink retries failed sends and can be rate limited through Options.
+// Sinks satisfy alert.Sink.
+package sinks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/helios-ag/tgstat-go/callback"
+	"github.com/helios-ag/tgstat-go/internal/ratelimit"
+	"text/template"
+	"time"
+)
+
+// DefaultBackoff is the first wait between two attempts.
+const DefaultBackoff = time.Second
+
+// Sink sends a batch of events, a single event or a digest.
+type Sink interface {
+	Send(ctx context.Context, events []callback.Event) error
+}
+
+// Options are the delivery policy shared by every sink.
+type Options struct {
+	// Retries is the number of attempts made after a failed one.
+	Retries int
+	// Backoff is the wait before the first retry, doubled for the next ones.
+	// It defaults to DefaultBackoff.
+	Backoff time.Duration
+	// Rate is the minimum interval between two sends, allowing bursts of
+	// Burst sends. Zero disables rate limiting.
+	Rate  time.Duration
+	Burst int
+}
+
+// permanentError is not retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, e.g. a rejected request.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err}
+}
+
+// retryAfterError asks to wait at least after before the next attempt.
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string { return e.err.Error() }
+func (e *retryAfterError) Unwrap() error { return e.err }
+
+// policy applies Options around the sends of a sink.
+type policy struct {
+	options Options
+	limiter *ratelimit.Limiter
+}
+
+func newPolicy(options Options) policy {
+	if options.Backoff <= 0 {
+		options.Backoff = DefaultBackoff
+	}
+	p := policy{options: options}
+	if options.Rate > 0 {
+		p.limiter = ratelimit.New(options.Rate, options.Burst)
+	}
+	return p
+}
+
+// run calls send until it succeeds, fails permanently or the retries are exhausted.
+func (p policy) run(ctx context.Context, send func(context.Context) error) error {
+	wait := p.options.Backoff
+	for attempt := 0; ; attempt++ {
+		if p.limiter != nil {
+			if err := p.limiter.Wait(ctx); err != nil {
+				return err
+			}
+		}
+		err := send(ctx)
+		var permanent *permanentError
+		if err == nil || attempt >= p.options.Retries || errors.As(err, &permanent) {
+			return err
+		}
+
+		delay := wait
+		var retryAfter *retryAfterError
+		if errors.As(err, &retryAfter) && retryAfter.after > delay {
+			delay = retryAfter.after
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		wait *= 2
+	}
+}
+
+// templateData is passed to the templates of the sinks.
+type templateData struct {
+	Events []callback.Event
+	// Event is the first event, handy for sinks receiving events one by one.
+	Event callback.Event
+}
+
+func newTemplateData(events []callback.Event) templateData {
+	data := templateData{Events: events}
+	if len(events) != 0 {
+		data.Event = events[0]
+	}
+	return data
+}
+
+var templateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+func parseTemplate(name, text string, funcs ...template.FuncMap) (*template.Template, error) {
+	t := template.New(name).Funcs(templateFuncs)
+	for _, f := range funcs {
+		t = t.Funcs(f)
+	}
+	return t.Option("missingkey=error").Parse(text)
+}
@@ -0,0 +1,344 @@
+package sinks
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	tgstat "github.com/helios-ag/tgstat-go"
+	"github.com/helios-ag/tgstat-go/alert"
+	"github.com/helios-ag/tgstat-go/callback"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	_ alert.Sink = (*Webhook)(nil)
+	_ alert.Sink = (*File)(nil)
+	_ alert.Sink = (*Telegram)(nil)
+	_ alert.Sink = (*Exec)(nil)
+)
+
+var events = []callback.Event{
+	{EventID: 1, EventType: tgstat.EventNewPost, Post: tgstat.Post{ID: 10, Link: "https://t.me/a/1", Text: "first"}},
+	{EventID: 2, EventType: tgstat.EventNewPost, Post: tgstat.Post{ID: 11, Link: "https://t.me/a/2", Text: "second"}},
+}
+
+func TestWebhook(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test default body and headers", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		var body map[string][]callback.Event
+		var auth string
+		testServer.Mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+			auth = r.Header.Get("Authorization")
+			_ = json.NewDecoder(r.Body).Decode(&body)
+		})
+
+		sink, err := NewWebhook(WebhookConfig{URL: testServer.URL + "/hook", Headers: map[string]string{"Authorization": "Bearer x"}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sink.Send(context.Background(), events)).To(Succeed())
+		Expect(auth).To(Equal("Bearer x"))
+		Expect(body["events"]).To(Equal(events))
+	})
+
+	t.Run("Test templated body", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		var body string
+		testServer.Mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {
+			data, _ := io.ReadAll(r.Body)
+			body = string(data)
+		})
+
+		sink, err := NewWebhook(WebhookConfig{URL: testServer.URL + "/hook", Body: `{"text": {{json .Event.Post.Text}}, "count": {{len .Events}}}`})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sink.Send(context.Background(), events)).To(Succeed())
+		Expect(body).To(Equal(`{"text": "first", "count": 2}`))
+
+		invalid, err := NewWebhook(WebhookConfig{URL: testServer.URL + "/hook", Body: `{"text": {{.Event.Post.Text}}}`})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(invalid.Send(context.Background(), events)).To(MatchError("sinks: webhook body is not valid JSON"))
+	})
+
+	t.Run("Test retries server errors, not client errors", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		var calls int32
+		testServer.Mux.HandleFunc("/flaky", func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+		})
+		testServer.Mux.HandleFunc("/reject", func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			http.Error(w, "bad payload", http.StatusBadRequest)
+		})
+
+		options := Options{Retries: 3, Backoff: time.Millisecond}
+		flaky, _ := NewWebhook(WebhookConfig{URL: testServer.URL + "/flaky", Options: options})
+		Expect(flaky.Send(context.Background(), events)).To(Succeed())
+		Expect(atomic.LoadInt32(&calls)).To(Equal(int32(3)))
+
+		atomic.StoreInt32(&calls, 0)
+		reject, _ := NewWebhook(WebhookConfig{URL: testServer.URL + "/reject", Options: options})
+		err := reject.Send(context.Background(), events)
+		Expect(err).To(MatchError(ContainSubstring("400 Bad Request: bad payload")))
+		Expect(atomic.LoadInt32(&calls)).To(Equal(int32(1)))
+	})
+
+	t.Run("Test rate limit", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		testServer.Mux.HandleFunc("/hook", func(w http.ResponseWriter, r *http.Request) {})
+
+		sink, _ := NewWebhook(WebhookConfig{URL: testServer.URL + "/hook", Options: Options{Rate: 50 * time.Millisecond}})
+		started := time.Now()
+		for i := 0; i < 3; i++ {
+			Expect(sink.Send(context.Background(), events)).To(Succeed())
+		}
+		Expect(time.Since(started)).To(BeNumerically(">=", 100*time.Millisecond))
+	})
+}
+
+func TestTelegram(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test send message", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		var message telegramMessage
+		testServer.Mux.HandleFunc("/bot123:abc/sendMessage", func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewDecoder(r.Body).Decode(&message)
+			_, _ = w.Write([]byte(`{"ok":true,"result":{}}`))
+		})
+
+		sink, err := NewTelegram(TelegramConfig{Token: "123:abc", ChatID: "@alerts", BaseURL: testServer.URL, DisableWebPagePreview: true})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sink.Send(context.Background(), events)).To(Succeed())
+		Expect(message).To(Equal(telegramMessage{
+			ChatID:                "@alerts",
+			Text:                  "https://t.me/a/1\nfirst\n\nhttps://t.me/a/2\nsecond",
+			DisableWebPagePreview: true,
+		}))
+	})
+
+	t.Run("Test too many requests is retried", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		var calls int32
+		testServer.Mux.HandleFunc("/botT/sendMessage", func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte(`{"ok":false,"error_code":429,"description":"Too Many Requests","parameters":{"retry_after":0}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		})
+
+		sink, _ := NewTelegram(TelegramConfig{Token: "T", ChatID: "1", BaseURL: testServer.URL, Options: Options{Retries: 1, Backoff: time.Millisecond}})
+		Expect(sink.Send(context.Background(), events)).To(Succeed())
+		Expect(atomic.LoadInt32(&calls)).To(Equal(int32(2)))
+	})
+
+	t.Run("Test rejected chat is not retried", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		var calls int32
+		testServer.Mux.HandleFunc("/botT/sendMessage", func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&calls, 1)
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`))
+		})
+
+		sink, _ := NewTelegram(TelegramConfig{Token: "T", ChatID: "1", BaseURL: testServer.URL, Options: Options{Retries: 3, Backoff: time.Millisecond}})
+		Expect(sink.Send(context.Background(), events)).To(MatchError("sinks: telegram: 400 Bad Request: chat not found"))
+		Expect(atomic.LoadInt32(&calls)).To(Equal(int32(1)))
+	})
+
+	t.Run("Test split messages are not sent twice", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		var received []string
+		failed := false
+		testServer.Mux.HandleFunc("/botT/sendMessage", func(w http.ResponseWriter, r *http.Request) {
+			var message telegramMessage
+			_ = json.NewDecoder(r.Body).Decode(&message)
+			if strings.HasPrefix(message.Text, "2") && !failed {
+				failed = true
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(`{"ok":false,"error_code":500,"description":"Internal Server Error"}`))
+				return
+			}
+			received = append(received, message.Text[:1])
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		})
+
+		sink, _ := NewTelegram(TelegramConfig{Token: "T", ChatID: "1", BaseURL: testServer.URL})
+		digest := []callback.Event{
+			{Post: tgstat.Post{Link: "1", Text: strings.Repeat("a", 3000)}},
+			{Post: tgstat.Post{Link: "2", Text: strings.Repeat("b", 3000)}},
+			{Post: tgstat.Post{Link: "3", Text: strings.Repeat("c", 3000)}},
+		}
+		Expect(sink.Send(context.Background(), digest)).ToNot(Succeed())
+		Expect(received).To(Equal([]string{"1"}))
+		Expect(sink.Send(context.Background(), digest)).To(Succeed())
+		Expect(received).To(Equal([]string{"1", "2", "3"}))
+		Expect(sink.sent).To(BeEmpty())
+
+		Expect(sink.Send(context.Background(), digest)).To(Succeed())
+		Expect(received).To(Equal([]string{"1", "2", "3", "1", "2", "3"}))
+	})
+
+	t.Run("Test long messages are cut", func(t *testing.T) {
+		Expect([]rune(truncate(strings.Repeat("я", 5000), telegramMaxText))).To(HaveLen(telegramMaxText))
+		Expect(truncate("short", telegramMaxText)).To(Equal("short"))
+	})
+
+	t.Run("Test post text is escaped for the parse mode", func(t *testing.T) {
+		escaped := []callback.Event{{Post: tgstat.Post{Link: "https://t.me/a/1", Text: "a < b & c_d"}}}
+		for mode, text := range map[string]string{
+			"":           "https://t.me/a/1\na < b & c_d",
+			"HTML":       "https://t.me/a/1\na &lt; b &amp; c_d",
+			"MarkdownV2": "https://t\\.me/a/1\na < b & c\\_d",
+		} {
+			sink, err := NewTelegram(TelegramConfig{Token: "T", ChatID: "1", ParseMode: mode})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sink.render(escaped)).To(Equal([]string{text}))
+		}
+	})
+
+	t.Run("Test long digests are split and long posts cut before escaping", func(t *testing.T) {
+		sink, err := NewTelegram(TelegramConfig{Token: "T", ChatID: "1", ParseMode: "HTML"})
+		Expect(err).ToNot(HaveOccurred())
+
+		digest := []callback.Event{
+			{Post: tgstat.Post{Link: "1", Text: strings.Repeat("a", 3000)}},
+			{Post: tgstat.Post{Link: "2", Text: strings.Repeat("b", 3000)}},
+			{Post: tgstat.Post{Link: "3", Text: strings.Repeat("&", 3000)}},
+		}
+		texts, err := sink.render(digest)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(texts).To(HaveLen(3))
+		Expect(texts[0]).To(HavePrefix("1\naaa"))
+		Expect(texts[1]).To(HavePrefix("2\nbbb"))
+		Expect(len([]rune(texts[2]))).To(BeNumerically("<=", telegramMaxText))
+		Expect(texts[2]).To(HaveSuffix("&amp;…"))
+
+		digest[2].Post.Text = "short"
+		texts, err = sink.render(digest[1:])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(texts).To(HaveLen(1))
+	})
+}
+
+func TestFile(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test append and rotate", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.ndjson")
+		sink, err := NewFile(FileConfig{Path: path, MaxSize: 300, MaxBackups: 2})
+		Expect(err).ToNot(HaveOccurred())
+		defer sink.Close()
+
+		for i := 0; i < 4; i++ {
+			Expect(sink.Send(context.Background(), events[:1])).To(Succeed())
+		}
+		Expect(sink.Close()).To(Succeed())
+
+		var files []string
+		for _, name := range []string{path, path + ".1", path + ".2", path + ".3"} {
+			if _, err := os.Stat(name); err == nil {
+				files = append(files, name)
+			}
+		}
+		Expect(files).To(Equal([]string{path, path + ".1", path + ".2"}))
+
+		file, err := os.Open(path)
+		Expect(err).ToNot(HaveOccurred())
+		defer file.Close()
+		scanner := bufio.NewScanner(file)
+		Expect(scanner.Scan()).To(BeTrue())
+		var event callback.Event
+		Expect(json.Unmarshal(scanner.Bytes(), &event)).To(Succeed())
+		Expect(event).To(Equal(events[0]))
+	})
+
+	t.Run("Test reopen after close", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "events.ndjson")
+		sink, err := NewFile(FileConfig{Path: path})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sink.Send(context.Background(), events)).To(Succeed())
+		Expect(sink.Close()).To(Succeed())
+		Expect(sink.Send(context.Background(), events)).To(Succeed())
+		Expect(sink.Close()).To(Succeed())
+
+		data, err := os.ReadFile(path)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Count(string(data), "\n")).To(Equal(4))
+	})
+}
+
+func TestExec(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test pipe events to command", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "out")
+		sink, err := NewExec(ExecConfig{Command: "sh", Args: []string{"-c", `cat > "$OUT"`}, Env: []string{"OUT=" + out}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sink.Send(context.Background(), events)).To(Succeed())
+
+		data, err := os.ReadFile(out)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(strings.Count(string(data), "\n")).To(Equal(2))
+	})
+
+	t.Run("Test failure reports stderr", func(t *testing.T) {
+		sink, _ := NewExec(ExecConfig{Command: "sh", Args: []string{"-c", "echo broken >&2; exit 3"}})
+		err := sink.Send(context.Background(), events)
+		Expect(err).To(MatchError(ContainSubstring("exit status 3: broken")))
+	})
+
+	t.Run("Test timeout", func(t *testing.T) {
+		sink, _ := NewExec(ExecConfig{Command: "sleep", Args: []string{"5"}, Timeout: 50 * time.Millisecond})
+		started := time.Now()
+		Expect(sink.Send(context.Background(), events)).To(HaveOccurred())
+		Expect(time.Since(started)).To(BeNumerically("<", 2*time.Second))
+	})
+}
+
+func TestPolicy(t *testing.T) {
+	RegisterTestingT(t)
+
+	t.Run("Test cancelled context stops retries", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		p := newPolicy(Options{Retries: 10, Backoff: time.Hour})
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+		err := p.run(ctx, func(context.Context) error {
+			calls++
+			return errors.New("down")
+		})
+		Expect(err).To(MatchError("down"))
+		Expect(calls).To(Equal(1))
+	})
+
+	t.Run("Test permanent errors unwrap", func(t *testing.T) {
+		cause := errors.New("cause")
+		Expect(errors.Is(Permanent(cause), cause)).To(BeTrue())
+		Expect(Permanent(nil)).To(BeNil())
+	})
+}
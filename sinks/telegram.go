@@ -0,0 +1,302 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/helios-ag/tgstat-go/callback"
+	"hash/fnv"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultTelegramURL is the Telegram Bot API server.
+	DefaultTelegramURL = "https://api.telegram.org"
+	// DefaultTelegramText lists the link and text of every event, escaped for
+	// the parse mode.
+	DefaultTelegramText = `{{range $i, $e := .Events}}{{if $i}}
+
+{{end}}{{escape $e.Post.Link}}
+{{escape $e.Post.Text}}{{end}}`
+
+	// telegramMaxText is the longest message accepted by sendMessage.
+	telegramMaxText = 4096
+	// telegramMaxResumed is the number of partly sent batches remembered.
+	telegramMaxResumed = 1024
+)
+
+// TelegramConfig configures a Telegram sink.
+type TelegramConfig struct {
+	// Token of the bot.
+	Token string
+	// ChatID is a numeric chat ID or a @channel username.
+	ChatID string
+	// BaseURL defaults to DefaultTelegramURL.
+	BaseURL string
+	// Text is a text/template of the message, executed like WebhookConfig.Body.
+	// It defaults to DefaultTelegramText. The escape function of the template
+	// escapes a value for ParseMode: HTML, Markdown or MarkdownV2.
+	//
+	// Events that do not fit in a message of 4096 characters are sent in
+	// several messages, and the post text of a single event is cut to fit.
+	// When sending the same events again after a failure, the messages that
+	// already went out are skipped.
+	Text                  string
+	ParseMode             string
+	DisableWebPagePreview bool
+	// Client defaults to an http.Client with DefaultTimeout.
+	Client *http.Client
+	Options
+}
+
+// Telegram sends events as a message of a bot through the sendMessage method.
+type Telegram struct {
+	config TelegramConfig
+	text   *template.Template
+	policy policy
+
+	mu sync.Mutex
+	// sent maps the hash of the messages of a partly sent batch to the
+	// number of them that went out.
+	sent map[uint64]int
+}
+
+// NewTelegram returns a Telegram sink.
+func NewTelegram(config TelegramConfig) (*Telegram, error) {
+	if config.Token == "" || config.ChatID == "" {
+		return nil, errors.New("sinks: telegram token and chat ID are required")
+	}
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultTelegramURL
+	}
+	if config.Text == "" {
+		config.Text = DefaultTelegramText
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: DefaultTimeout}
+	}
+	text, err := parseTemplate("telegram", config.Text, template.FuncMap{"escape": escaper(config.ParseMode)})
+	if err != nil {
+		return nil, fmt.Errorf("sinks: telegram text: %w", err)
+	}
+	return &Telegram{config: config, text: text, policy: newPolicy(config.Options), sent: make(map[uint64]int)}, nil
+}
+
+type telegramMessage struct {
+	ChatID                string `json:"chat_id"`
+	Text                  string `json:"text"`
+	ParseMode             string `json:"parse_mode,omitempty"`
+	DisableWebPagePreview bool   `json:"disable_web_page_preview,omitempty"`
+}
+
+type telegramResponse struct {
+	OK          bool   `json:"ok"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+	Parameters  struct {
+		RetryAfter int `json:"retry_after"`
+	} `json:"parameters"`
+}
+
+// Send renders the messages and sends them to the chat, resuming after the
+// messages of the same events sent by a previous failed call.
+func (t *Telegram) Send(ctx context.Context, events []callback.Event) error {
+	texts, err := t.render(events)
+	if err != nil {
+		return err
+	}
+	key := batchKey(texts)
+
+	t.mu.Lock()
+	start := t.sent[key]
+	t.mu.Unlock()
+
+	for i := start; i < len(texts); i++ {
+		if err := t.send(ctx, texts[i]); err != nil {
+			if i > 0 {
+				t.remember(key, i)
+			}
+			return err
+		}
+	}
+	if start > 0 {
+		t.mu.Lock()
+		delete(t.sent, key)
+		t.mu.Unlock()
+	}
+	return nil
+}
+
+// remember records that the first n messages of a batch went out.
+func (t *Telegram) remember(key uint64, n int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.sent[key]; !ok && len(t.sent) >= telegramMaxResumed {
+		for old := range t.sent {
+			delete(t.sent, old)
+			break
+		}
+	}
+	t.sent[key] = n
+}
+
+// batchKey identifies the messages of a batch.
+func batchKey(texts []string) uint64 {
+	hash := fnv.New64a()
+	for _, text := range texts {
+		hash.Write([]byte(text)) //nolint
+		hash.Write([]byte{0})    //nolint
+	}
+	return hash.Sum64()
+}
+
+// render executes the template for as many events as fit in a message.
+func (t *Telegram) render(events []callback.Event) ([]string, error) {
+	var texts []string
+	for len(events) > 0 {
+		n := len(events)
+		text, err := t.execute(events[:n])
+		for err == nil && n > 1 && tooLong(text) {
+			n--
+			text, err = t.execute(events[:n])
+		}
+		if err == nil && tooLong(text) {
+			text, err = t.fit(events[0], text)
+		}
+		if err != nil {
+			return nil, err
+		}
+		texts = append(texts, text)
+		events = events[n:]
+	}
+	return texts, nil
+}
+
+// fit cuts the post text of event, before escaping, to the longest prefix
+// whose message fits.
+func (t *Telegram) fit(event callback.Event, text string) (string, error) {
+	post := []rune(event.Post.Text)
+	cut := func(keep int) (string, error) {
+		event.Post.Text = string(post[:keep]) + "…"
+		return t.execute([]callback.Event{event})
+	}
+
+	fitting, err := cut(0)
+	if err != nil {
+		return "", err
+	}
+	if tooLong(fitting) {
+		if t.config.ParseMode == "" {
+			return truncate(text, telegramMaxText), nil
+		}
+		return "", Permanent(errors.New("sinks: telegram: message longer than 4096 characters without the post text"))
+	}
+	for low, high := 0, len(post); low < high; {
+		keep := (low + high + 1) / 2
+		text, err := cut(keep)
+		if err != nil {
+			return "", err
+		}
+		if tooLong(text) {
+			high = keep - 1
+		} else {
+			low, fitting = keep, text
+		}
+	}
+	return fitting, nil
+}
+
+func (t *Telegram) execute(events []callback.Event) (string, error) {
+	var text strings.Builder
+	if err := t.text.Execute(&text, newTemplateData(events)); err != nil {
+		return "", Permanent(fmt.Errorf("sinks: telegram text: %w", err))
+	}
+	return text.String(), nil
+}
+
+func tooLong(text string) bool {
+	return utf8.RuneCountInString(text) > telegramMaxText
+}
+
+func (t *Telegram) send(ctx context.Context, text string) error {
+	message := telegramMessage{
+		ChatID:                t.config.ChatID,
+		Text:                  text,
+		ParseMode:             t.config.ParseMode,
+		DisableWebPagePreview: t.config.DisableWebPagePreview,
+	}
+	body, err := json.Marshal(message)
+	if err != nil {
+		return fmt.Errorf("sinks: telegram: %w", err)
+	}
+	endpoint := strings.TrimRight(t.config.BaseURL, "/") + "/bot" + t.config.Token + "/sendMessage"
+
+	return t.policy.run(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return Permanent(fmt.Errorf("sinks: telegram: %w", err))
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := t.config.Client.Do(req)
+		if err != nil {
+			// the URL holds the token, keep it out of the error
+			var urlErr *url.Error
+			if errors.As(err, &urlErr) {
+				err = urlErr.Err
+			}
+			return fmt.Errorf("sinks: telegram: %w", err)
+		}
+		defer resp.Body.Close()
+
+		var result telegramResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return fmt.Errorf("sinks: telegram: %s: %w", resp.Status, err)
+		}
+		if result.OK {
+			return nil
+		}
+		err = fmt.Errorf("sinks: telegram: %d %s", result.ErrorCode, result.Description)
+		switch {
+		case result.ErrorCode == http.StatusTooManyRequests:
+			return &retryAfterError{err, time.Duration(result.Parameters.RetryAfter) * time.Second}
+		case result.ErrorCode >= 400 && result.ErrorCode < 500:
+			return Permanent(err)
+		}
+		return err
+	})
+}
+
+// escaper returns the escaping of text for a Telegram parse mode.
+func escaper(parseMode string) func(string) string {
+	switch strings.ToLower(parseMode) {
+	case "html":
+		return html.EscapeString
+	case "markdown":
+		return strings.NewReplacer("_", `\_`, "*", `\*`, "`", "\\`", "[", `\[`).Replace
+	case "markdownv2":
+		var pairs []string
+		for _, c := range "\\_*[]()~`>#+-=|{}.!" {
+			pairs = append(pairs, string(c), `\`+string(c))
+		}
+		return strings.NewReplacer(pairs...).Replace
+	}
+	return func(text string) string { return text }
+}
+
+// truncate cuts s to at most limit characters, for messages without parse mode.
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,111 @@
+package sinks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/helios-ag/tgstat-go/callback"
+	"io"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+)
+
+// DefaultWebhookBody sends the events as {"events": [...]}.
+const DefaultWebhookBody = `{"events": {{json .Events}}}`
+
+// DefaultTimeout bounds a single HTTP request or command run.
+const DefaultTimeout = 30 * time.Second
+
+// WebhookConfig configures a Webhook.
+type WebhookConfig struct {
+	URL string
+	// Method defaults to POST.
+	Method  string
+	Headers map[string]string
+	// Body is a text/template of the JSON body, executed with .Events and
+	// .Event, the first event. The json function encodes a value.
+	// It defaults to DefaultWebhookBody.
+	Body string
+	// Client defaults to an http.Client with DefaultTimeout.
+	Client *http.Client
+	Options
+}
+
+// Webhook posts events to an HTTP endpoint. 2xx answers are successes,
+// other 4xx than 408 and 429 are not retried.
+type Webhook struct {
+	config WebhookConfig
+	body   *template.Template
+	policy policy
+}
+
+// NewWebhook returns a webhook sink.
+func NewWebhook(config WebhookConfig) (*Webhook, error) {
+	if config.URL == "" {
+		return nil, errors.New("sinks: webhook URL is required")
+	}
+	if config.Method == "" {
+		config.Method = http.MethodPost
+	}
+	if config.Body == "" {
+		config.Body = DefaultWebhookBody
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: DefaultTimeout}
+	}
+	body, err := parseTemplate("webhook", config.Body)
+	if err != nil {
+		return nil, fmt.Errorf("sinks: webhook body: %w", err)
+	}
+	return &Webhook{config: config, body: body, policy: newPolicy(config.Options)}, nil
+}
+
+// Send renders the body and sends it.
+func (w *Webhook) Send(ctx context.Context, events []callback.Event) error {
+	var body bytes.Buffer
+	if err := w.body.Execute(&body, newTemplateData(events)); err != nil {
+		return fmt.Errorf("sinks: webhook body: %w", err)
+	}
+	if !json.Valid(body.Bytes()) {
+		return errors.New("sinks: webhook body is not valid JSON")
+	}
+
+	return w.policy.run(ctx, func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, w.config.Method, w.config.URL, bytes.NewReader(body.Bytes()))
+		if err != nil {
+			return Permanent(fmt.Errorf("sinks: webhook: %w", err))
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range w.config.Headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := w.config.Client.Do(req)
+		return httpError("webhook", resp, err)
+	})
+}
+
+// httpError turns an unsuccessful response into an error, classifying it for retries.
+func httpError(sink string, resp *http.Response, err error) error {
+	if err != nil {
+		return fmt.Errorf("sinks: %s: %w", sink, err)
+	}
+	defer resp.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	err = fmt.Errorf("sinks: %s: %s: %s", sink, resp.Status, bytes.TrimSpace(message))
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		after, _ := strconv.Atoi(resp.Header.Get("Retry-After"))
+		return &retryAfterError{err, time.Duration(after) * time.Second}
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout:
+		return Permanent(err)
+	}
+	return err
+}
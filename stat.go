@@ -14,3 +14,19 @@ type StatResult struct {
 	Status   string         `json:"status"`
 	Response []StatResponse `json:"response"`
 }
+
+// Remaining returns the requests left on the tariff, false when the result
+// does not count requests.
+func (r StatResult) Remaining() (int, bool) {
+	for _, service := range r.Response {
+		if service.SpentRequests == "" {
+			continue
+		}
+		spent, limit := parseSpent(service.SpentRequests)
+		if limit <= 0 {
+			return 0, false
+		}
+		return max(limit-spent, 0), true
+	}
+	return 0, false
+}
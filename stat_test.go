@@ -0,0 +1,26 @@
+package tgstat_go
+
+import (
+	. "github.com/onsi/gomega"
+	"testing"
+)
+
+func TestStatResultRemaining(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test remaining requests", func(t *testing.T) {
+		remaining, ok := StatResult{Response: []StatResponse{{SpentChannels: "1/10"}, {SpentRequests: "950/1000"}}}.Remaining()
+		Expect(ok).To(BeTrue())
+		Expect(remaining).To(Equal(50))
+	})
+
+	t.Run("Test overspent requests", func(t *testing.T) {
+		remaining, ok := StatResult{Response: []StatResponse{{SpentRequests: "1200/1000"}}}.Remaining()
+		Expect(ok).To(BeTrue())
+		Expect(remaining).To(BeZero())
+	})
+
+	t.Run("Test tariff without requests", func(t *testing.T) {
+		_, ok := StatResult{Response: []StatResponse{{SpentWords: "1/10"}}}.Remaining()
+		Expect(ok).To(BeFalse())
+	})
+}
@@ -0,0 +1,415 @@
+package testing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types and subscription types of the simulated deliveries.
+const (
+	EventNewPost        = "new_post"
+	EventEditPost       = "edit_post"
+	EventRemovePost     = "remove_post"
+	SubscriptionChannel = "channel"
+	SubscriptionKeyword = "keyword"
+)
+
+// SimulatedChannel is a channel the simulator publishes posts in.
+type SimulatedChannel struct {
+	ID                int    `json:"id"`
+	Link              string `json:"link"`
+	Username          string `json:"username"`
+	Title             string `json:"title"`
+	ParticipantsCount int    `json:"participants_count"`
+}
+
+// SimulatedMedia is the media of a simulated post.
+type SimulatedMedia struct {
+	MediaType string `json:"media_type"`
+	MimeType  string `json:"mime_type,omitempty"`
+	Size      int    `json:"size,omitempty"`
+}
+
+// SimulatedPost is a post as delivered by TGStat, is_deleted being 0 or 1.
+type SimulatedPost struct {
+	ID        int64          `json:"id"`
+	Date      int            `json:"date"`
+	Views     int            `json:"views"`
+	Link      string         `json:"link"`
+	ChannelID int            `json:"channel_id"`
+	IsDeleted int            `json:"is_deleted"`
+	Text      string         `json:"text"`
+	Media     SimulatedMedia `json:"media"`
+}
+
+// SimulatedEvent is the body of a simulated delivery.
+type SimulatedEvent struct {
+	EventID          int64            `json:"event_id"`
+	EventType        string           `json:"event_type"`
+	SubscriptionID   int              `json:"subscription_id"`
+	SubscriptionType string           `json:"subscription_type"`
+	Post             SimulatedPost    `json:"post"`
+	Channel          SimulatedChannel `json:"channel"`
+	Keyword          string           `json:"keyword,omitempty"`
+}
+
+// SimulatorConfig configures a Simulator, empty fields get realistic defaults.
+type SimulatorConfig struct {
+	// URL is the callback URL the deliveries are posted to.
+	URL    string
+	Client *http.Client
+	// Seed makes the generated events reproducible.
+	Seed int64
+
+	Channels []SimulatedChannel
+	// Texts are the post texts, the keyword is inserted in those of keyword events.
+	Texts      []string
+	MediaTypes []string
+	// Keywords are the queries of the keyword subscriptions, no keyword events when empty.
+	Keywords []string
+	// Mix weighs the generated kinds: new_post, edit_post, remove_post and
+	// keyword. Edits and removals need a published post, new posts are
+	// generated instead until there is one.
+	Mix map[string]int
+
+	// Interval is the wait between two deliveries of Run, randomized by Jitter.
+	Interval time.Duration
+	Jitter   time.Duration
+	// Redelivery is the probability that Run delivers an event again, the way
+	// TGStat retries a delivery without a timely answer.
+	Redelivery float64
+	// Reorder shuffles the deliveries of Run within windows of that many events.
+	Reorder int
+	// Retries is the number of times Run posts a delivery again after a failure.
+	Retries int
+
+	// VerifyCode is sent by Verify, defaults to a random code.
+	VerifyCode string
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+var (
+	defaultChannels = []SimulatedChannel{
+		{ID: 101, Link: "t.me/technews", Username: "@technews", Title: "Tech News", ParticipantsCount: 125000},
+		{ID: 102, Link: "t.me/marketwatch", Username: "@marketwatch", Title: "Market Watch", ParticipantsCount: 48000},
+		{ID: 103, Link: "t.me/citydaily", Username: "@citydaily", Title: "City Daily", ParticipantsCount: 9100},
+	}
+	defaultTexts = []string{
+		"Quarterly results beat expectations, shares up 4% in early trading.",
+		"New smartphone lineup announced with a bigger battery and a faster chip.",
+		"Road works on the main avenue will last until the end of the month.",
+		"Central bank keeps the key rate unchanged, analysts expect a cut in autumn.",
+		"Open source release 2.0 brings a new plugin system and better docs.",
+		"Weekend weather: sunny on Saturday, rain expected on Sunday evening.",
+	}
+	defaultMediaTypes = []string{"mediaText", "mediaText", "mediaPhoto", "mediaVideo", "mediaDocument"}
+	defaultMix        = map[string]int{EventNewPost: 6, EventEditPost: 2, EventRemovePost: 1, SubscriptionKeyword: 2}
+	mimeTypes         = map[string]string{"mediaPhoto": "image/jpeg", "mediaVideo": "video/mp4", "mediaDocument": "application/pdf"}
+)
+
+// Simulator generates callback deliveries and posts them to a handler, for
+// developing and testing handlers without TGStat.
+type Simulator struct {
+	config SimulatorConfig
+
+	mu          sync.Mutex
+	rand        *rand.Rand
+	nextEventID int64
+	nextPostID  int64
+	date        int
+	published   []SimulatedEvent
+}
+
+// NewSimulator returns a simulator posting to config.URL.
+func NewSimulator(config SimulatorConfig) *Simulator {
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	if len(config.Channels) == 0 {
+		config.Channels = defaultChannels
+	}
+	if len(config.Texts) == 0 {
+		config.Texts = defaultTexts
+	}
+	if len(config.MediaTypes) == 0 {
+		config.MediaTypes = defaultMediaTypes
+	}
+	if len(config.Mix) == 0 {
+		config.Mix = defaultMix
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+
+	s := &Simulator{config: config, rand: rand.New(rand.NewSource(config.Seed)), nextEventID: 1, nextPostID: 1}
+	if s.config.VerifyCode == "" {
+		s.config.VerifyCode = fmt.Sprintf("TGSTAT_VERIFY_CODE_%06d", s.rand.Intn(1000000))
+	}
+	return s
+}
+
+// Next generates the next event.
+func (s *Simulator) Next() SimulatedEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kind := s.pick()
+	if (kind == EventEditPost || kind == EventRemovePost) && len(s.published) == 0 {
+		kind = EventNewPost
+	}
+	if kind == SubscriptionKeyword && len(s.config.Keywords) == 0 {
+		kind = EventNewPost
+	}
+
+	now := int(s.config.Now().Unix())
+	// dates never go backwards, even with a fixed Now
+	s.date = max(s.date+1, now)
+
+	var event SimulatedEvent
+	switch kind {
+	case EventEditPost, EventRemovePost:
+		i := s.rand.Intn(len(s.published))
+		event = s.published[i]
+		event.EventType = kind
+		event.Post.Views += s.rand.Intn(500)
+		if kind == EventEditPost {
+			event.Post.Text += " (updated)"
+			s.published[i] = event
+		} else {
+			event.Post.IsDeleted = 1
+			s.published = append(s.published[:i], s.published[i+1:]...)
+		}
+	default:
+		event = s.newPost(kind == SubscriptionKeyword)
+		if !event.isKeyword() {
+			// keyword subscriptions only deliver new posts
+			s.published = append(s.published, event)
+		}
+	}
+	event.EventID = s.nextEventID
+	s.nextEventID++
+	return event
+}
+
+func (e SimulatedEvent) isKeyword() bool {
+	return e.SubscriptionType == SubscriptionKeyword
+}
+
+func (s *Simulator) pick() string {
+	kinds := []string{EventNewPost, EventEditPost, EventRemovePost, SubscriptionKeyword}
+	total := 0
+	for _, kind := range kinds {
+		total += max(s.config.Mix[kind], 0)
+	}
+	if total == 0 {
+		return EventNewPost
+	}
+	n := s.rand.Intn(total)
+	for _, kind := range kinds {
+		if n -= max(s.config.Mix[kind], 0); n < 0 {
+			return kind
+		}
+	}
+	return EventNewPost
+}
+
+func (s *Simulator) newPost(keyword bool) SimulatedEvent {
+	channelIndex := s.rand.Intn(len(s.config.Channels))
+	channel := s.config.Channels[channelIndex]
+	text := s.config.Texts[s.rand.Intn(len(s.config.Texts))]
+	mediaType := s.config.MediaTypes[s.rand.Intn(len(s.config.MediaTypes))]
+
+	post := SimulatedPost{
+		ID:        s.nextPostID,
+		Date:      s.date,
+		Views:     s.rand.Intn(channel.ParticipantsCount/10 + 1),
+		Link:      fmt.Sprintf("%s/%d", strings.TrimSuffix(channel.Link, "/"), s.nextPostID),
+		ChannelID: channel.ID,
+		Text:      text,
+		Media:     SimulatedMedia{MediaType: mediaType},
+	}
+	if mime, ok := mimeTypes[mediaType]; ok {
+		post.Media.MimeType = mime
+		post.Media.Size = 10000 + s.rand.Intn(5000000)
+	}
+	s.nextPostID++
+
+	event := SimulatedEvent{
+		EventType:        EventNewPost,
+		SubscriptionID:   channelIndex + 1,
+		SubscriptionType: SubscriptionChannel,
+		Post:             post,
+		Channel:          channel,
+	}
+	if keyword {
+		i := s.rand.Intn(len(s.config.Keywords))
+		event.Keyword = s.config.Keywords[i]
+		event.SubscriptionID = 1000 + i
+		event.SubscriptionType = SubscriptionKeyword
+		event.Post.Text = fmt.Sprintf("%s #%s", text, event.Keyword)
+	}
+	return event
+}
+
+// Verify runs the verification of SetCallback: it posts the verify code and
+// checks that the handler answers it.
+func (s *Simulator) Verify(ctx context.Context) error {
+	body, err := json.Marshal(map[string]string{"verify_code": s.config.VerifyCode})
+	if err != nil {
+		return err
+	}
+	answer, err := s.post(ctx, body)
+	if err != nil {
+		return fmt.Errorf("simulator: verification: %w", err)
+	}
+	if strings.TrimSpace(answer) != s.config.VerifyCode {
+		return fmt.Errorf("simulator: verification: answered %q instead of %q", answer, s.config.VerifyCode)
+	}
+	return nil
+}
+
+// VerifyCode returns the code sent by Verify.
+func (s *Simulator) VerifyCode() string {
+	return s.config.VerifyCode
+}
+
+// Deliver posts an event once, failing unless the handler answers 2xx.
+func (s *Simulator) Deliver(ctx context.Context, event SimulatedEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if _, err := s.post(ctx, body); err != nil {
+		return fmt.Errorf("simulator: event %d: %w", event.EventID, err)
+	}
+	return nil
+}
+
+// SimulationResult is the outcome of Run.
+type SimulationResult struct {
+	// Delivered lists the events acknowledged by the handler, in delivery
+	// order and with redeliveries.
+	Delivered []SimulatedEvent
+	// Failed lists the events the handler failed on after the retries.
+	Failed []SimulatedEvent
+	// Attempts counts the posted deliveries, including retries.
+	Attempts int
+}
+
+// Run verifies the callback URL, then generates n events and delivers them
+// with the configured timing, redeliveries and reordering. Failed deliveries
+// do not stop the run, they are listed in the result.
+func (s *Simulator) Run(ctx context.Context, n int) (*SimulationResult, error) {
+	if err := s.Verify(ctx); err != nil {
+		return nil, err
+	}
+
+	var queue []SimulatedEvent
+	for i := 0; i < n; i++ {
+		event := s.Next()
+		queue = append(queue, event)
+		if s.chance(s.config.Redelivery) {
+			queue = append(queue, event)
+		}
+	}
+	s.reorder(queue)
+
+	result := &SimulationResult{}
+	for i, event := range queue {
+		if i != 0 && !s.wait(ctx) {
+			return result, ctx.Err()
+		}
+		var err error
+		for attempt := 0; attempt <= s.config.Retries; attempt++ {
+			result.Attempts++
+			if err = s.Deliver(ctx, event); err == nil || ctx.Err() != nil {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		if err != nil {
+			result.Failed = append(result.Failed, event)
+			continue
+		}
+		result.Delivered = append(result.Delivered, event)
+	}
+	return result, nil
+}
+
+func (s *Simulator) chance(probability float64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return probability > 0 && s.rand.Float64() < probability
+}
+
+// reorder shuffles the queue within windows of Reorder events.
+func (s *Simulator) reorder(queue []SimulatedEvent) {
+	if s.config.Reorder < 2 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for start := 0; start < len(queue); start += s.config.Reorder {
+		window := queue[start:min(start+s.config.Reorder, len(queue))]
+		s.rand.Shuffle(len(window), func(i, j int) { window[i], window[j] = window[j], window[i] })
+	}
+}
+
+func (s *Simulator) wait(ctx context.Context) bool {
+	delay := s.config.Interval
+	if s.config.Jitter > 0 {
+		s.mu.Lock()
+		delay += time.Duration(s.rand.Int63n(int64(s.config.Jitter)))
+		s.mu.Unlock()
+	}
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+func (s *Simulator) post(ctx context.Context, body []byte) (string, error) {
+	if s.config.URL == "" {
+		return "", errors.New("URL is empty")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.URL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	answer, err := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("status %s", resp.Status)
+	}
+	return string(answer), nil
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/helios-ag/tgstat-go/internal/ratelimit"
 	"io"
 	"net/http"
 	"net/url"
@@ -37,6 +38,9 @@ type API interface {
 type Client struct {
 	Url        string
 	httpClient *http.Client
+	limiter    *ratelimit.Limiter
+	pool       *TokenPool
+	breakers   *circuitBreakers
 }
 
 var TGStatClient Client
@@ -68,6 +72,14 @@ var NewRestRequest = func(c *Client, ctx context.Context, token, method, urlPath
 		return nil, errors.New("data is not initialised")
 	}
 
+	if token == "" && c.pool != nil {
+		var err error
+		if token, err = c.pool.Pick(urlPath); err != nil {
+			return nil, err
+		}
+		ctx = context.WithValue(ctx, pooledTokenKey{}, true)
+	}
+
 	if token == "" {
 		return nil, errors.New("token not found")
 	}
@@ -105,8 +117,61 @@ var reader = func(r io.Reader) ([]byte, error) {
 }
 
 // Do perform an HTTP request against the API.
+// When the token of the request was drawn from the pool of the client,
+// requests failing with a quota error are retried with the next available
+// token, each token of the pool being tried at most once.
 func (c *Client) Do(r *http.Request, v interface{}) (*http.Response, error) {
+	resp, err := c.do(r, v)
+	if c.pool == nil || r.Context().Value(pooledTokenKey{}) == nil {
+		return resp, err
+	}
+
+	attempts := len(c.pool.Tokens())
+	for attempt := 1; IsQuotaError(err); attempt++ {
+		token := requestToken(r)
+		if token == "" {
+			return resp, err
+		}
+		c.pool.MarkExhausted(token)
+		if attempt >= attempts {
+			return resp, fmt.Errorf("%w: %w", ErrTokensExhausted, err)
+		}
+
+		next, pickErr := c.pool.Pick(c.endpointOf(r))
+		if pickErr != nil {
+			return resp, fmt.Errorf("%w: %w", pickErr, err)
+		}
+		if r, pickErr = withToken(r, next); pickErr != nil {
+			return resp, pickErr
+		}
+		resp, err = c.do(r, v)
+	}
+
+	return resp, err
+}
+
+func (c *Client) do(r *http.Request, v interface{}) (*http.Response, error) {
+	if c.limiter != nil {
+		if err := c.limiter.Wait(r.Context()); err != nil {
+			return nil, err
+		}
+	}
+
+	breaker := c.breakers.get(endpointGroup(c.endpointOf(r)))
+	if breaker != nil {
+		if err := breaker.allow(); err != nil {
+			return nil, err
+		}
+	}
+
 	resp, err := c.httpClient.Do(r)
+	if breaker != nil {
+		if errors.Is(err, context.Canceled) {
+			breaker.release()
+		} else {
+			breaker.record(isBackendFailure(resp, err))
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -152,7 +217,7 @@ func errorFromResponse(resp *http.Response, body []byte) error {
 	if respBody.Error == "" || respBody.VerifyCode != "" {
 		return nil
 	}
-	return fmt.Errorf("%s",respBody.Error)
+	return &APIError{Message: respBody.Error}
 }
 
 // NewClient creates a new client.
@@ -183,6 +248,9 @@ func newAPI(url string, options ...ClientOption) *Client {
 	client := &Client{
 		Url:        url,
 		httpClient: &http.Client{},
+		limiter:    TGStatClient.limiter,
+		pool:       TGStatClient.pool,
+		breakers:   TGStatClient.breakers,
 	}
 
 	for _, option := range options {
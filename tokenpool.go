@@ -0,0 +1,301 @@
+package tgstat_go
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TokenStrategy selects which token of a TokenPool serves the next request.
+type TokenStrategy int
+
+const (
+	// RoundRobin cycles through the available tokens.
+	RoundRobin TokenStrategy = iota
+	// LeastUsed picks the token with the lowest share of its request quota spent.
+	LeastUsed
+	// StickyPerEndpoint keeps using the same token for an endpoint until it is exhausted.
+	StickyPerEndpoint
+)
+
+// DefaultTokenCooldown is how long an exhausted token is skipped when its expiry is unknown.
+const DefaultTokenCooldown = time.Hour
+
+// ErrTokensExhausted is returned when every token of the pool has run out of quota.
+var ErrTokensExhausted = errors.New("all tokens are exhausted")
+
+type pooledToken struct {
+	token          string
+	used           int
+	spent          int
+	limit          int
+	expiredAt      time.Time
+	exhaustedUntil time.Time
+}
+
+// TokenPool rotates several TGStat tokens and fails over when one runs out of quota.
+type TokenPool struct {
+	mu       sync.Mutex
+	tokens   []*pooledToken
+	strategy TokenStrategy
+	cooldown time.Duration
+	next     int
+	sticky   map[string]*pooledToken
+	now      func() time.Time
+}
+
+// NewTokenPool creates a pool over the given tokens.
+func NewTokenPool(strategy TokenStrategy, tokens ...string) *TokenPool {
+	pool := &TokenPool{
+		strategy: strategy,
+		cooldown: DefaultTokenCooldown,
+		sticky:   make(map[string]*pooledToken),
+		now:      time.Now,
+	}
+	for _, token := range tokens {
+		if token != "" {
+			pool.tokens = append(pool.tokens, &pooledToken{token: token})
+		}
+	}
+	return pool
+}
+
+// SetCooldown sets how long an exhausted token is skipped when its expiry is
+// unknown. The cooldown must be positive.
+func (p *TokenPool) SetCooldown(cooldown time.Duration) error {
+	if cooldown <= 0 {
+		return fmt.Errorf("tgstat: token cooldown %s is not positive", cooldown)
+	}
+	p.mu.Lock()
+	p.cooldown = cooldown
+	p.mu.Unlock()
+	return nil
+}
+
+// Tokens returns the tokens of the pool.
+func (p *TokenPool) Tokens() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	tokens := make([]string, 0, len(p.tokens))
+	for _, t := range p.tokens {
+		tokens = append(tokens, t.token)
+	}
+	return tokens
+}
+
+// UpdateUsage records the usage.Stat result of a token,
+// it is used by the LeastUsed strategy and to know when a token expires.
+func (p *TokenPool) UpdateUsage(token string, stat StatResult) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t := p.find(token)
+	if t == nil {
+		return
+	}
+	for _, service := range stat.Response {
+		if service.SpentRequests == "" {
+			continue
+		}
+		t.spent, t.limit = parseSpent(service.SpentRequests)
+		t.used = 0
+		if service.ExpiredAt > 0 {
+			t.expiredAt = time.Unix(service.ExpiredAt, 0)
+		}
+		return
+	}
+}
+
+// MarkExhausted takes the token out of rotation until its tariff expires
+// or the cooldown passes.
+func (p *TokenPool) MarkExhausted(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t := p.find(token)
+	if t == nil {
+		return
+	}
+	now := p.now()
+	t.exhaustedUntil = now.Add(p.cooldown)
+	if t.expiredAt.After(now) {
+		t.exhaustedUntil = t.expiredAt
+	}
+	for endpoint, s := range p.sticky {
+		if s == t {
+			delete(p.sticky, endpoint)
+		}
+	}
+}
+
+// Pick returns the token to use for a request to endpoint.
+func (p *TokenPool) Pick(endpoint string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	available := func(t *pooledToken) bool {
+		return !t.exhaustedUntil.After(now)
+	}
+
+	var picked *pooledToken
+	switch p.strategy {
+	case StickyPerEndpoint:
+		if t, ok := p.sticky[endpoint]; ok && available(t) {
+			picked = t
+			break
+		}
+		picked = p.roundRobin(available)
+		if picked != nil {
+			p.sticky[endpoint] = picked
+		}
+	case LeastUsed:
+		for _, t := range p.tokens {
+			if available(t) && (picked == nil || t.share() < picked.share()) {
+				picked = t
+			}
+		}
+	default:
+		picked = p.roundRobin(available)
+	}
+
+	if picked == nil {
+		return "", ErrTokensExhausted
+	}
+	picked.used++
+	return picked.token, nil
+}
+
+func (p *TokenPool) roundRobin(available func(*pooledToken) bool) *pooledToken {
+	for i := 0; i < len(p.tokens); i++ {
+		t := p.tokens[(p.next+i)%len(p.tokens)]
+		if available(t) {
+			p.next = (p.next + i + 1) % len(p.tokens)
+			return t
+		}
+	}
+	return nil
+}
+
+func (p *TokenPool) find(token string) *pooledToken {
+	for _, t := range p.tokens {
+		if t.token == token {
+			return t
+		}
+	}
+	return nil
+}
+
+func (t *pooledToken) share() float64 {
+	if t.limit <= 0 {
+		return float64(t.spent + t.used)
+	}
+	return float64(t.spent+t.used) / float64(t.limit)
+}
+
+// parseSpent parses usage counters such as "120/5000".
+func parseSpent(value string) (spent, limit int) {
+	parts := strings.SplitN(value, "/", 2)
+	spent, _ = strconv.Atoi(strings.TrimSpace(parts[0]))
+	if len(parts) == 2 {
+		limit, _ = strconv.Atoi(strings.TrimSpace(parts[1]))
+	}
+	return spent, limit
+}
+
+// WithTokenPool makes the shared client draw tokens from pool
+// whenever a request is made without an explicit token.
+func WithTokenPool(pool *TokenPool) {
+	TGStatClient.pool = pool
+}
+
+// QuotaErrorCodes are the API error codes meaning the token cannot make more
+// requests until its quota or subscription is renewed.
+var QuotaErrorCodes = []string{
+	"quota_requests_exceeded",
+	"quota_words_exceeded",
+	"quota_channels_exceeded",
+	"no_active_subscription",
+}
+
+// IsQuotaError reports whether err is an API error with one of QuotaErrorCodes.
+func IsQuotaError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return slices.Contains(QuotaErrorCodes, apiErr.Message)
+}
+
+// pooledTokenKey marks the context of requests whose token was drawn from the pool.
+type pooledTokenKey struct{}
+
+// requestToken returns the token a request was built with.
+func requestToken(r *http.Request) string {
+	if token := r.URL.Query().Get("token"); token != "" {
+		return token
+	}
+	if r.GetBody == nil {
+		return ""
+	}
+	body, err := r.GetBody()
+	if err != nil {
+		return ""
+	}
+	data := make(map[string]string)
+	_ = json.NewDecoder(body).Decode(&data)
+	return data["token"]
+}
+
+// withToken clones the request replacing its token.
+func withToken(r *http.Request, token string) (*http.Request, error) {
+	req := r.Clone(r.Context())
+
+	if r.URL.Query().Has("token") {
+		query := r.URL.Query()
+		query.Set("token", token)
+		req.URL.RawQuery = query.Encode()
+	}
+
+	if r.GetBody != nil {
+		body, err := r.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		raw, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) > 0 {
+			data := make(map[string]string)
+			if err := json.Unmarshal(raw, &data); err != nil {
+				return nil, err
+			}
+			data["token"] = token
+			raw, _ = json.Marshal(data)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(raw))
+		req.ContentLength = int64(len(raw))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(raw)), nil
+		}
+	}
+
+	return req, nil
+}
+
+// endpointOf returns the API path of a request relative to the client URL.
+func (c *Client) endpointOf(r *http.Request) string {
+	base, err := url.Parse(c.Url)
+	if err != nil {
+		return r.URL.Path
+	}
+	return strings.TrimPrefix(r.URL.Path, strings.TrimRight(base.Path, "/"))
+}
@@ -0,0 +1,180 @@
+package tgstat_go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/helios-ag/tgstat-go/endpoints"
+	server "github.com/helios-ag/tgstat-go/testing"
+	. "github.com/onsi/gomega"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTokenPool(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test round robin", func(t *testing.T) {
+		pool := NewTokenPool(RoundRobin, "a", "b")
+		Expect(pool.Pick(endpoints.ChannelsGet)).To(Equal("a"))
+		Expect(pool.Pick(endpoints.ChannelsGet)).To(Equal("b"))
+		Expect(pool.Pick(endpoints.ChannelsGet)).To(Equal("a"))
+	})
+
+	t.Run("Test sticky per endpoint", func(t *testing.T) {
+		pool := NewTokenPool(StickyPerEndpoint, "a", "b")
+		Expect(pool.Pick(endpoints.ChannelsGet)).To(Equal("a"))
+		Expect(pool.Pick(endpoints.PostsGet)).To(Equal("b"))
+		Expect(pool.Pick(endpoints.ChannelsGet)).To(Equal("a"))
+
+		pool.MarkExhausted("a")
+		Expect(pool.Pick(endpoints.ChannelsGet)).To(Equal("b"))
+	})
+
+	t.Run("Test exhausted token returns after cooldown", func(t *testing.T) {
+		now := time.Now()
+		pool := NewTokenPool(RoundRobin, "a")
+		pool.now = func() time.Time { return now }
+		Expect(pool.SetCooldown(time.Minute)).To(Succeed())
+		Expect(pool.SetCooldown(0)).To(HaveOccurred())
+		pool.MarkExhausted("a")
+
+		_, err := pool.Pick(endpoints.ChannelsGet)
+		Expect(errors.Is(err, ErrTokensExhausted)).To(BeTrue())
+
+		now = now.Add(time.Minute)
+		Expect(pool.Pick(endpoints.ChannelsGet)).To(Equal("a"))
+	})
+
+	t.Run("Test exhausted token waits for its expiry", func(t *testing.T) {
+		now := time.Now()
+		pool := NewTokenPool(RoundRobin, "a")
+		pool.now = func() time.Time { return now }
+		pool.UpdateUsage("a", StatResult{Response: []StatResponse{
+			{SpentRequests: "10/10", ExpiredAt: now.Add(48 * time.Hour).Unix()},
+		}})
+		pool.MarkExhausted("a")
+
+		now = now.Add(2 * DefaultTokenCooldown)
+		_, err := pool.Pick(endpoints.ChannelsGet)
+		Expect(err).To(MatchError(ErrTokensExhausted))
+	})
+}
+
+func TestClientDoWithTokenPool(t *testing.T) {
+	RegisterTestingT(t)
+	quotaHandler := func(w http.ResponseWriter, token string) {
+		w.Header().Set("Content-Type", "application/json")
+		if token == "spent" {
+			json.NewEncoder(w).Encode(ErrorResult{Status: "error", Error: "quota_requests_exceeded"})
+			return
+		}
+		json.NewEncoder(w).Encode(SuccessResult{Status: "ok"})
+	}
+
+	t.Run("Test GET request fails over to the next token", func(t *testing.T) {
+		newServer := server.NewServer()
+		defer newServer.Teardown()
+
+		var seen []string
+		newServer.Mux.HandleFunc(endpoints.ChannelsGet, func(w http.ResponseWriter, r *http.Request) {
+			seen = append(seen, r.URL.Query().Get("token"))
+			quotaHandler(w, r.URL.Query().Get("token"))
+		})
+
+		client, _ := newClient(newServer.URL)
+		client.pool = NewTokenPool(RoundRobin, "spent", "fresh")
+		request, err := client.NewRestRequest(context.Background(), "", http.MethodGet, endpoints.ChannelsGet, make(map[string]string))
+		Expect(err).ToNot(HaveOccurred())
+
+		var response SuccessResult
+		_, err = client.Do(request, &response)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(response.Status).To(Equal("ok"))
+		Expect(seen).To(Equal([]string{"spent", "fresh"}))
+	})
+
+	t.Run("Test POST request fails over to the next token", func(t *testing.T) {
+		newServer := server.NewServer()
+		defer newServer.Teardown()
+
+		newServer.Mux.HandleFunc(endpoints.ChannelsAdd, func(w http.ResponseWriter, r *http.Request) {
+			data := make(map[string]string)
+			json.NewDecoder(r.Body).Decode(&data)
+			Expect(data["channelName"]).To(Equal("name"))
+			quotaHandler(w, data["token"])
+		})
+
+		client, _ := newClient(newServer.URL)
+		client.pool = NewTokenPool(RoundRobin, "spent", "fresh")
+		request, _ := client.NewRestRequest(context.Background(), "", http.MethodPost, endpoints.ChannelsAdd, map[string]string{"channelName": "name"})
+
+		_, err := client.Do(request, nil)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	t.Run("Test all tokens exhausted", func(t *testing.T) {
+		newServer := server.NewServer()
+		defer newServer.Teardown()
+
+		newServer.Mux.HandleFunc(endpoints.ChannelsGet, func(w http.ResponseWriter, r *http.Request) {
+			quotaHandler(w, "spent")
+		})
+
+		client, _ := newClient(newServer.URL)
+		client.pool = NewTokenPool(RoundRobin, "a", "b")
+		request, _ := client.NewRestRequest(context.Background(), "", http.MethodGet, endpoints.ChannelsGet, make(map[string]string))
+
+		_, err := client.Do(request, nil)
+		Expect(err).To(MatchError(ErrTokensExhausted))
+		Expect(IsQuotaError(err)).To(BeTrue())
+	})
+
+	t.Run("Test each token is tried once", func(t *testing.T) {
+		newServer := server.NewServer()
+		defer newServer.Teardown()
+
+		requests := 0
+		newServer.Mux.HandleFunc(endpoints.ChannelsGet, func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			quotaHandler(w, "spent")
+		})
+
+		client, _ := newClient(newServer.URL)
+		client.pool = NewTokenPool(RoundRobin, "a", "b")
+		// without a cooldown exhausted tokens are available again at once
+		client.pool.cooldown = 0
+		request, _ := client.NewRestRequest(context.Background(), "", http.MethodGet, endpoints.ChannelsGet, make(map[string]string))
+
+		_, err := client.Do(request, nil)
+		Expect(err).To(MatchError(ErrTokensExhausted))
+		Expect(IsQuotaError(err)).To(BeTrue())
+		Expect(requests).To(Equal(2))
+	})
+
+	t.Run("Test explicit token is not replaced by the pool", func(t *testing.T) {
+		newServer := server.NewServer()
+		defer newServer.Teardown()
+
+		var seen []string
+		newServer.Mux.HandleFunc(endpoints.ChannelsGet, func(w http.ResponseWriter, r *http.Request) {
+			seen = append(seen, r.URL.Query().Get("token"))
+			quotaHandler(w, r.URL.Query().Get("token"))
+		})
+
+		client, _ := newClient(newServer.URL)
+		client.pool = NewTokenPool(RoundRobin, "fresh")
+		request, _ := client.NewRestRequest(context.Background(), "spent", http.MethodGet, endpoints.ChannelsGet, make(map[string]string))
+
+		_, err := client.Do(request, nil)
+		Expect(IsQuotaError(err)).To(BeTrue())
+		Expect(seen).To(Equal([]string{"spent"}))
+	})
+
+	t.Run("Test quota errors are matched by code", func(t *testing.T) {
+		Expect(IsQuotaError(&APIError{Message: "no_active_subscription"})).To(BeTrue())
+		Expect(IsQuotaError(&APIError{Message: "Timeout expired"})).To(BeFalse())
+		Expect(IsQuotaError(&APIError{Message: "limit exceeded for channel"})).To(BeFalse())
+		Expect(IsQuotaError(errors.New("quota_requests_exceeded"))).To(BeFalse())
+	})
+}
@@ -41,9 +41,29 @@ func (c Client) Stat(ctx context.Context) (*tgstat.StatResult, *http.Response, e
 	return &response, result, err
 }
 
+// RefreshTokenPool requests usage statistics for every token of the pool
+// so that it knows how much quota each token has left.
+func RefreshTokenPool(ctx context.Context, pool *tgstat.TokenPool) error {
+	api := tgstat.GetAPI()
+	for _, token := range pool.Tokens() {
+		response, _, err := Client{api, token}.Stat(ctx)
+		if err != nil {
+			return err
+		}
+		pool.UpdateUsage(token, *response)
+	}
+
+	return nil
+}
+
 func getClient() Client {
 	return Client{
 		tgstat.GetAPI(),
 		tgstat.Token,
 	}
 }
+
+// NewClient returns a client authenticated with token.
+func NewClient(token string) Client {
+	return Client{tgstat.GetAPI(), token}
+}
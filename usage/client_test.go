@@ -65,3 +65,32 @@ func TestClient_UsageStat(t *testing.T) {
 		tgstat.NewRestRequest = oldNewRequest
 	})
 }
+
+func TestRefreshTokenPool(t *testing.T) {
+	RegisterTestingT(t)
+	t.Run("Test least used token is picked after refresh", func(t *testing.T) {
+		testServer := server.NewServer()
+		defer testServer.Teardown()
+		prepareClient(testServer.URL)
+
+		spent := map[string]string{"first": "900/1000", "second": "10/1000"}
+		testServer.Mux.HandleFunc(endpoints.UsageStat, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(tgstat.StatResult{
+				Status: "ok",
+				Response: []tgstat.StatResponse{
+					{ServiceKey: "api", SpentRequests: spent[r.URL.Query().Get("token")]},
+				},
+			})
+		})
+
+		pool := tgstat.NewTokenPool(tgstat.LeastUsed, "first", "second")
+		err := RefreshTokenPool(context.Background(), pool)
+		Expect(err).ToNot(HaveOccurred())
+
+		token, err := pool.Pick(endpoints.ChannelsGet)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(token).To(Equal("second"))
+	})
+}
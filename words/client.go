@@ -16,20 +16,21 @@ type Client struct {
 
 type MentionPeriodRequest struct {
 	Q              string
-	PeerType       *string
+	PeerType       tgstat.PeerType
 	StartDate      *string
 	EndDate        *string
 	HideForwards   *bool
 	StrongSearch   *bool
 	MinusWords     *string
-	Group          *string
+	Group          tgstat.Group
 	ExtendedSyntax *bool
 }
 
 func (mentionPeriodRequest MentionPeriodRequest) Validate() error {
 	return validation.ValidateStruct(&mentionPeriodRequest,
 		validation.Field(&mentionPeriodRequest.Q, validation.Required),
-		validation.Field(&mentionPeriodRequest.Group, validation.In("day", "week", "month")),
+		validation.Field(&mentionPeriodRequest.PeerType, validation.By(tgstat.AllowedFor(endpoints.WordsMentionsByPeriod))),
+		validation.Field(&mentionPeriodRequest.Group, validation.By(tgstat.AllowedFor(endpoints.WordsMentionsByPeriod))),
 	)
 }
 
@@ -58,8 +59,8 @@ func (c Client) MentionsByPeriod(ctx context.Context, request MentionPeriodReque
 
 	body := make(map[string]string)
 	body["q"] = request.Q
-	if nil != request.PeerType {
-		body["peerType"] = *request.PeerType
+	if request.PeerType != "" {
+		body["peerType"] = string(request.PeerType)
 	}
 	if nil != request.StartDate {
 		body["startDate"] = *request.StartDate
@@ -88,8 +89,8 @@ func (c Client) MentionsByPeriod(ctx context.Context, request MentionPeriodReque
 		body["minusWords"] = *request.MinusWords
 	}
 
-	if nil != request.Group {
-		body["group"] = *request.Group
+	if request.Group != "" {
+		body["group"] = string(request.Group)
 	}
 
 	body["extendedSyntax"] = func() string {
@@ -117,7 +118,7 @@ func (c Client) MentionsByPeriod(ctx context.Context, request MentionPeriodReque
 
 type MentionsByChannelRequest struct {
 	Q              string
-	PeerType       *string
+	PeerType       tgstat.PeerType
 	StartDate      *string
 	EndDate        *string
 	HideForwards   *bool
@@ -129,7 +130,7 @@ type MentionsByChannelRequest struct {
 func (mentionsByChannelRequest MentionsByChannelRequest) Validate() error {
 	return validation.ValidateStruct(&mentionsByChannelRequest,
 		validation.Field(&mentionsByChannelRequest.Q, validation.Required),
-		validation.Field(&mentionsByChannelRequest.PeerType, validation.In("channel", "chat", "all")),
+		validation.Field(&mentionsByChannelRequest.PeerType, validation.By(tgstat.AllowedFor(endpoints.WordsMentionsByChannels))),
 	)
 }
 
@@ -158,8 +159,8 @@ func (c Client) MentionsByChannels(ctx context.Context, request MentionsByChanne
 
 	body := make(map[string]string)
 	body["q"] = request.Q
-	if nil != request.PeerType {
-		body["peerType"] = *request.PeerType
+	if request.PeerType != "" {
+		body["peerType"] = string(request.PeerType)
 	}
 	if nil != request.StartDate {
 		body["startDate"] = *request.StartDate
@@ -54,7 +54,7 @@ func TestClient_MentionsByPeriod(t *testing.T) {
 
 		req := MentionPeriodRequest{
 			Q:        "",
-			PeerType: tgstat.String("5"),
+			PeerType: tgstat.PeerType("5"),
 		}
 		_, _, err := MentionsByPeriod(context.Background(), req)
 		Expect(err).To(HaveOccurred())
@@ -116,14 +116,14 @@ func TestClient_MentionsByPeriod(t *testing.T) {
 
 		req := MentionPeriodRequest{
 			Q:        "q",
-			PeerType: tgstat.String("all"),
+			PeerType: tgstat.PeerAll,
 		}
 		_, _, err := MentionsByPeriod(context.Background(), req)
 		Expect(err).ToNot(HaveOccurred())
 
 		req = MentionPeriodRequest{
 			Q:         "q",
-			PeerType:  tgstat.String("all"),
+			PeerType:  tgstat.PeerAll,
 			StartDate: tgstat.String("2020011907"),
 		}
 		_, _, err = MentionsByPeriod(context.Background(), req)
@@ -131,7 +131,7 @@ func TestClient_MentionsByPeriod(t *testing.T) {
 
 		req = MentionPeriodRequest{
 			Q:            "q",
-			PeerType:     tgstat.String("all"),
+			PeerType:     tgstat.PeerAll,
 			EndDate:      tgstat.String("2020011907"),
 			HideForwards: tgstat.Bool(true),
 		}
@@ -140,7 +140,7 @@ func TestClient_MentionsByPeriod(t *testing.T) {
 
 		req = MentionPeriodRequest{
 			Q:            "q",
-			PeerType:     tgstat.String("all"),
+			PeerType:     tgstat.PeerAll,
 			EndDate:      tgstat.String("2020011907"),
 			HideForwards: tgstat.Bool(true),
 			StrongSearch: tgstat.Bool(false),
@@ -150,7 +150,7 @@ func TestClient_MentionsByPeriod(t *testing.T) {
 
 		req = MentionPeriodRequest{
 			Q:            "q",
-			PeerType:     tgstat.String("all"),
+			PeerType:     tgstat.PeerAll,
 			EndDate:      tgstat.String("2020011907"),
 			HideForwards: tgstat.Bool(true),
 			StrongSearch: tgstat.Bool(false),
@@ -161,24 +161,24 @@ func TestClient_MentionsByPeriod(t *testing.T) {
 
 		req = MentionPeriodRequest{
 			Q:            "q",
-			PeerType:     tgstat.String("all"),
+			PeerType:     tgstat.PeerAll,
 			EndDate:      tgstat.String("2020011907"),
 			HideForwards: tgstat.Bool(true),
 			StrongSearch: tgstat.Bool(true),
 			MinusWords:   tgstat.String("something"),
-			Group:        tgstat.String("day"),
+			Group:        tgstat.GroupDay,
 		}
 		_, _, err = MentionsByPeriod(context.Background(), req)
 		Expect(err).ToNot(HaveOccurred())
 
 		req = MentionPeriodRequest{
 			Q:              "q",
-			PeerType:       tgstat.String("all"),
+			PeerType:       tgstat.PeerAll,
 			EndDate:        tgstat.String("2020011907"),
 			HideForwards:   tgstat.Bool(true),
 			StrongSearch:   tgstat.Bool(true),
 			MinusWords:     tgstat.String("something"),
-			Group:          tgstat.String("week"),
+			Group:          tgstat.GroupWeek,
 			ExtendedSyntax: tgstat.Bool(true),
 		}
 		_, _, err = MentionsByPeriod(context.Background(), req)
@@ -187,13 +187,13 @@ func TestClient_MentionsByPeriod(t *testing.T) {
 
 		req = MentionPeriodRequest{
 			Q:              "q",
-			PeerType:       tgstat.String("all"),
+			PeerType:       tgstat.PeerAll,
 			StartDate:      tgstat.String("sdalfjs"),
 			EndDate:        tgstat.String("sdfsdf"),
 			HideForwards:   tgstat.Bool(true),
 			StrongSearch:   tgstat.Bool(true),
 			MinusWords:     tgstat.String("something"),
-			Group:          tgstat.String("week"),
+			Group:          tgstat.GroupWeek,
 			ExtendedSyntax: tgstat.Bool(true),
 		}
 		_, _, err = MentionsByPeriod(context.Background(), req)
@@ -201,13 +201,13 @@ func TestClient_MentionsByPeriod(t *testing.T) {
 
 		req = MentionPeriodRequest{
 			Q:              "q",
-			PeerType:       tgstat.String("all"),
+			PeerType:       tgstat.PeerAll,
 			StartDate:      nil,
 			EndDate:        tgstat.String("sdfsdf"),
 			HideForwards:   tgstat.Bool(true),
 			StrongSearch:   tgstat.Bool(true),
 			MinusWords:     tgstat.String("something"),
-			Group:          tgstat.String("week"),
+			Group:          tgstat.GroupWeek,
 			ExtendedSyntax: tgstat.Bool(true),
 		}
 		_, _, err = MentionsByPeriod(context.Background(), req)
@@ -215,13 +215,13 @@ func TestClient_MentionsByPeriod(t *testing.T) {
 
 		req = MentionPeriodRequest{
 			Q:              "q",
-			PeerType:       tgstat.String("all"),
+			PeerType:       tgstat.PeerAll,
 			EndDate:        nil,
 			StartDate:      nil,
 			HideForwards:   tgstat.Bool(true),
 			StrongSearch:   tgstat.Bool(true),
 			MinusWords:     tgstat.String("something"),
-			Group:          tgstat.String("week"),
+			Group:          tgstat.GroupWeek,
 			ExtendedSyntax: tgstat.Bool(true),
 		}
 		_, _, err = MentionsByPeriod(context.Background(), req)
@@ -269,14 +269,14 @@ func TestClient_MentionsByChannels(t *testing.T) {
 
 		req := MentionsByChannelRequest{
 			Q:        "q",
-			PeerType: tgstat.String("chat"),
+			PeerType: tgstat.PeerChat,
 		}
 		_, _, err := MentionsByChannels(context.Background(), req)
 		Expect(err).ToNot(HaveOccurred())
 
 		req = MentionsByChannelRequest{
 			Q:         "q",
-			PeerType:  tgstat.String("chat"),
+			PeerType:  tgstat.PeerChat,
 			StartDate: tgstat.String("2020011907"),
 		}
 		_, _, err = MentionsByChannels(context.Background(), req)
@@ -284,7 +284,7 @@ func TestClient_MentionsByChannels(t *testing.T) {
 
 		req = MentionsByChannelRequest{
 			Q:            "q",
-			PeerType:     tgstat.String("all"),
+			PeerType:     tgstat.PeerAll,
 			EndDate:      tgstat.String("2020011907"),
 			HideForwards: tgstat.Bool(true),
 		}
@@ -293,7 +293,7 @@ func TestClient_MentionsByChannels(t *testing.T) {
 
 		req = MentionsByChannelRequest{
 			Q:            "q",
-			PeerType:     tgstat.String("all"),
+			PeerType:     tgstat.PeerAll,
 			EndDate:      tgstat.String("2020011907"),
 			HideForwards: tgstat.Bool(true),
 			StrongSearch: tgstat.Bool(false),
@@ -303,7 +303,7 @@ func TestClient_MentionsByChannels(t *testing.T) {
 
 		req = MentionsByChannelRequest{
 			Q:            "q",
-			PeerType:     tgstat.String("all"),
+			PeerType:     tgstat.PeerAll,
 			EndDate:      tgstat.String("2020011907"),
 			HideForwards: tgstat.Bool(true),
 			StrongSearch: tgstat.Bool(false),
@@ -314,7 +314,7 @@ func TestClient_MentionsByChannels(t *testing.T) {
 
 		req = MentionsByChannelRequest{
 			Q:            "q",
-			PeerType:     tgstat.String("chat"),
+			PeerType:     tgstat.PeerChat,
 			EndDate:      tgstat.String("2020011907"),
 			HideForwards: tgstat.Bool(true),
 			StrongSearch: tgstat.Bool(true),
@@ -325,7 +325,7 @@ func TestClient_MentionsByChannels(t *testing.T) {
 
 		req = MentionsByChannelRequest{
 			Q:              "q",
-			PeerType:       tgstat.String("chat"),
+			PeerType:       tgstat.PeerChat,
 			EndDate:        tgstat.String("2020011907"),
 			HideForwards:   tgstat.Bool(true),
 			StrongSearch:   tgstat.Bool(true),
@@ -337,7 +337,7 @@ func TestClient_MentionsByChannels(t *testing.T) {
 
 		req = MentionsByChannelRequest{
 			Q:              "q",
-			PeerType:       tgstat.String("chat"),
+			PeerType:       tgstat.PeerChat,
 			StartDate:      tgstat.String("vlvlv"),
 			EndDate:        tgstat.String("202asdasd0011907"),
 			HideForwards:   tgstat.Bool(true),
@@ -350,7 +350,7 @@ func TestClient_MentionsByChannels(t *testing.T) {
 
 		req = MentionsByChannelRequest{
 			Q:              "q",
-			PeerType:       tgstat.String("chat"),
+			PeerType:       tgstat.PeerChat,
 			StartDate:      nil,
 			EndDate:        tgstat.String("202asdasd0011907"),
 			HideForwards:   tgstat.Bool(true),
@@ -363,7 +363,7 @@ func TestClient_MentionsByChannels(t *testing.T) {
 
 		req = MentionsByChannelRequest{
 			Q:              "q",
-			PeerType:       tgstat.String("chat"),
+			PeerType:       tgstat.PeerChat,
 			StartDate:      tgstat.String("123123123"),
 			EndDate:        tgstat.String("123123"),
 			HideForwards:   tgstat.Bool(true),
@@ -376,7 +376,7 @@ func TestClient_MentionsByChannels(t *testing.T) {
 
 		req = MentionsByChannelRequest{
 			Q:              "q",
-			PeerType:       tgstat.String("chat"),
+			PeerType:       tgstat.PeerChat,
 			StartDate:      nil,
 			EndDate:        nil,
 			HideForwards:   tgstat.Bool(true),